@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// AuditEntry is one record of GET /audit.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	Resource   string    `json:"resource"`
+	ResourceID string    `json:"resource_id"`
+	RequestID  string    `json:"request_id"`
+	Principal  string    `json:"principal"`
+}
+
+func cmdAudit() *cobra.Command {
+	var resource, resourceID, action, since, until string
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "List recent model and job changes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q := url.Values{}
+			for key, v := range map[string]string{"resource": resource, "resource_id": resourceID, "action": action} {
+				if v != "" {
+					q.Set(key, v)
+				}
+			}
+			for key, v := range map[string]string{"since": since, "until": until} {
+				if v == "" {
+					continue
+				}
+				t, err := auditTime(v)
+				if err != nil {
+					return fmt.Errorf("invalid --%s %q: want an RFC 3339 time or a duration such as 24h", key, v)
+				}
+				q.Set(key, t)
+			}
+			return auditList(q)
+		},
+	}
+	cmd.Flags().StringVar(&resource, "resource", "", "Only model or job entries")
+	_ = cmd.RegisterFlagCompletionFunc("resource", completeWords("model", "job"))
+	cmd.Flags().StringVar(&resourceID, "id", "", "Only entries for this model or job ID")
+	cmd.Flags().StringVar(&action, "action", "", "Only this action, e.g. model.update or job.cancel")
+	cmd.Flags().StringVar(&since, "since", "", "Only entries at or after this time (RFC 3339, or a duration ago such as 24h)")
+	cmd.Flags().StringVar(&until, "until", "", "Only entries before this time (RFC 3339, or a duration ago)")
+	return cmd
+}
+
+// auditTime turns an RFC 3339 time, or a duration before now, into the
+// RFC 3339 form GET /audit expects.
+func auditTime(v string) (string, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d).UTC().Format(time.RFC3339), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+func auditList(q url.Values) error {
+	path := "/audit"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	body, err := apiGet(path)
+	if err != nil {
+		return err
+	}
+	var entries []AuditEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return err
+	}
+	return printOutput(body,
+		func() {
+			if len(entries) == 0 {
+				fmt.Println("no audit entries")
+				return
+			}
+			fmt.Printf("%-20s %-18s %-6s %-10s %-16s %s\n", "TIME", "ACTION", "KIND", "ID", "PRINCIPAL", "REQUEST")
+			for _, e := range entries {
+				principal := e.Principal
+				if principal == "" {
+					principal = "-"
+				}
+				fmt.Printf("%-20s %-18s %-6s %-10s %-16s %s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Action, e.Resource, e.ResourceID, principal, e.RequestID)
+			}
+		},
+		func() [][]string {
+			records := [][]string{{"time", "action", "resource", "resource_id", "principal", "request_id"}}
+			for _, e := range entries {
+				records = append(records, []string{e.Time.Format(time.RFC3339Nano), e.Action, e.Resource, e.ResourceID, e.Principal, e.RequestID})
+			}
+			return records
+		})
+}
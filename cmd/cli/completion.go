@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout bounds the API lookups behind dynamic completion so a
+// slow or unreachable server never stalls the shell.
+const completionTimeout = 2 * time.Second
+
+type completionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// completeModelArg completes a leading model ID; later arguments fall back
+// to the shell completion given by rest.
+func completeModelArg(rest cobra.ShellCompDirective) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, rest
+		}
+		return modelCompletions(cmd, nil), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeModelArgs completes any number of distinct model IDs.
+func completeModelArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return modelCompletions(cmd, args), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeJobArg completes a single job ID.
+func completeJobArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return jobCompletions(cmd, nil), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeJobArgs completes any number of distinct job IDs.
+func completeJobArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return jobCompletions(cmd, args), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeWords completes a flag from a fixed list of values.
+func completeWords(words ...string) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return words, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// jobCompletions returns "id<TAB>state model" for each job not in skip.
+func jobCompletions(cmd *cobra.Command, skip []string) []string {
+	var list []JobStatus
+	if err := json.Unmarshal(completionGet(cmd, "/jobs"), &list); err != nil {
+		return nil
+	}
+	out := make([]string, 0, len(list))
+	for _, j := range list {
+		if indexOf(skip, j.JobID) >= 0 {
+			continue
+		}
+		out = append(out, j.JobID+"\t"+j.State+" "+j.ModelID)
+	}
+	return out
+}
+
+// modelCompletions returns "id<TAB>name" for each model not in skip.
+func modelCompletions(cmd *cobra.Command, skip []string) []string {
+	var list []Model
+	if err := json.Unmarshal(completionGet(cmd, "/models"), &list); err != nil {
+		return nil
+	}
+	out := make([]string, 0, len(list))
+	for _, m := range list {
+		if indexOf(skip, m.ID) >= 0 {
+			continue
+		}
+		out = append(out, m.ID+"\t"+strings.TrimSpace(m.Name))
+	}
+	return out
+}
+
+// completionGet fetches path for dynamic completion without retries. Any
+// failure yields nil so the shell simply offers no completions.
+func completionGet(cmd *cobra.Command, path string) []byte {
+	// Completion runs without the root command's PersistentPreRunE
+	if err := loadGlobalFlags(cmd); err != nil {
+		return nil
+	}
+	retries = 0
+	apiClient.Timeout = completionTimeout
+	get := apiGet
+	if strings.HasPrefix(path, "/models") {
+		get = apiGetCached
+	}
+	body, err := get(path)
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configPath string
+	profile    string
+)
+
+// configFlags are the persistent flags a config file may set, with the
+// environment variable that takes precedence over it, if any.
+var configFlags = []struct{ flag, env string }{
+	{"api", "BATCH_API_URL"},
+	{"token", "BATCH_API_TOKEN"},
+	{"namespace", "BATCH_NAMESPACE"},
+	{"output", ""},
+	{"retries", "BATCH_RETRIES"},
+	{"retry-delay", "BATCH_RETRY_DELAY"},
+	{"api-timeout", "BATCH_API_TIMEOUT"},
+	{"upload-timeout", "BATCH_UPLOAD_TIMEOUT"},
+}
+
+// configFile is the CLI config file. Settings are named after the flags
+// they default; those at the top level apply to every profile, and the
+// selected profile's settings override them.
+type configFile struct {
+	Settings       map[string]interface{}            `yaml:",inline"`
+	DefaultProfile string                            `yaml:"default-profile"`
+	Profiles       map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+// configFilePath returns the config file named by --config or
+// BATCH_CONFIG, which must exist, or else ~/.batch/config.yaml, which may
+// not. It is "" without a home directory.
+func configFilePath() (path string, explicit bool) {
+	if configPath != "" {
+		return configPath, true
+	}
+	if v := os.Getenv("BATCH_CONFIG"); v != "" {
+		return v, true
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(home, ".batch", "config.yaml"), false
+}
+
+// readConfig parses the config file at path; a missing file that was not
+// asked for reads as empty.
+func readConfig(path string, explicit bool) (configFile, error) {
+	var cfg configFile
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// applyConfig fills the persistent flags that were neither given nor set
+// through the environment from the config file.
+func applyConfig(cmd *cobra.Command) error {
+	name := profile
+	if name == "" {
+		name = os.Getenv("BATCH_PROFILE")
+	}
+	path, explicit := configFilePath()
+	if path == "" {
+		if name != "" {
+			return fmt.Errorf("profile %q needs a config file", name)
+		}
+		return nil
+	}
+	cfg, err := readConfig(path, explicit)
+	if err != nil {
+		return err
+	}
+
+	settings := cfg.Settings
+	if name == "" {
+		name = cfg.DefaultProfile
+	}
+	if name != "" {
+		p, ok := cfg.Profiles[name]
+		if !ok {
+			return fmt.Errorf("%s: profile %q not found (have %s)", path, name, profileNames(cfg.Profiles))
+		}
+		merged := map[string]interface{}{}
+		for k, v := range settings {
+			merged[k] = v
+		}
+		for k, v := range p {
+			merged[k] = v
+		}
+		settings = merged
+	}
+
+	for key := range settings {
+		known := false
+		for _, f := range configFlags {
+			known = known || f.flag == key
+		}
+		if !known {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+	}
+	for _, f := range configFlags {
+		v, ok := settings[f.flag]
+		if !ok || v == nil || cmd.Flags().Changed(f.flag) || (f.env != "" && os.Getenv(f.env) != "") {
+			continue
+		}
+		if err := cmd.Flags().Set(f.flag, fmt.Sprint(v)); err != nil {
+			return fmt.Errorf("%s: invalid %s: %w", path, f.flag, err)
+		}
+	}
+	return nil
+}
+
+func profileNames(profiles map[string]map[string]interface{}) string {
+	if len(profiles) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// completeProfiles completes the profile names of the config file.
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	path, explicit := configFilePath()
+	cfg, err := readConfig(path, explicit)
+	if path == "" || err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/keithchambers/batch-ingestion/internal/rowschema"
+)
+
+// schemaChange is one difference between two model schemas. Field is ""
+// for keywords of the schema itself; Attribute is "" when a whole field
+// was added or removed. Before and After are JSON text.
+type schemaChange struct {
+	Change    string `json:"change"` // added, removed or changed
+	Field     string `json:"field"`
+	Attribute string `json:"attribute,omitempty"`
+	Before    string `json:"before,omitempty"`
+	After     string `json:"after,omitempty"`
+}
+
+func cmdModelDiff() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff <model_id|schema_file> <schema_file>",
+		Short: "Compare a model's schema, or a schema file, with a schema file",
+		Long: "Print the fields added, removed or changed from the first schema to the second,\n" +
+			"and each changed keyword such as type, required, enum, pattern or maximum. The\n" +
+			"first argument is read as a file if one exists by that name, and otherwise as\n" +
+			"a model whose current schema is fetched. Exits 1 if the schemas differ.",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeModelArg(cobra.ShellCompDirectiveDefault),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return modelDiff(args[0], args[1])
+		},
+	}
+}
+
+func modelDiff(from, toFile string) error {
+	var before json.RawMessage
+	if _, err := os.Stat(from); err == nil {
+		if before, err = os.ReadFile(from); err != nil {
+			return err
+		}
+	} else {
+		body, err := apiGet("/models/" + from)
+		if err != nil {
+			return err
+		}
+		var model Model
+		if err := json.Unmarshal(body, &model); err != nil {
+			return err
+		}
+		before = model.Schema
+	}
+	after, err := os.ReadFile(toFile)
+	if err != nil {
+		return err
+	}
+
+	changes, err := diffSchemas(before, after)
+	if err != nil {
+		return err
+	}
+	out, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	err = printOutput(out, func() {
+		if len(changes) == 0 {
+			fmt.Println("no differences")
+			return
+		}
+		printSchemaChanges(changes)
+	}, func() [][]string {
+		records := [][]string{{"change", "field", "attribute", "before", "after"}}
+		for _, c := range changes {
+			records = append(records, []string{c.Change, c.Field, c.Attribute, c.Before, c.After})
+		}
+		return records
+	})
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		return &exitError{code: 1, msg: fmt.Sprintf("%d differences", len(changes))}
+	}
+	return nil
+}
+
+// modelSchema is the part of a JSON schema diffSchemas compares.
+type modelSchema struct {
+	keywords   map[string]json.RawMessage // top-level keywords besides properties and required
+	properties map[string]map[string]json.RawMessage
+	order      []string // property names in declaration order
+	required   []string
+}
+
+func parseModelSchema(raw json.RawMessage) (modelSchema, error) {
+	var s modelSchema
+	if err := json.Unmarshal(raw, &s.keywords); err != nil {
+		return s, fmt.Errorf("invalid schema: %w", err)
+	}
+	if props, ok := s.keywords["properties"]; ok {
+		if err := json.Unmarshal(props, &s.properties); err != nil {
+			return s, fmt.Errorf("invalid schema properties: %w", err)
+		}
+	}
+	if req, ok := s.keywords["required"]; ok {
+		if err := json.Unmarshal(req, &s.required); err != nil {
+			return s, fmt.Errorf("invalid schema required: %w", err)
+		}
+	}
+	delete(s.keywords, "properties")
+	delete(s.keywords, "required")
+	s.order = rowschema.Columns(raw)
+	return s, nil
+}
+
+// diffSchemas lists the differences from before to after: schema keywords
+// first, then fields in declaration order, removed fields last.
+func diffSchemas(before, after json.RawMessage) ([]schemaChange, error) {
+	a, err := parseModelSchema(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := parseModelSchema(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []schemaChange{}
+	changes = append(changes, diffKeywords("", a.keywords, b.keywords)...)
+	for _, name := range b.order {
+		old, ok := a.properties[name]
+		if !ok {
+			changes = append(changes, schemaChange{Change: "added", Field: name, After: compactJSON(b.properties[name])})
+			if indexOf(b.required, name) >= 0 {
+				changes = append(changes, schemaChange{Change: "added", Field: name, Attribute: "required", After: "true"})
+			}
+			continue
+		}
+		wasRequired, isRequired := indexOf(a.required, name) >= 0, indexOf(b.required, name) >= 0
+		if wasRequired != isRequired {
+			changes = append(changes, schemaChange{
+				Change: "changed", Field: name, Attribute: "required",
+				Before: fmt.Sprint(wasRequired), After: fmt.Sprint(isRequired),
+			})
+		}
+		changes = append(changes, diffKeywords(name, old, b.properties[name])...)
+	}
+	for _, name := range a.order {
+		if _, ok := b.properties[name]; !ok {
+			changes = append(changes, schemaChange{Change: "removed", Field: name, Before: compactJSON(a.properties[name])})
+		}
+	}
+	return changes, nil
+}
+
+// diffKeywords compares two sets of schema keywords, in name order.
+func diffKeywords(field string, before, after map[string]json.RawMessage) []schemaChange {
+	keys := make([]string, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []schemaChange
+	for _, k := range keys {
+		old, hadOld := before[k]
+		cur, hasCur := after[k]
+		switch {
+		case !hadOld:
+			changes = append(changes, schemaChange{Change: "added", Field: field, Attribute: k, After: compact(cur)})
+		case !hasCur:
+			changes = append(changes, schemaChange{Change: "removed", Field: field, Attribute: k, Before: compact(old)})
+		case !sameJSON(old, cur):
+			changes = append(changes, schemaChange{Change: "changed", Field: field, Attribute: k, Before: compact(old), After: compact(cur)})
+		}
+	}
+	return changes
+}
+
+// compactJSON encodes a property's keywords on one line.
+func compactJSON(prop map[string]json.RawMessage) string {
+	b, _ := json.Marshal(prop)
+	return string(b)
+}
+
+// compact removes insignificant white space from a JSON value.
+func compact(v json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, v); err != nil {
+		return string(v)
+	}
+	return buf.String()
+}
+
+func printSchemaChanges(changes []schemaChange) {
+	fmt.Printf("%-8s %-20s %-20s %-24s %s\n", "CHANGE", "FIELD", "ATTRIBUTE", "BEFORE", "AFTER")
+	fmt.Println("-------- -------------------- -------------------- ------------------------ ------------------------")
+	for _, c := range changes {
+		field := c.Field
+		if field == "" {
+			field = "(schema)"
+		}
+		fmt.Printf("%-8s %-20s %-20s %-24s %s\n", c.Change, clip(field, 20), c.Attribute, clip(c.Before, 24), clip(c.After, 40))
+	}
+}
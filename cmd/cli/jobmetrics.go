@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JobMetrics is the reply to GET /jobs/{id}/metrics.
+type JobMetrics struct {
+	ElapsedMS  int64             `json:"elapsed_ms"`
+	RowsPerSec float64           `json:"rows_per_sec"`
+	Throughput []ThroughputPoint `json:"throughput"`
+	KafkaWrite struct {
+		Count int64   `json:"count"`
+		AvgMS float64 `json:"avg_ms"`
+		P99MS float64 `json:"p99_ms"`
+		MaxMS float64 `json:"max_ms"`
+	} `json:"kafka_write_latency"`
+	Time struct {
+		ValidatingMS int64 `json:"validating_ms"`
+		ProducingMS  int64 `json:"producing_ms"`
+		ThrottledMS  int64 `json:"throttled_ms"`
+		PausedMS     int64 `json:"paused_ms"`
+	} `json:"time"`
+}
+
+// ThroughputPoint is the number of rows a job produced in one second.
+type ThroughputPoint struct {
+	Time time.Time `json:"time"`
+	Rows int       `json:"rows"`
+}
+
+// jobStatusVerbose prints a job's status followed by its metrics. JSON and
+// YAML output nest the metrics under "metrics".
+func jobStatusVerbose(jobID string) error {
+	job, jobBody, err := fetchJob(jobID)
+	if err != nil {
+		return err
+	}
+	metricsBody, err := apiGet("/jobs/" + jobID + "/metrics")
+	if err != nil {
+		return err
+	}
+	var m JobMetrics
+	if err := json.Unmarshal(metricsBody, &m); err != nil {
+		return err
+	}
+	var combined map[string]json.RawMessage
+	if err := json.Unmarshal(jobBody, &combined); err != nil {
+		return err
+	}
+	combined["metrics"] = metricsBody
+	body, err := json.Marshal(combined)
+	if err != nil {
+		return err
+	}
+	if err := printOutput(body,
+		func() {
+			printJobTable([]JobStatus{job})
+			printJobNotes([]JobStatus{job})
+			printJobInput(job)
+			printRejectionSummary(job)
+			fmt.Println()
+			printJobMetrics(m)
+		},
+		func() [][]string { return jobCSVRecords([]JobStatus{job}) }); err != nil {
+		return err
+	}
+	return stateError([]JobStatus{job})
+}
+
+func printJobMetrics(m JobMetrics) {
+	fmt.Printf("Throughput:    %s rows/s over %s\n", strings.TrimSuffix(fmt.Sprintf("%.1f", m.RowsPerSec), ".0"), time.Duration(m.ElapsedMS)*time.Millisecond)
+	if len(m.Throughput) > 0 {
+		peak := 0
+		for _, p := range m.Throughput {
+			peak = max(peak, p.Rows)
+		}
+		fmt.Printf("               peak %s rows/s, last %ds: %s\n", formatNumber(peak), len(m.Throughput), sparkline(m.Throughput, peak))
+	}
+	if m.KafkaWrite.Count > 0 {
+		fmt.Printf("Kafka writes:  %s, avg %.1f ms, p99 %.1f ms, max %.1f ms\n", formatNumber(int(m.KafkaWrite.Count)), m.KafkaWrite.AvgMS, m.KafkaWrite.P99MS, m.KafkaWrite.MaxMS)
+	}
+	total := m.Time.ValidatingMS + m.Time.ProducingMS + m.Time.ThrottledMS + m.Time.PausedMS
+	share := func(ms int64) string {
+		if total == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%d%%", ms*100/total)
+	}
+	fmt.Printf("Time:          validating %s (%s), producing %s (%s), throttled %s (%s)\n",
+		time.Duration(m.Time.ValidatingMS)*time.Millisecond, share(m.Time.ValidatingMS),
+		time.Duration(m.Time.ProducingMS)*time.Millisecond, share(m.Time.ProducingMS),
+		time.Duration(m.Time.ThrottledMS)*time.Millisecond, share(m.Time.ThrottledMS))
+	if m.Time.PausedMS > 0 {
+		fmt.Printf("               paused %s (%s)\n", time.Duration(m.Time.PausedMS)*time.Millisecond, share(m.Time.PausedMS))
+	}
+}
+
+// sparkline draws up to the last 60 per-second counts relative to peak.
+func sparkline(points []ThroughputPoint, peak int) string {
+	levels := []rune("▁▂▃▄▅▆▇█")
+	if len(points) > 60 {
+		points = points[len(points)-60:]
+	}
+	var b strings.Builder
+	for _, p := range points {
+		i := 0
+		if peak > 0 {
+			i = p.Rows * (len(levels) - 1) / peak
+		}
+		b.WriteRune(levels[i])
+	}
+	return b.String()
+}
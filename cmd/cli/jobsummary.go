@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// JobSummary aggregates the jobs matched by job summary.
+type JobSummary struct {
+	Jobs            int            `json:"jobs"`
+	States          map[string]int `json:"states"`
+	Rows            int            `json:"rows"`     // rows processed, rejected or not
+	OK              int            `json:"ok"`       // rows produced
+	Rejected        int            `json:"rejected"` // rows sent to the DLQ
+	Finished        int            `json:"finished"` // jobs in a terminal state, which avg_processing_ms covers
+	AvgProcessingMS int64          `json:"avg_processing_ms"`
+}
+
+func cmdJobSummary() *cobra.Command {
+	var models, states, tags []string
+	var since string
+	cmd := &cobra.Command{
+		Use:   "summary",
+		Short: "Show totals across jobs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var after time.Time
+			if since != "" {
+				t, err := auditTime(since)
+				if err != nil {
+					return fmt.Errorf("invalid --since %q: want an RFC 3339 time or a duration such as 24h", since)
+				}
+				after, _ = time.Parse(time.RFC3339, t)
+			}
+			for i, s := range states {
+				states[i] = strings.ToUpper(s)
+			}
+			return jobSummary(models, states, tags, after)
+		},
+	}
+	cmd.Flags().StringSliceVar(&models, "model", nil, "Only jobs of these model IDs (comma-separated or repeated)")
+	cmd.Flags().StringSliceVar(&states, "state", nil, "Only jobs in these states (comma-separated or repeated)")
+	_ = cmd.RegisterFlagCompletionFunc("state", completeWords("PENDING", "RUNNING", "PAUSED", "SUCCESS", "PARTIAL_SUCCESS", "FAILED", "CANCELLED"))
+	cmd.Flags().StringArrayVar(&tags, "tag", nil, "Only jobs with this tag, as key:value or key (repeatable; all must match)")
+	cmd.Flags().StringVar(&since, "since", "", "Only jobs started at or after this time (RFC 3339, or a duration ago such as 24h)")
+	return cmd
+}
+
+func jobSummary(models, states, tags []string, since time.Time) error {
+	path := "/jobs"
+	if len(tags) > 0 {
+		path += "?" + url.Values{"tag": tags}.Encode()
+	}
+	body, err := apiGet(path)
+	if err != nil {
+		return err
+	}
+	var jobs []JobStatus
+	if err := json.Unmarshal(body, &jobs); err != nil {
+		return err
+	}
+
+	s := JobSummary{States: map[string]int{}}
+	var processing int64
+	for _, job := range jobs {
+		// Pending jobs have not started; they were last touched when created
+		started := job.StartedAt
+		if started.IsZero() {
+			started = job.UpdatedAt
+		}
+		if (len(models) > 0 && indexOf(models, job.ModelID) < 0) ||
+			(len(states) > 0 && indexOf(states, job.State) < 0) ||
+			started.Before(since) {
+			continue
+		}
+		s.Jobs++
+		s.States[job.State]++
+		s.Rows += max(job.Totals.Rows, job.Totals.OK+job.Totals.Errors+job.Totals.Duplicates)
+		s.OK += job.Totals.OK
+		s.Rejected += job.Totals.Errors
+		if isTerminal(job.State) {
+			s.Finished++
+			processing += job.Timings.ProcessingMS
+		}
+	}
+	if s.Finished > 0 {
+		s.AvgProcessingMS = processing / int64(s.Finished)
+	}
+
+	out, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return printOutput(out,
+		func() { printJobSummary(s) },
+		func() [][]string {
+			return [][]string{
+				{"jobs", "states", "rows", "ok", "rejected", "finished", "avg_processing_ms"},
+				{strconv.Itoa(s.Jobs), summaryStates(s.States), strconv.Itoa(s.Rows), strconv.Itoa(s.OK),
+					strconv.Itoa(s.Rejected), strconv.Itoa(s.Finished), strconv.FormatInt(s.AvgProcessingMS, 10)},
+			}
+		})
+}
+
+func printJobSummary(s JobSummary) {
+	if s.Jobs == 0 {
+		fmt.Println("no jobs")
+		return
+	}
+	fmt.Printf("Jobs:      %s (%s)\n", formatNumber(s.Jobs), strings.ReplaceAll(summaryStates(s.States), "=", " "))
+	fmt.Printf("Rows:      %s processed, %s ok, %s rejected\n", formatNumber(s.Rows), formatNumber(s.OK), formatNumber(s.Rejected))
+	if s.Finished > 0 {
+		fmt.Printf("Avg time:  %s over %s finished jobs\n", time.Duration(s.AvgProcessingMS)*time.Millisecond, formatNumber(s.Finished))
+	}
+}
+
+// summaryStates formats per-state job counts as STATE=n pairs, most
+// frequent first.
+func summaryStates(states map[string]int) string {
+	names := make([]string, 0, len(states))
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if states[names[i]] != states[names[j]] {
+			return states[names[i]] > states[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strconv.Itoa(states[name])
+	}
+	return strings.Join(pairs, ", ")
+}
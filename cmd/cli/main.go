@@ -1,28 +1,70 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+
+	"github.com/keithchambers/batch-ingestion/internal/rowschema"
+)
+
+var (
+	apiURL        string
+	apiToken      string
+	namespace     string
+	outputFormat  string
+	retries       int
+	retryDelay    time.Duration
+	apiTimeout    time.Duration
+	uploadTimeout time.Duration
+)
+
+var (
+	// apiClient sends ordinary API requests, bounded by --api-timeout.
+	apiClient = &http.Client{}
+	// uploadClient sends job uploads and rejected-row downloads, which can
+	// take far longer; it is bounded by --upload-timeout.
+	uploadClient = &http.Client{}
+	// streamClient follows event streams. Only the wait for response
+	// headers is bounded, by --api-timeout, since a stream stays open.
+	streamClient = &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
 )
 
-var apiURL string
+var outputFormats = []string{"table", "json", "yaml", "csv"}
 
 // Data structures for API responses
 type Model struct {
-	ID     string          `json:"id"`
-	Name   string          `json:"name"`
-	Schema json.RawMessage `json:"schema"`
+	ID       string           `json:"id"`
+	Name     string           `json:"name"`
+	Schema   json.RawMessage  `json:"schema"`
+	Defaults json.RawMessage  `json:"defaults,omitempty"`
+	Mapping  json.RawMessage  `json:"mapping,omitempty"`
+	Computed json.RawMessage  `json:"computed,omitempty"`
+	Nulls    *rowschema.Nulls `json:"nulls,omitempty"`
 }
 
 type JobStatus struct {
@@ -30,9 +72,11 @@ type JobStatus struct {
 	ModelID string `json:"model_id"`
 	State   string `json:"state"`
 	Totals  struct {
-		Rows   int `json:"rows"`
-		OK     int `json:"ok"`
-		Errors int `json:"errors"`
+		Rows       int `json:"rows"`
+		OK         int `json:"ok"`
+		Errors     int `json:"errors"`
+		Duplicates int `json:"duplicates"`
+		Expected   int `json:"expected"` // estimated by the server; 0 if unknown
 	} `json:"totals"`
 	Timings struct {
 		WaitingMS    int64 `json:"waiting_ms"`
@@ -40,41 +84,158 @@ type JobStatus struct {
 	} `json:"timings"`
 	UpdatedAt time.Time `json:"updated_at"`
 	StartedAt time.Time `json:"started_at"`
+	Warnings  []string  `json:"warnings"`
+
+	ETASeconds       *int64         `json:"eta_seconds"`
+	RejectionSummary map[string]int `json:"rejection_summary"` // "REASON" or "REASON:column" to rows
+
+	Checksum  string    `json:"checksum"` // hex SHA-256 of a single uploaded file
+	Size      int64     `json:"size"`     // bytes uploaded or fetched, across all files
+	SourceURL string    `json:"source_url"`
+	Files     []JobFile `json:"files"` // set for multi-file jobs
+}
+
+// JobFile is one file of a job uploaded as several files.
+type JobFile struct {
+	Name     string `json:"name"`
+	Checksum string `json:"checksum"`
+	Size     int64  `json:"size"`
 }
 
 type RejectedRow struct {
-	JobID     string    `json:"job_id"`
-	RowNumber int       `json:"row_number"`
-	RawData   string    `json:"raw_data"`
-	Error     string    `json:"error"`
-	Timestamp time.Time `json:"timestamp"`
+	JobID         string    `json:"job_id"`
+	RowNumber     int       `json:"row_number"`
+	RawData       string    `json:"raw_data"`
+	Error         string    `json:"error"`
+	ErrorType     string    `json:"error_type"`
+	Column        string    `json:"column"`
+	ObservedValue string    `json:"observed_value"`
+	ExpectedType  string    `json:"expected_type"`
+	SourceFile    string    `json:"source_file"`
+	Timestamp     time.Time `json:"timestamp"`
 }
 
 func main() {
 	root := &cobra.Command{
-		Use:   "batch",
-		Short: "Batch ingestion CLI",
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
-			if apiURL == "" {
-				apiURL = getenv("BATCH_API_URL", "http://localhost:8000")
-			}
+		Use:           "batch",
+		Short:         "Batch ingestion CLI",
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Arguments are already validated; runtime failures don't need usage
+			cmd.SilenceUsage = true
+			return loadGlobalFlags(cmd)
 		},
 	}
-	root.PersistentFlags().StringVar(&apiURL, "api", "", "Batch ingestion API URL")
+	root.PersistentFlags().StringVar(&configPath, "config", "", "Config file supplying defaults for these flags (env BATCH_CONFIG, default ~/.batch/config.yaml)")
+	root.PersistentFlags().StringVar(&profile, "profile", "", "Config file profile to use (env BATCH_PROFILE, default the file's default-profile)")
+	root.PersistentFlags().StringVar(&apiURL, "api", "", "Batch ingestion API URL (env BATCH_API_URL, default http://localhost:8000)")
+	root.PersistentFlags().StringVar(&apiToken, "token", "", "API key sent as a bearer token (env BATCH_API_TOKEN)")
+	root.PersistentFlags().StringVarP(&namespace, "namespace", "n", "", "Namespace whose models and jobs to act on (env BATCH_NAMESPACE, default \"default\")")
+	root.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format: table, json, yaml or csv")
+	root.PersistentFlags().IntVar(&retries, "retries", 2, "Retries for failed requests (env BATCH_RETRIES)")
+	root.PersistentFlags().DurationVar(&retryDelay, "retry-delay", 500*time.Millisecond, "Initial delay between retries, doubled each attempt (env BATCH_RETRY_DELAY)")
+	root.PersistentFlags().DurationVar(&apiTimeout, "api-timeout", 30*time.Second, "Timeout for each API request, 0 for none (env BATCH_API_TIMEOUT)")
+	root.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Print only the IDs of the jobs or models a command returns")
+	root.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Log each API call's method, URL, status and duration to stderr")
+	root.PersistentFlags().DurationVar(&uploadTimeout, "upload-timeout", 30*time.Minute, "Timeout for job uploads and rejected-row downloads, 0 for none (env BATCH_UPLOAD_TIMEOUT)")
+	_ = root.RegisterFlagCompletionFunc("output", completeWords(outputFormats...))
+	_ = root.RegisterFlagCompletionFunc("profile", completeProfiles)
 
 	// model commands
 	modelCmd := &cobra.Command{Use: "model", Short: "Model operations"}
-	modelCmd.AddCommand(cmdModelList(), cmdModelDescribe(), cmdModelCreate(), cmdModelUpdate(), cmdModelDelete())
+	modelCmd.AddCommand(cmdModelList(), cmdModelDescribe(), cmdModelVersions(), cmdModelCreate(), cmdModelClone(), cmdModelUpdate(), cmdModelDelete(), cmdModelExport(), cmdModelImport(), cmdModelDiff(), cmdModelCancelJobs())
 	root.AddCommand(modelCmd)
 
 	// job commands
 	jobCmd := &cobra.Command{Use: "job", Short: "Job operations"}
-	jobCmd.AddCommand(cmdJobList(), cmdJobCreate(), cmdJobStatus(), cmdJobCancel(), cmdJobRejected())
+	jobCmd.AddCommand(cmdJobList(), cmdJobSummary(), cmdJobCreate(), cmdJobCreateAll(), cmdJobValidate(), cmdJobStatus(), cmdJobCancel(), cmdJobDelete(), cmdJobPause(), cmdJobResume(), cmdJobRejected(), cmdJobRetry(), cmdJobPurge(), cmdJobWait())
 	root.AddCommand(jobCmd)
+	root.AddCommand(cmdAudit())
+	root.AddCommand(cmdQuota())
+	root.AddCommand(cmdVersion())
+
+	if err := root.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		var exit *exitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
+		}
+		os.Exit(1)
+	}
+}
 
-	_ = root.Execute()
+// loadGlobalFlags fills unset persistent flags from the environment, then
+// the config file, and validates them.
+func loadGlobalFlags(cmd *cobra.Command) error {
+	if err := applyConfig(cmd); err != nil {
+		return err
+	}
+	if apiURL == "" {
+		apiURL = getenv("BATCH_API_URL", "http://localhost:8000")
+	}
+	if apiToken == "" {
+		apiToken = os.Getenv("BATCH_API_TOKEN")
+	}
+	if namespace == "" {
+		namespace = os.Getenv("BATCH_NAMESPACE")
+	}
+	if v := os.Getenv("BATCH_RETRIES"); v != "" && !cmd.Flags().Changed("retries") {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid BATCH_RETRIES %q: %w", v, err)
+		}
+		retries = n
+	}
+	if v := os.Getenv("BATCH_RETRY_DELAY"); v != "" && !cmd.Flags().Changed("retry-delay") {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid BATCH_RETRY_DELAY %q: %w", v, err)
+		}
+		retryDelay = d
+	}
+	for _, t := range []struct {
+		flag, env string
+		d         *time.Duration
+	}{{"api-timeout", "BATCH_API_TIMEOUT", &apiTimeout}, {"upload-timeout", "BATCH_UPLOAD_TIMEOUT", &uploadTimeout}} {
+		if v := os.Getenv(t.env); v != "" && !cmd.Flags().Changed(t.flag) {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", t.env, v, err)
+			}
+			*t.d = d
+		}
+		if *t.d < 0 {
+			return fmt.Errorf("invalid --%s %s: must not be negative", t.flag, *t.d)
+		}
+	}
+	apiClient.Timeout = apiTimeout
+	uploadClient.Timeout = uploadTimeout
+	streamClient.Transport.(*http.Transport).ResponseHeaderTimeout = apiTimeout
+	if quiet && verbose {
+		return fmt.Errorf("--quiet and --verbose cannot be used together")
+	}
+	if verbose {
+		logRequests()
+	}
+	if retries < 0 {
+		return fmt.Errorf("invalid --retries %d: must not be negative", retries)
+	}
+	for _, f := range outputFormats {
+		if outputFormat == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid --output %q: must be one of %s", outputFormat, strings.Join(outputFormats, ", "))
 }
 
+// exitError makes the process exit with a specific status code.
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string { return e.msg }
+
 // ---------------- model commands ----------------
 
 func cmdModelList() *cobra.Command {
@@ -88,18 +249,217 @@ func cmdModelList() *cobra.Command {
 }
 
 func cmdModelDescribe() *cobra.Command {
+	var version int
+	cmd := &cobra.Command{
+		Use:               "describe <model_id>",
+		Short:             "Describe a model",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeModelArg(cobra.ShellCompDirectiveNoFileComp),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "/models/" + args[0]
+			if version > 0 {
+				path += "?version=" + strconv.Itoa(version)
+			}
+			return httpGet(path)
+		},
+	}
+	cmd.Flags().IntVar(&version, "version", 0, "Show a specific model version instead of the latest")
+	return cmd
+}
+
+func cmdModelVersions() *cobra.Command {
 	return &cobra.Command{
-		Use:   "describe <model_id>",
-		Short: "Describe a model",
-		Args:  cobra.ExactArgs(1),
+		Use:               "versions <model_id>",
+		Short:             "List every version of a model",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeModelArg(cobra.ShellCompDirectiveNoFileComp),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return httpGet("/models/" + args[0])
+			return httpGet("/models/" + args[0] + "/versions")
 		},
 	}
 }
 
+// modelDefaultsFlags are the job defaults model create and update store on
+// the model.
+type modelDefaultsFlags struct {
+	targetTopic, keyColumn, rowFormat, cleanupPolicy string
+}
+
+func (f *modelDefaultsFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.targetTopic, "target-topic", "", "Default topic for the model's jobs; may use {model_id}, {model_name} and {version}")
+	cmd.Flags().StringVar(&f.keyColumn, "key-column", "", "Default message key column for the model's jobs")
+	cmd.Flags().StringVar(&f.rowFormat, "output-format", "", "Default encoding of produced rows: array, object, avro or protobuf")
+	_ = cmd.RegisterFlagCompletionFunc("output-format", completeWords("array", "object", "avro", "protobuf"))
+	cmd.Flags().StringVar(&f.cleanupPolicy, "cleanup-policy", "", "Default cleanup.policy of the model's job topics: delete or compact")
+	_ = cmd.RegisterFlagCompletionFunc("cleanup-policy", completeWords("delete", "compact"))
+}
+
+// defaults returns the defaults object for the flags that were set, or
+// nil when none were.
+func (f *modelDefaultsFlags) defaults() map[string]string {
+	d := map[string]string{}
+	for key, v := range map[string]string{
+		"target_topic":   f.targetTopic,
+		"key_column":     f.keyColumn,
+		"output_format":  f.rowFormat,
+		"cleanup_policy": f.cleanupPolicy,
+	} {
+		if v != "" {
+			d[key] = v
+		}
+	}
+	if len(d) == 0 {
+		return nil
+	}
+	return d
+}
+
+// modelMappingFlags are the column mapping model create and update store
+// on the model.
+type modelMappingFlags struct {
+	rename, exclude []string
+	dropUnmapped    bool
+}
+
+func (f *modelMappingFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&f.rename, "rename", nil, "Produce source column as field, given as column=field (repeatable)")
+	cmd.Flags().StringArrayVar(&f.exclude, "exclude", nil, "Never produce this source column (repeatable)")
+	cmd.Flags().BoolVar(&f.dropUnmapped, "drop-unmapped", false, "Produce only the columns named by --rename")
+}
+
+// mapping returns the mapping object for the flags that were set, or nil
+// when none were.
+func (f *modelMappingFlags) mapping() (map[string]interface{}, error) {
+	if len(f.rename) == 0 && len(f.exclude) == 0 && !f.dropUnmapped {
+		return nil, nil
+	}
+	rename := map[string]string{}
+	for _, r := range f.rename {
+		col, field, ok := strings.Cut(r, "=")
+		if !ok || col == "" || field == "" {
+			return nil, fmt.Errorf("invalid --rename %q: want column=field", r)
+		}
+		rename[col] = field
+	}
+	m := map[string]interface{}{"drop_unmapped": f.dropUnmapped}
+	if len(rename) > 0 {
+		m["rename"] = rename
+	}
+	if len(f.exclude) > 0 {
+		m["exclude"] = f.exclude
+	}
+	return m, nil
+}
+
+// modelComputedFlags are the computed fields model create and update store
+// on the model.
+type modelComputedFlags struct {
+	computed, constants []string
+}
+
+func (f *modelComputedFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&f.computed, "computed", nil, "Add field=builtin to every row, where builtin is now(), job_id, row_number or source_filename (repeatable)")
+	cmd.Flags().StringArrayVar(&f.constants, "constant", nil, "Add field=value to every row as a string (repeatable)")
+}
+
+// fields returns the computed fields for the flags that were set, or nil
+// when none were.
+func (f *modelComputedFlags) fields() ([]map[string]string, error) {
+	var fields []map[string]string
+	for _, kind := range []struct {
+		flag, key string
+		values    []string
+	}{{"computed", "builtin", f.computed}, {"constant", "value", f.constants}} {
+		for _, v := range kind.values {
+			name, val, ok := strings.Cut(v, "=")
+			if !ok || name == "" {
+				return nil, fmt.Errorf("invalid --%s %q: want field=%s", kind.flag, v, kind.key)
+			}
+			fields = append(fields, map[string]string{"name": name, kind.key: val})
+		}
+	}
+	return fields, nil
+}
+
+// modelNullFlags are the null values model create and update store on
+// the model.
+type modelNullFlags struct {
+	values, columns []string
+}
+
+func (f *modelNullFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&f.values, "null", nil, "Read cells holding this value, e.g. NULL or \\N, as null; '' makes empty strings null too (repeatable)")
+	cmd.Flags().StringArrayVar(&f.columns, "column-null", nil, "Read column=value as null in that column instead of the --null values (repeatable)")
+}
+
+// nulls returns the null values for the flags that were set, or nil when
+// none were.
+func (f *modelNullFlags) nulls() (*rowschema.Nulls, error) {
+	if len(f.values) == 0 && len(f.columns) == 0 {
+		return nil, nil
+	}
+	n := &rowschema.Nulls{Values: f.values}
+	for _, c := range f.columns {
+		col, v, ok := strings.Cut(c, "=")
+		if !ok || col == "" {
+			return nil, fmt.Errorf("invalid --column-null %q: want column=value", c)
+		}
+		if n.Columns == nil {
+			n.Columns = map[string][]string{}
+		}
+		n.Columns[col] = append(n.Columns[col], v)
+	}
+	return n, nil
+}
+
+// modelFlags are the settings besides the schema that model create and
+// update send.
+type modelFlags struct {
+	defaults modelDefaultsFlags
+	mapping  modelMappingFlags
+	computed modelComputedFlags
+	nulls    modelNullFlags
+}
+
+func (f *modelFlags) register(cmd *cobra.Command) {
+	f.defaults.register(cmd)
+	f.mapping.register(cmd)
+	f.computed.register(cmd)
+	f.nulls.register(cmd)
+}
+
+// apply adds the settings whose flags were set to a model request body.
+func (f *modelFlags) apply(req map[string]interface{}) error {
+	if d := f.defaults.defaults(); d != nil {
+		req["defaults"] = d
+	}
+	m, err := f.mapping.mapping()
+	if err != nil {
+		return err
+	}
+	if m != nil {
+		req["mapping"] = m
+	}
+	c, err := f.computed.fields()
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		req["computed"] = c
+	}
+	n, err := f.nulls.nulls()
+	if err != nil {
+		return err
+	}
+	if n != nil {
+		req["nulls"] = n
+	}
+	return nil
+}
+
 func cmdModelCreate() *cobra.Command {
-	return &cobra.Command{
+	var mf modelFlags
+	cmd := &cobra.Command{
 		Use:   "create <name> <schema_file>",
 		Short: "Create model",
 		Args:  cobra.ExactArgs(2),
@@ -110,226 +470,1475 @@ func cmdModelCreate() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			body, _ := json.Marshal(map[string]interface{}{
+			req := map[string]interface{}{
 				"name":   name,
 				"schema": json.RawMessage(schema),
-			})
+			}
+			if err := mf.apply(req); err != nil {
+				return err
+			}
+			body, _ := json.Marshal(req)
 			return httpPost("/models", body)
 		},
 	}
+	mf.register(cmd)
+	return cmd
 }
 
-func cmdModelUpdate() *cobra.Command {
+func cmdModelClone() *cobra.Command {
 	return &cobra.Command{
-		Use:   "update <model_id> <schema_file>",
-		Short: "Update model",
-		Args:  cobra.ExactArgs(2),
+		Use:               "clone <model_id> <new_name>",
+		Short:             "Create a model from another's schema and settings",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeModelArg(cobra.ShellCompDirectiveNoFileComp),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			body, _ := json.Marshal(map[string]string{"name": args[1]})
+			return httpPost("/models/"+args[0]+"/clone", body)
+		},
+	}
+}
+
+func cmdModelUpdate() *cobra.Command {
+	var force bool
+	var compatibility string
+	var mf modelFlags
+	cmd := &cobra.Command{
+		Use:               "update <model_id> <schema_file>",
+		Short:             "Update model",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeModelArg(cobra.ShellCompDirectiveDefault),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			id := args[0]
 			schema, err := os.ReadFile(args[1])
 			if err != nil {
 				return err
 			}
-			body, _ := json.Marshal(map[string]interface{}{
+			req := map[string]interface{}{
 				"schema": json.RawMessage(schema),
-			})
-			return httpPut("/models/"+id, body)
+			}
+			if err := mf.apply(req); err != nil {
+				return err
+			}
+			body, _ := json.Marshal(req)
+			q := url.Values{}
+			if force {
+				q.Set("force", "true")
+			}
+			if compatibility != "" {
+				q.Set("compatibility", compatibility)
+			}
+			path := "/models/" + id
+			if len(q) > 0 {
+				path += "?" + q.Encode()
+			}
+			return httpPut(path, body)
 		},
 	}
+	cmd.Flags().BoolVar(&force, "force", false, "Update even if jobs using the model are still active")
+	cmd.Flags().StringVar(&compatibility, "compatibility", "", "Schema compatibility to enforce: BACKWARD, FORWARD, FULL (server default) or NONE")
+	mf.register(cmd)
+	return cmd
 }
 
 func cmdModelDelete() *cobra.Command {
-	return &cobra.Command{
-		Use:   "delete <model_id>",
-		Short: "Delete model",
-		Args:  cobra.ExactArgs(1),
+	var force, yes bool
+	cmd := &cobra.Command{
+		Use:               "delete <model_id>",
+		Short:             "Delete model",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeModelArg(cobra.ShellCompDirectiveNoFileComp),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := confirmModelDelete(args[0], yes); err != nil {
+				return err
+			}
+			return httpDelete("/models/" + args[0] + forceQuery(force))
+		},
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "Delete even if jobs using the model are still active")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+	return cmd
+}
+
+func cmdModelCancelJobs() *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:               "cancel-jobs <model_id>",
+		Short:             "Cancel every pending, running or paused job of a model",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeModelArg(cobra.ShellCompDirectiveNoFileComp),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return httpDelete("/models/" + args[0])
+			if err := confirmModelCancelJobs(args[0], yes); err != nil {
+				return err
+			}
+			return modelCancelJobs(args[0])
 		},
 	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+	return cmd
+}
+
+func modelCancelJobs(modelID string) error {
+	body, err := apiSend("POST", "/models/"+modelID+"/jobs/cancel", nil)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Cancelled []string `json:"cancelled"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+	if quiet {
+		fmt.Print(strings.Join(append(result.Cancelled, ""), "\n"))
+		return nil
+	}
+	return printOutput(body, func() {
+		for _, id := range result.Cancelled {
+			fmt.Println(id)
+		}
+		fmt.Printf("%d jobs cancelled\n", len(result.Cancelled))
+	}, func() [][]string {
+		records := [][]string{{"job"}}
+		for _, id := range result.Cancelled {
+			records = append(records, []string{id})
+		}
+		return records
+	})
+}
+
+func forceQuery(force bool) string {
+	if force {
+		return "?force=true"
+	}
+	return ""
+}
+
+// confirm asks the user to approve a destructive action and returns an
+// error if they decline. It does not ask when yes is set or stdin is not a
+// terminal, so scripts and pipelines never block on the prompt.
+func confirm(prompt string, yes bool) error {
+	if yes || !stdinIsTerminal() {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return &exitError{code: 1, msg: "aborted"}
+}
+
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// confirmModelDelete looks the model up so the prompt can show its name.
+func confirmModelDelete(modelID string, yes bool) error {
+	if yes || !stdinIsTerminal() {
+		return nil
+	}
+	body, err := apiGet("/models/" + modelID)
+	if err != nil {
+		return err
+	}
+	var model Model
+	if err := json.Unmarshal(body, &model); err != nil {
+		return err
+	}
+	return confirm(fmt.Sprintf("Delete model %s (%s)?", model.Name, modelID), false)
+}
+
+// confirmModelCancelJobs counts the model's active jobs for the prompt.
+func confirmModelCancelJobs(modelID string, yes bool) error {
+	if yes || !stdinIsTerminal() {
+		return nil
+	}
+	body, err := apiGet("/jobs")
+	if err != nil {
+		return err
+	}
+	var list []JobStatus
+	if err := json.Unmarshal(body, &list); err != nil {
+		return err
+	}
+	active := 0
+	for _, j := range list {
+		if j.ModelID == modelID && !isTerminal(j.State) {
+			active++
+		}
+	}
+	return confirm(fmt.Sprintf("Cancel %d active jobs of model %s (%s)?", active, getModelName(modelID), modelID), false)
+}
+
+// confirmJob looks the job up so the prompt can show its model and
+// progress.
+func confirmJob(verb, jobID string, yes bool) error {
+	if yes || !stdinIsTerminal() {
+		return nil
+	}
+	job, _, err := fetchJob(jobID)
+	if err != nil {
+		return err
+	}
+	return confirm(fmt.Sprintf("%s job %s (model %s, %s, %s rows)?",
+		verb, jobID, getModelName(job.ModelID), job.State, formatNumber(job.Totals.Rows)), false)
 }
 
 // ---------------- job commands ----------------
 
 func cmdJobList() *cobra.Command {
-	return &cobra.Command{
+	var tags []string
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List jobs",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return jobList()
+			return jobList(tags)
 		},
 	}
+	cmd.Flags().StringArrayVar(&tags, "tag", nil, "Only list jobs with this tag, as key:value or key (repeatable; all must match)")
+	return cmd
+}
+
+// jobCreateFlags are the job settings shared by job create and create-all.
+type jobCreateFlags struct {
+	callbackURL, rowFormat, inputEncoding string
+	delimiter                             string
+	dedupeColumn, keyColumn, tombstoneCol string
+	cleanupPolicy, targetTopic            string
+	createTopic, exactlyOnce              bool
+	trimSpace, skipBlankLines             bool
+	retentionMS                           int64
+	dedupe, strictColumns                 bool
+	timeout                               time.Duration
+	rateLimit, maxErrors                  int
+	maxErrorRate                          float64
+	tags                                  []string
+}
+
+func (f *jobCreateFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.callbackURL, "callback-url", "", "URL the server POSTs the final job status to")
+	cmd.Flags().BoolVar(&f.dedupe, "dedupe", false, "Return the existing job if this file was already ingested into the model")
+	cmd.Flags().StringVar(&f.rowFormat, "output-format", "", "Encoding of produced rows: array (default), object, avro or protobuf")
+	_ = cmd.RegisterFlagCompletionFunc("output-format", completeWords("array", "object", "avro", "protobuf"))
+	cmd.Flags().StringArrayVar(&f.tags, "tag", nil, "Label the job with key=value (repeatable)")
+	cmd.Flags().StringVar(&f.inputEncoding, "encoding", "", "Character set of the file: utf-8 (default), latin1, windows-1252, utf-16, utf-16le or utf-16be")
+	_ = cmd.RegisterFlagCompletionFunc("encoding", completeWords("utf-8", "latin1", "windows-1252", "utf-16", "utf-16le", "utf-16be"))
+	cmd.Flags().StringVar(&f.delimiter, "delimiter", "", "CSV field separator: one character, or tab (default tab for .tsv files, comma otherwise)")
+	cmd.Flags().BoolVar(&f.trimSpace, "trim-space", false, "Strip leading and trailing white space from every field")
+	cmd.Flags().BoolVar(&f.skipBlankLines, "skip-blank-lines", false, "Skip lines whose fields are all blank instead of rejecting them")
+	cmd.Flags().BoolVar(&f.strictColumns, "strict-columns", true, "Reject rows whose field count differs from the schema; false pads or truncates them")
+	cmd.Flags().DurationVar(&f.timeout, "timeout", 0, "Stop processing the job after this long (the server's JOB_TIMEOUT still applies)")
+	cmd.Flags().IntVar(&f.rateLimit, "rate-limit", 0, "Produce at most this many rows per second, 0 for no limit (the server's MAX_ROWS_PER_SEC still applies)")
+	cmd.Flags().IntVar(&f.maxErrors, "max-errors", 0, "Fail the job once more than this many rows are rejected (default unlimited)")
+	cmd.Flags().Float64Var(&f.maxErrorRate, "max-error-rate", 0, "Fail the job once more than this fraction of rows is rejected, e.g. 0.05 (default unlimited)")
+	cmd.Flags().StringVar(&f.dedupeColumn, "dedupe-column", "", "Skip rows whose value in this column was already produced by the job")
+	cmd.Flags().StringVar(&f.keyColumn, "key-column", "", "Use this column's value as the Kafka message key instead of the job ID")
+	cmd.Flags().StringVar(&f.tombstoneCol, "tombstone-column", "", "Produce a tombstone (key, null value) for rows where this column is true, 1 or yes; needs --key-column")
+	cmd.Flags().StringVar(&f.cleanupPolicy, "cleanup-policy", "", "cleanup.policy of the job's topic: delete (default) or compact")
+	_ = cmd.RegisterFlagCompletionFunc("cleanup-policy", completeWords("delete", "compact"))
+	cmd.Flags().Int64Var(&f.retentionMS, "retention-ms", 0, "retention.ms of the job's topic, -1 to keep rows forever (default 7 days)")
+	cmd.Flags().StringVar(&f.targetTopic, "target-topic", "", "Produce into this existing topic instead of a new batch_<job_id> topic")
+	cmd.Flags().BoolVar(&f.createTopic, "create-topic", false, "Create the target topic (--target-topic or the model's) if it does not exist")
+	cmd.Flags().BoolVar(&f.exactlyOnce, "exactly-once", false, "Produce rows in Kafka transactions so consumers reading committed data never see duplicates")
+}
+
+// fields returns the form values for the flags that were set.
+func (f *jobCreateFlags) fields(cmd *cobra.Command) (map[string]string, error) {
+	fields := map[string]string{}
+	if f.callbackURL != "" {
+		fields["callback_url"] = f.callbackURL
+	}
+	if f.rowFormat != "" {
+		fields["output_format"] = f.rowFormat
+	}
+	if f.timeout > 0 {
+		fields["timeout"] = f.timeout.String()
+	}
+	if f.inputEncoding != "" {
+		fields["encoding"] = f.inputEncoding
+	}
+	if f.delimiter != "" {
+		fields["delimiter"] = f.delimiter
+	}
+	if f.dedupeColumn != "" {
+		fields["dedupe_column"] = f.dedupeColumn
+	}
+	if f.keyColumn != "" {
+		fields["key_column"] = f.keyColumn
+	}
+	if f.tombstoneCol != "" {
+		fields["tombstone_column"] = f.tombstoneCol
+	}
+	if f.cleanupPolicy != "" {
+		fields["cleanup_policy"] = f.cleanupPolicy
+	}
+	if f.retentionMS != 0 {
+		fields["retention_ms"] = strconv.FormatInt(f.retentionMS, 10)
+	}
+	if f.targetTopic != "" {
+		fields["target_topic"] = f.targetTopic
+	}
+	if f.createTopic {
+		fields["create_topic"] = "true"
+	}
+	if f.exactlyOnce {
+		fields["exactly_once"] = "true"
+	}
+	if f.trimSpace {
+		fields["trim_space"] = "true"
+	}
+	if f.skipBlankLines {
+		fields["skip_blank_lines"] = "true"
+	}
+	if f.rateLimit < 0 {
+		return nil, fmt.Errorf("invalid --rate-limit %d: must not be negative", f.rateLimit)
+	}
+	if f.rateLimit > 0 {
+		fields["rate_limit"] = strconv.Itoa(f.rateLimit)
+	}
+	if cmd.Flags().Changed("max-errors") {
+		if f.maxErrors < 0 {
+			return nil, fmt.Errorf("invalid --max-errors %d: must not be negative", f.maxErrors)
+		}
+		fields["max_errors"] = strconv.Itoa(f.maxErrors)
+	}
+	if cmd.Flags().Changed("max-error-rate") {
+		if f.maxErrorRate < 0 || f.maxErrorRate > 1 {
+			return nil, fmt.Errorf("invalid --max-error-rate %g: must be between 0 and 1", f.maxErrorRate)
+		}
+		fields["max_error_rate"] = strconv.FormatFloat(f.maxErrorRate, 'g', -1, 64)
+	}
+	for _, tag := range f.tags {
+		if !strings.Contains(tag, "=") {
+			return nil, fmt.Errorf("invalid --tag %q: want key=value", tag)
+		}
+	}
+	if len(f.tags) > 0 {
+		fields["tags"] = strings.Join(f.tags, ",")
+	}
+	if cmd.Flags().Changed("strict-columns") {
+		fields["strict_columns"] = strconv.FormatBool(f.strictColumns)
+	}
+	if f.dedupe {
+		fields["dedupe"] = "true"
+	}
+	return fields, nil
 }
 
 func cmdJobCreate() *cobra.Command {
-	return &cobra.Command{
-		Use:   "create <model_id> <file>",
+	var flags jobCreateFlags
+	var sourceURL string
+	var wait, sync bool
+	var interval time.Duration
+	cmd := &cobra.Command{
+		Use:   "create <model_id> [file...]",
 		Short: "Create job",
-		Args:  cobra.ExactArgs(2),
+		Long: "Create a job from one or more files, or from --url. With --wait, poll the job until\n" +
+			"it finishes, print its final status and exit as job wait does. With --sync, the\n" +
+			"server holds the request open until the job finishes instead, up to its\n" +
+			"SYNC_JOB_WAIT; a job still running then is reported as created, as without --sync.",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeModelArg(cobra.ShellCompDirectiveDefault),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (len(args) > 1) == (sourceURL != "") {
+				return fmt.Errorf("give either files or --url")
+			}
+			fields, err := flags.fields(cmd)
+			if err != nil {
+				return err
+			}
+			if sourceURL != "" {
+				if flags.dedupe {
+					return fmt.Errorf("--dedupe is not supported with --url")
+				}
+				fields["source_url"] = sourceURL
+			}
+			if sync {
+				fields["sync"] = "true"
+			}
+			var (
+				jobID    string
+				finished bool
+			)
+			if sourceURL != "" {
+				jobID, finished, err = jobCreateFromURL(args[0], fields)
+			} else {
+				jobID, finished, err = jobCreate(args[0], args[1:], fields)
+			}
+			if err != nil || finished || !wait {
+				return err
+			}
+			if jobID == "" {
+				return fmt.Errorf("the server did not return a job ID to wait for")
+			}
+			job, body, err := awaitJob(jobID, interval, 0)
+			if err != nil {
+				return err
+			}
+			if quiet {
+				// The ID was printed when the job was created
+				return stateError([]JobStatus{job})
+			}
+			return printFinalStatus(job, body)
+		},
+	}
+	cmd.Flags().StringVar(&sourceURL, "url", "", "Have the server fetch the data from an http(s):// or s3:// URL instead of uploading a file")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the job to finish and exit by its final state")
+	cmd.Flags().BoolVar(&sync, "sync", false, "Have the server process the job before replying, and exit by its final state if it finishes in time")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "Polling interval for --wait")
+	flags.register(cmd)
+	return cmd
+}
+
+func cmdJobCreateAll() *cobra.Command {
+	var flags jobCreateFlags
+	var pattern string
+	var wait bool
+	var interval time.Duration
+	cmd := &cobra.Command{
+		Use:   "create-all <model_id> <dir>",
+		Short: "Create one job per matching file in a directory",
+		Long: "Upload every file in dir matching --glob as its own job and print the job IDs.\n" +
+			"A failed upload does not stop the rest; the command exits 1 if any upload failed.\n" +
+			"With --wait, it otherwise exits by the worst final state, as job wait does.",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeModelArg(cobra.ShellCompDirectiveFilterDirs),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return jobCreate(args[0], args[1])
+			fields, err := flags.fields(cmd)
+			if err != nil {
+				return err
+			}
+			return jobCreateAll(args[0], args[1], pattern, fields, wait, interval)
 		},
 	}
+	cmd.Flags().StringVar(&pattern, "glob", "*.csv", "Only upload files whose name matches this pattern")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for every created job to finish")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "Polling interval for --wait")
+	flags.register(cmd)
+	return cmd
 }
 
 func cmdJobStatus() *cobra.Command {
-	return &cobra.Command{
-		Use:   "status <job_id>",
+	var watch bool
+	var interval time.Duration
+	cmd := &cobra.Command{
+		Use:   "status <job_id>...",
 		Short: "Job status",
-		Args:  cobra.ExactArgs(1),
+		Long: "Show the status of one or more jobs. Several jobs are fetched in one request;\n" +
+			"IDs that do not exist are reported and make the command exit 1. Otherwise it\n" +
+			"exits as job wait does for finished jobs (the worst state among several) and 0\n" +
+			"while they are still running. With --verbose, a single job's throughput, Kafka\n" +
+			"write latency and where processing time went are shown too.",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeJobArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case len(args) > 1 && watch:
+				return jobWatchMany(args, interval)
+			case len(args) > 1:
+				return jobStatusMany(args)
+			case watch:
+				return jobWatch(args[0], interval)
+			case verbose:
+				return jobStatusVerbose(args[0])
+			}
 			return jobStatus(args[0])
 		},
 	}
+	cmd.Flags().BoolVar(&watch, "watch", false, "Refresh the progress bar (or, for several jobs, the table) until the jobs finish")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "Refresh interval for --watch")
+	return cmd
 }
 
 func cmdJobCancel() *cobra.Command {
-	return &cobra.Command{
-		Use:   "cancel <job_id>",
-		Short: "Cancel job",
-		Args:  cobra.ExactArgs(1),
+	var yes bool
+	cmd := &cobra.Command{
+		Use:               "cancel <job_id>",
+		Short:             "Cancel job",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeJobArg,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := confirmJob("Cancel", args[0], yes); err != nil {
+				return err
+			}
 			return jobCancel(args[0])
 		},
 	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+	return cmd
+}
+
+func cmdJobDelete() *cobra.Command {
+	var yes, topics bool
+	cmd := &cobra.Command{
+		Use:               "delete <job_id>",
+		Short:             "Remove a finished job's record",
+		Long:              "Remove a finished job from the server. Running jobs must be cancelled first.\nWith --topics its Kafka topics are deleted too, as job purge does.",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeJobArg,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := confirmJob("Delete", args[0], yes); err != nil {
+				return err
+			}
+			path := "/jobs/" + args[0]
+			if topics {
+				path += "?topics=true"
+			}
+			return httpDelete(path)
+		},
+	}
+	cmd.Flags().BoolVar(&topics, "topics", false, "Also delete the job's main and DLQ topics")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+	return cmd
 }
 
 func cmdJobRejected() *cobra.Command {
+	var count bool
+	var file string
+	var limit, offset int
+	cmd := &cobra.Command{
+		Use:               "rejected <job_id>",
+		Short:             "List rejected rows",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeJobArg,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if count {
+				return jobRejectedCount(args[0])
+			}
+			if file != "" {
+				format := outputFormat
+				if !cmd.Flags().Changed("output") {
+					format = "csv"
+				}
+				return jobRejectedDownload(args[0], file, format)
+			}
+			return jobRejected(args[0], limit, offset)
+		},
+	}
+	cmd.Flags().BoolVar(&count, "count", false, "Print only the number of rejected rows")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Show at most this many rows (0 for all)")
+	cmd.Flags().IntVar(&offset, "offset", 0, "Skip this many rows")
+	cmd.Flags().StringVar(&file, "file", "", "Save every rejected row to this path, as CSV unless --output is json or table")
+	return cmd
+}
+
+func cmdJobRetry() *cobra.Command {
+	return &cobra.Command{
+		Use:               "retry <job_id>",
+		Short:             "Reprocess a finished job's rejected rows as a new job",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeJobArg,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return httpPost("/jobs/"+args[0]+"/retry", nil)
+		},
+	}
+}
+
+func cmdJobPause() *cobra.Command {
+	return &cobra.Command{
+		Use:               "pause <job_id>",
+		Short:             "Pause a running job after its current row",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeJobArg,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return httpPost("/jobs/"+args[0]+"/pause", nil)
+		},
+	}
+}
+
+func cmdJobResume() *cobra.Command {
 	return &cobra.Command{
-		Use:   "rejected <job_id>",
-		Short: "List rejected rows",
-		Args:  cobra.ExactArgs(1),
+		Use:               "resume <job_id>",
+		Short:             "Resume a paused job where it stopped",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeJobArg,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return httpPost("/jobs/"+args[0]+"/resume", nil)
+		},
+	}
+}
+
+func cmdJobPurge() *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:               "purge <job_id>",
+		Short:             "Delete a finished job and its Kafka topics",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeJobArg,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return jobRejected(args[0])
+			if err := confirmJob("Purge", args[0], yes); err != nil {
+				return err
+			}
+			return httpDelete("/jobs/" + args[0] + "/topics")
+		},
+	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+	return cmd
+}
+
+func cmdJobWait() *cobra.Command {
+	var interval, timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "wait <job_id>",
+		Short: "Wait for a job to finish",
+		Long: "Poll a job until it reaches SUCCESS, PARTIAL_SUCCESS, FAILED or CANCELLED.\n" +
+			"Exits 0 on SUCCESS, 2 on PARTIAL_SUCCESS, 3 on FAILED, 4 on CANCELLED and 5 on timeout.",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeJobArg,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return jobWait(args[0], interval, timeout)
+		},
+	}
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "Polling interval")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Give up after this long (0 waits forever)")
+	return cmd
+}
+
+// ---------------- Job formatting functions ----------------
+
+func jobList(tags []string) error {
+	path := "/jobs"
+	if len(tags) > 0 {
+		path += "?" + url.Values{"tag": tags}.Encode()
+	}
+	responseBody, err := apiGet(path)
+	if err != nil {
+		return err
+	}
+
+	var jobs []JobStatus
+	if err := json.Unmarshal(responseBody, &jobs); err != nil {
+		return err
+	}
+	return printOutput(responseBody,
+		func() {
+			if len(jobs) == 0 {
+				fmt.Println("no jobs")
+				return
+			}
+			printJobTable(jobs)
+		},
+		func() [][]string { return jobCSVRecords(jobs) })
+}
+
+func jobStatus(jobID string) error {
+	job, responseBody, err := fetchJob(jobID)
+	if err != nil {
+		return err
+	}
+	if err := printOutput(responseBody,
+		func() {
+			printJobTable([]JobStatus{job})
+			printJobNotes([]JobStatus{job})
+			printJobInput(job)
+			printRejectionSummary(job)
+		},
+		func() [][]string { return jobCSVRecords([]JobStatus{job}) }); err != nil {
+		return err
+	}
+	return stateError([]JobStatus{job})
+}
+
+// jobStatusMany prints the status of several jobs from one bulk request.
+func jobStatusMany(jobIDs []string) error {
+	list, notFound, body, err := fetchJobs(jobIDs)
+	if err != nil {
+		return err
+	}
+	if err := printOutput(body,
+		func() {
+			printJobTable(list)
+			printJobNotes(list)
+		},
+		func() [][]string { return jobCSVRecords(list) }); err != nil {
+		return err
+	}
+	if err := notFoundError(notFound); err != nil {
+		return err
+	}
+	return stateError(list)
+}
+
+// jobWatchMany redraws the job table every interval until every job is
+// terminal or the user interrupts it.
+func jobWatchMany(jobIDs []string, interval time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		list, notFound, _, err := fetchJobs(jobIDs)
+		if err != nil {
+			return err
+		}
+		if !quiet {
+			fmt.Print("\033[H\033[2J") // clear screen
+			printJobTable(list)
+			printJobNotes(list)
+		}
+		done := true
+		for _, job := range list {
+			if !isTerminal(job.State) {
+				done = false
+			}
+		}
+		if done {
+			if err := notFoundError(notFound); err != nil {
+				return err
+			}
+			return stateError(list)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchJobs returns the jobs named by jobIDs, in order, and the IDs that
+// do not exist, using a single POST /jobs/status request.
+func fetchJobs(jobIDs []string) (list []JobStatus, notFound []string, body []byte, err error) {
+	payload, _ := json.Marshal(jobIDs)
+	body, err = apiSend("POST", "/jobs/status", payload)
+	if err != nil {
+		return nil, nil, body, err
+	}
+	var res struct {
+		Jobs     []JobStatus `json:"jobs"`
+		NotFound []string    `json:"not_found"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, nil, body, err
+	}
+	return res.Jobs, res.NotFound, body, nil
+}
+
+func notFoundError(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return &exitError{code: 1, msg: "jobs not found: " + strings.Join(ids, ", ")}
+}
+
+// fetchJob returns the decoded job along with the raw response body.
+func fetchJob(jobID string) (JobStatus, []byte, error) {
+	var job JobStatus
+	body, err := apiGet("/jobs/" + jobID)
+	if err != nil {
+		return job, body, err
+	}
+	if err := json.Unmarshal(body, &job); err != nil {
+		return job, body, err
+	}
+	return job, body, nil
+}
+
+func isTerminal(state string) bool {
+	switch state {
+	case "SUCCESS", "PARTIAL_SUCCESS", "FAILED", "CANCELLED":
+		return true
+	}
+	return false
+}
+
+// stateExitCode maps a terminal job state onto the process exit code.
+func stateExitCode(state string) int {
+	switch state {
+	case "SUCCESS":
+		return 0
+	case "PARTIAL_SUCCESS":
+		return 2
+	case "FAILED":
+		return 3
+	case "CANCELLED":
+		return 4
+	}
+	return 1
+}
+
+// stateError returns an exitError carrying the highest stateExitCode among
+// the terminal jobs, or nil if each of them ended SUCCESS. Jobs that are
+// still running do not count.
+func stateError(jobs []JobStatus) error {
+	code := 0
+	var unsuccessful []string
+	for _, job := range jobs {
+		if !isTerminal(job.State) || job.State == "SUCCESS" {
+			continue
+		}
+		unsuccessful = append(unsuccessful, job.JobID+" "+job.State)
+		code = max(code, stateExitCode(job.State))
+	}
+	switch {
+	case code == 0:
+		return nil
+	case len(jobs) == 1:
+		return &exitError{code: code, msg: "job " + jobs[0].JobID + " finished with state " + jobs[0].State}
+	}
+	return &exitError{code: code, msg: fmt.Sprintf("%d of %d jobs did not succeed: %s", len(unsuccessful), len(jobs), strings.Join(unsuccessful, ", "))}
+}
+
+func jobWait(jobID string, interval, timeout time.Duration) error {
+	job, body, err := awaitJob(jobID, interval, timeout)
+	if err != nil {
+		return err
+	}
+	return printFinalStatus(job, body)
+}
+
+// awaitJob polls a job every interval until it is terminal, giving up
+// with exit code 5 after timeout unless that is 0.
+func awaitJob(jobID string, interval, timeout time.Duration) (JobStatus, []byte, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for {
+		job, body, err := fetchJob(jobID)
+		if err != nil {
+			return job, body, err
+		}
+		if isTerminal(job.State) {
+			return job, body, nil
+		}
+		if !deadline.IsZero() && time.Now().Add(interval).After(deadline) {
+			return job, body, &exitError{code: 5, msg: fmt.Sprintf("timed out after %s waiting for job %s (state %s)", timeout, jobID, job.State)}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// printFinalStatus prints a finished job and returns its stateError.
+func printFinalStatus(job JobStatus, body []byte) error {
+	if err := printOutput(body,
+		func() {
+			printJobTable([]JobStatus{job})
+			printJobInput(job)
+			printRejectionSummary(job)
 		},
+		func() [][]string { return jobCSVRecords([]JobStatus{job}) }); err != nil {
+		return err
+	}
+	return stateError([]JobStatus{job})
+}
+
+// jobWatch redraws a single status line in place until the job reaches a
+// terminal state or the user interrupts it. It follows the server's event
+// stream and falls back to polling every interval if that is unavailable.
+func jobWatch(jobID string, interval time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Quietly the watch only waits for the job to finish
+	if !quiet {
+		fmt.Print("\033[?25l") // hide cursor
+		defer fmt.Print("\033[?25h\n")
+	}
+
+	// An interrupted watch exits 0; a finished one by the final state
+	var last JobStatus
+	render := func(job JobStatus) {
+		last = job
+		if quiet {
+			return
+		}
+		total := job.Totals.Rows
+		if (job.State == "RUNNING" || job.State == "PAUSED") && job.Totals.Expected > 0 {
+			total = job.Totals.Expected
+		}
+		fmt.Printf("\r\033[K%s %-15s %s %s/%s ok, %s errors",
+			job.JobID, job.State, createProgressBar(job),
+			formatNumber(job.Totals.OK), formatNumber(total), formatNumber(job.Totals.Errors))
+		if job.ETASeconds != nil {
+			fmt.Printf(", ETA %s", time.Duration(*job.ETASeconds)*time.Second)
+		}
+	}
+	if watchEvents(ctx, jobID, render) {
+		return stateError([]JobStatus{last})
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, _, err := fetchJob(jobID)
+		if err != nil {
+			return err
+		}
+		render(job)
+		if isTerminal(job.State) {
+			return stateError([]JobStatus{job})
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchEvents renders each status event from GET /jobs/{id}/events until
+// the job is terminal or ctx is cancelled. It returns false when the stream
+// could not be used to the end, so the caller can fall back to polling.
+func watchEvents(ctx context.Context, jobID string, render func(JobStatus)) bool {
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL+"/jobs/"+jobID+"/events", nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	prepareRequest(req)
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return ctx.Err() != nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var job JobStatus
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			continue
+		}
+		render(job)
+		if isTerminal(job.State) {
+			return true
+		}
+	}
+	return ctx.Err() != nil
+}
+
+// jobCreateFromURL creates a job whose data the server downloads itself
+// and returns its ID, and whether it finished before the server replied
+// (see finishedJob).
+func jobCreateFromURL(modelID string, fields map[string]string) (string, bool, error) {
+	payload := map[string]interface{}{"model_id": modelID}
+	for k, v := range fields {
+		payload[k] = v
+	}
+	for _, name := range []string{"strict_columns", "create_topic", "exactly_once", "trim_space", "skip_blank_lines"} {
+		if v, ok := fields[name]; ok {
+			payload[name] = v == "true"
+		}
+	}
+	if v, ok := fields["rate_limit"]; ok {
+		payload["rate_limit"], _ = strconv.Atoi(v)
+	}
+	if v, ok := fields["retention_ms"]; ok {
+		payload["retention_ms"], _ = strconv.ParseInt(v, 10, 64)
+	}
+	if v, ok := fields["max_errors"]; ok {
+		payload["max_errors"], _ = strconv.Atoi(v)
+	}
+	if v, ok := fields["max_error_rate"]; ok {
+		payload["max_error_rate"], _ = strconv.ParseFloat(v, 64)
+	}
+	if v, ok := fields["tags"]; ok {
+		tags := map[string]string{}
+		for _, pair := range strings.Split(v, ",") {
+			key, value, _ := strings.Cut(pair, "=")
+			tags[key] = value
+		}
+		payload["tags"] = tags
+	}
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", apiURL+"/jobs", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	// Nothing is created unless the request is accepted, so 5xx is retryable
+	responseBody, err := doRequestRetry(apiClient, req, true)
+	if job, ok := finishedJob(responseBody, err); ok {
+		return job.JobID, true, printSyncedJob(job, responseBody)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if quiet {
+		return createdJobID(responseBody), false, printIDs(responseBody)
+	}
+	os.Stdout.Write(responseBody)
+	return createdJobID(responseBody), false, nil
+}
+
+// createdJobID returns the job_id of a POST /jobs response.
+func createdJobID(body []byte) string {
+	var created struct {
+		JobID string `json:"job_id"`
+	}
+	_ = json.Unmarshal(body, &created)
+	return created.JobID
+}
+
+// jobCreate uploads every file as a "file" part of one job, fields being
+// extra form values, and returns the job's ID and whether it finished
+// before the server replied (see finishedJob).
+func jobCreate(modelID string, filePaths []string, fields map[string]string) (string, bool, error) {
+	responseBody, err := uploadJob(modelID, filePaths, fields)
+	if job, ok := finishedJob(responseBody, err); ok {
+		return job.JobID, true, printSyncedJob(job, responseBody)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if quiet {
+		return createdJobID(responseBody), false, printIDs(responseBody)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		// If it's not JSON, just print as is
+		fmt.Print(string(responseBody))
+		return "", false, nil
+	}
+
+	// Output JSON for test compatibility
+	jsonOutput, _ := json.Marshal(result)
+	fmt.Println(string(jsonOutput))
+
+	return createdJobID(responseBody), false, nil
+}
+
+// finishedJob returns the job in a POST /jobs response that holds its
+// final status, as a sync request gets when the job finishes in time: with
+// 200 or 207, or with a 422 error for FAILED and CANCELLED. A plain
+// {job_id} reply, or any other error, reports false.
+func finishedJob(body []byte, err error) (JobStatus, bool) {
+	var aerr *apiError
+	if err != nil && (!errors.As(err, &aerr) || aerr.Status != http.StatusUnprocessableEntity) {
+		return JobStatus{}, false
+	}
+	var job JobStatus
+	if json.Unmarshal(body, &job) != nil || !isTerminal(job.State) {
+		return JobStatus{}, false
+	}
+	return job, true
+}
+
+// printSyncedJob prints a job that finished within a sync request as job
+// wait would, and returns its stateError.
+func printSyncedJob(job JobStatus, body []byte) error {
+	if quiet {
+		fmt.Println(job.JobID)
+		return stateError([]JobStatus{job})
+	}
+	return printFinalStatus(job, body)
+}
+
+// createAllResult is one file of a job create-all run.
+type createAllResult struct {
+	File  string `json:"file"`
+	JobID string `json:"job_id,omitempty"`
+	State string `json:"state,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// jobCreateAll uploads every file in dir matching pattern as its own job.
+// Upload failures are recorded and the remaining files still go up.
+func jobCreateAll(modelID, dir, pattern string, fields map[string]string, wait bool, interval time.Duration) error {
+	matches, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return fmt.Errorf("invalid --glob %q: %w", pattern, err)
+	}
+	var files []string
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.Mode().IsRegular() {
+			files = append(files, m)
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files in %s match %q", dir, pattern)
+	}
+
+	results := make([]createAllResult, len(files))
+	failed := 0
+	for i, file := range files {
+		results[i].File = filepath.Base(file)
+		body, err := uploadJob(modelID, []string{file}, fields)
+		var created struct {
+			JobID string `json:"job_id"`
+		}
+		if err == nil {
+			err = json.Unmarshal(body, &created)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", results[i].File, err)
+			continue
+		}
+		results[i].JobID = created.JobID
+	}
+
+	unsuccessful := 0
+	if wait {
+		unsuccessful = waitAll(results, interval)
+	}
+
+	body, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	body = append(body, '\n')
+	err = printOutput(body, func() {
+		printCreateAllTable(results)
+		fmt.Printf("\n%d created, %d failed", len(files)-failed, failed)
+		if wait {
+			fmt.Printf(", %d did not succeed", unsuccessful)
+		}
+		fmt.Println()
+	}, func() [][]string {
+		records := [][]string{{"file", "job", "state", "error"}}
+		for _, r := range results {
+			records = append(records, []string{r.File, r.JobID, r.State, r.Error})
+		}
+		return records
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case failed > 0:
+		return &exitError{code: 1, msg: fmt.Sprintf("%d of %d uploads failed", failed, len(files))}
+	case unsuccessful > 0:
+		// As for job wait, the worst final state sets the exit code
+		code := 0
+		for _, r := range results {
+			if r.JobID != "" && r.State != "SUCCESS" {
+				code = max(code, stateExitCode(r.State))
+			}
+		}
+		return &exitError{code: code, msg: fmt.Sprintf("%d of %d jobs did not succeed", unsuccessful, len(files))}
+	}
+	return nil
+}
+
+// waitAll polls the created jobs until each is terminal, recording their
+// final states, and returns how many did not end SUCCESS.
+func waitAll(results []createAllResult, interval time.Duration) int {
+	for {
+		var ids []string
+		for _, r := range results {
+			if r.JobID != "" && !isTerminal(r.State) {
+				ids = append(ids, r.JobID)
+			}
+		}
+		if len(ids) == 0 {
+			break
+		}
+		list, notFound, _, err := fetchJobs(ids)
+		states := map[string]string{}
+		for _, job := range list {
+			states[job.JobID] = job.State
+		}
+		pending := 0
+		for i := range results {
+			r := &results[i]
+			if indexOf(ids, r.JobID) < 0 {
+				continue
+			}
+			switch {
+			case err != nil:
+				r.State, r.Error = "UNKNOWN", err.Error()
+			case indexOf(notFound, r.JobID) >= 0:
+				r.State, r.Error = "UNKNOWN", "job not found"
+			default:
+				r.State = states[r.JobID]
+				if !isTerminal(r.State) {
+					pending++
+				}
+			}
+		}
+		if pending == 0 {
+			break
+		}
+		time.Sleep(interval)
+	}
+	unsuccessful := 0
+	for _, r := range results {
+		if r.JobID != "" && r.State != "SUCCESS" {
+			unsuccessful++
+		}
+	}
+	return unsuccessful
+}
+
+func printCreateAllTable(results []createAllResult) {
+	width := len("FILE")
+	for _, r := range results {
+		if len(r.File) > width {
+			width = len(r.File)
+		}
+	}
+	fmt.Printf("%-*s %-8s %-15s %s\n", width, "FILE", "JOB", "STATE", "ERROR")
+	fmt.Printf("%s -------- --------------- -----\n", strings.Repeat("-", width))
+	for _, r := range results {
+		fmt.Printf("%-*s %-8s %-15s %s\n", width, r.File, r.JobID, r.State, r.Error)
+	}
+}
+
+// uploadJob posts the files as one job and returns the API response.
+func uploadJob(modelID string, filePaths []string, fields map[string]string) ([]byte, error) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	_ = w.WriteField("model_id", modelID)
+	for k, v := range fields {
+		_ = w.WriteField(k, v)
+	}
+	for _, filePath := range filePaths {
+		if err := addFormFile(w, filePath); err != nil {
+			return nil, err
+		}
 	}
-}
+	w.Close()
 
-// ---------------- Job formatting functions ----------------
+	req, _ := http.NewRequest("POST", apiURL+"/jobs", body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	// The upload only creates a job once it is accepted, so 5xx is retryable
+	return doRequestRetry(uploadClient, req, true)
+}
 
-func jobList() error {
-	resp, err := http.Get(apiURL + "/jobs")
+func addFormFile(w *multipart.Writer, filePath string) error {
+	fw, err := w.CreateFormFile("file", filepath.Base(filePath))
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(fw, f)
+	return err
+}
 
-	// Read response body and output JSON for test compatibility
-	responseBody, err := io.ReadAll(resp.Body)
+func jobCancel(jobID string) error {
+	req, _ := http.NewRequest("POST", apiURL+"/jobs/"+jobID+"/cancel", nil)
+	responseBody, err := doRequest(req)
 	if err != nil {
 		return err
 	}
+	if quiet {
+		return printIDs(responseBody)
+	}
+
+	// Try to parse as JSON and output for test compatibility
+	var result map[string]interface{}
+	if err := json.Unmarshal(responseBody, &result); err == nil {
+		jsonOutput, _ := json.Marshal(result)
+		fmt.Println(string(jsonOutput))
+	} else {
+		// If not JSON, just print as is
+		fmt.Print(string(responseBody))
+	}
 
-	fmt.Print(string(responseBody))
 	return nil
 }
 
-func jobStatus(jobID string) error {
-	resp, err := http.Get(apiURL + "/jobs/" + jobID)
+func jobRejected(jobID string, limit, offset int) error {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		q.Set("offset", strconv.Itoa(offset))
+	}
+	path := "/jobs/" + jobID + "/rejected"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	responseBody, err := apiGet(path)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
+	var page struct {
+		Rows   []RejectedRow `json:"rows"`
+		Total  int           `json:"total"`
+		Offset int           `json:"offset"`
+	}
+	if err := json.Unmarshal(responseBody, &page); err != nil {
 		return err
 	}
-
-	// Output JSON for test compatibility
-	fmt.Print(string(responseBody))
-	return nil
+	return printOutput(responseBody,
+		func() {
+			if len(page.Rows) == 0 {
+				fmt.Println("no rejected rows")
+				return
+			}
+			printRejectedTable(page.Rows)
+			if len(page.Rows) < page.Total {
+				fmt.Printf("\nrows %d-%d of %s\n", page.Offset+1, page.Offset+len(page.Rows), formatNumber(page.Total))
+			}
+		},
+		func() [][]string { return rejectedCSVRecords(page.Rows) })
 }
 
-func jobCreate(modelID, filePath string) error {
-	body := &bytes.Buffer{}
-	w := multipart.NewWriter(body)
-	_ = w.WriteField("model_id", modelID)
-	fw, err := w.CreateFormFile("file", filepath.Base(filePath))
+// jobRejectedDownload streams the server's export of the DLQ to path: its
+// CSV, its NDJSON, or the NDJSON rendered as the rejected table.
+func jobRejectedDownload(jobID, path, format string) error {
+	var download func(io.Writer) error
+	switch format {
+	case "csv":
+		download = func(w io.Writer) error { return apiDownload("/jobs/"+jobID+"/rejected.csv", w) }
+	case "json":
+		download = func(w io.Writer) error { return apiDownload("/jobs/"+jobID+"/rejected.ndjson", w) }
+	case "table":
+		download = func(w io.Writer) error { return downloadRejectedTable(jobID, w) }
+	default:
+		return fmt.Errorf("--file saves csv, json or table, not %s", format)
+	}
+	f, err := os.Create(path)
 	if err != nil {
 		return err
 	}
-	f, err := os.Open(filePath)
-	if err != nil {
+	if err := download(f); err != nil {
+		f.Close()
+		os.Remove(path)
 		return err
 	}
-	defer f.Close()
-	if _, err = io.Copy(fw, f); err != nil {
+	if err := f.Close(); err != nil {
 		return err
 	}
-	w.Close()
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "rejected rows saved to %s\n", path)
+	}
+	return nil
+}
 
-	req, _ := http.NewRequest("POST", apiURL+"/jobs", body)
-	req.Header.Set("Content-Type", w.FormDataContentType())
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+// downloadRejectedTable writes the rejected table to w one row at a time,
+// as the rows arrive from the server's NDJSON export.
+func downloadRejectedTable(jobID string, w io.Writer) error {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := apiDownload("/jobs/"+jobID+"/rejected.ndjson", pw)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+	dec := json.NewDecoder(pr)
+	for n := 0; ; n++ {
+		var row RejectedRow
+		err := dec.Decode(&row)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// A failed download ends the pipe with its own error
+			pr.Close()
+			<-done
+			return err
+		}
+		if n == 0 {
+			writeRejectedHeader(w)
+		}
+		writeRejectedRow(w, row)
 	}
-	defer resp.Body.Close()
+	return <-done
+}
 
-	// Read the response body first
-	responseBody, err := io.ReadAll(resp.Body)
+func jobRejectedCount(jobID string) error {
+	responseBody, err := apiGet("/jobs/" + jobID + "/rejected/count")
 	if err != nil {
 		return err
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Count int64 `json:"count"`
+	}
 	if err := json.Unmarshal(responseBody, &result); err != nil {
-		// If it's not JSON, just print as is
-		fmt.Print(string(responseBody))
-		return nil
+		return err
 	}
+	return printOutput(responseBody,
+		func() { fmt.Println(formatNumber(int(result.Count))) },
+		func() [][]string {
+			return [][]string{{"count"}, {strconv.FormatInt(result.Count, 10)}}
+		})
+}
 
-	// Output JSON for test compatibility
-	jsonOutput, _ := json.Marshal(result)
-	fmt.Println(string(jsonOutput))
+// ---------------- Output formatting functions ----------------
 
+// printOutput writes an API response body in the selected --output format,
+// or with --quiet only the IDs in it.
+// Resources without a table or CSV renderer pass nil and fall back to JSON.
+func printOutput(body []byte, table func(), csvRecords func() [][]string) error {
+	if quiet {
+		return printIDs(body)
+	}
+	switch outputFormat {
+	case "yaml":
+		return printYAML(body)
+	case "table":
+		if table != nil {
+			table()
+			return nil
+		}
+	case "csv":
+		if csvRecords != nil {
+			w := csv.NewWriter(os.Stdout)
+			if err := w.WriteAll(csvRecords()); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+	fmt.Print(string(body))
 	return nil
 }
 
-func jobCancel(jobID string) error {
-	req, _ := http.NewRequest("DELETE", apiURL+"/jobs/"+jobID, nil)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+func printYAML(body []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	// Read response body
-	responseBody, err := io.ReadAll(resp.Body)
+	out, err := yaml.Marshal(v)
 	if err != nil {
 		return err
 	}
-
-	// Try to parse as JSON and output for test compatibility
-	var result map[string]interface{}
-	if err := json.Unmarshal(responseBody, &result); err == nil {
-		jsonOutput, _ := json.Marshal(result)
-		fmt.Println(string(jsonOutput))
-	} else {
-		// If not JSON, just print as is
-		fmt.Print(string(responseBody))
-	}
-
+	fmt.Print(string(out))
 	return nil
 }
 
-func jobRejected(jobID string) error {
-	resp, err := http.Get(apiURL + "/jobs/" + jobID + "/rejected")
-	if err != nil {
-		return err
+// jobCSVRecords returns the job table columns without padding.
+func jobCSVRecords(jobs []JobStatus) [][]string {
+	records := [][]string{{"job", "model", "state", "total", "ok", "errors", "progress", "waiting", "processing"}}
+	for _, job := range jobs {
+		records = append(records, []string{
+			job.JobID,
+			getModelName(job.ModelID),
+			job.State,
+			strconv.Itoa(job.Totals.Rows),
+			strconv.Itoa(job.Totals.OK),
+			strconv.Itoa(job.Totals.Errors),
+			fmt.Sprintf("%.0f", jobPercent(job)),
+			strings.TrimSpace(formatDuration(job.Timings.WaitingMS)),
+			strings.TrimSpace(formatDuration(job.Timings.ProcessingMS)),
+		})
 	}
-	defer resp.Body.Close()
+	return records
+}
 
-	// Read response body and output JSON for test compatibility
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
+// rejectedCSVRecords returns the rejected table columns without padding.
+func rejectedCSVRecords(rows []RejectedRow) [][]string {
+	records := [][]string{{"row", "event_id", "column", "type", "error", "observed", "message", "expected", "file"}}
+	for _, row := range rows {
+		eventID, column, errorType, code, observed, message := parseErrorDetails(row)
+		records = append(records, []string{
+			strconv.Itoa(row.RowNumber), eventID, column, errorType, code, observed, message, row.ExpectedType, row.SourceFile,
+		})
 	}
-
-	fmt.Print(string(responseBody))
-	return nil
+	return records
 }
 
 // ---------------- Table formatting functions ----------------
@@ -378,95 +1987,202 @@ func printJobTable(jobs []JobStatus) {
 	}
 }
 
+// printJobNotes lists skipped duplicates and warnings below a job table.
+func printJobNotes(jobs []JobStatus) {
+	var notes []string
+	for _, job := range jobs {
+		if job.Totals.Duplicates > 0 {
+			notes = append(notes, fmt.Sprintf("%s: %s duplicate rows skipped", job.JobID, formatNumber(job.Totals.Duplicates)))
+		}
+		for _, w := range job.Warnings {
+			notes = append(notes, fmt.Sprintf("%s: warning: %s", job.JobID, w))
+		}
+	}
+	if len(notes) > 0 {
+		fmt.Println()
+		fmt.Println(strings.Join(notes, "\n"))
+	}
+}
+
+// printJobInput shows what a job ingested: the size and SHA-256 of its
+// file, or of each file of a multi-file job, and where the server fetched
+// it from. Retry jobs have neither.
+func printJobInput(job JobStatus) {
+	if job.Checksum == "" && len(job.Files) == 0 {
+		return
+	}
+	fmt.Println()
+	if job.SourceURL != "" {
+		fmt.Printf("Source:    %s\n", job.SourceURL)
+	}
+	if len(job.Files) == 0 {
+		fmt.Printf("Size:      %s bytes\n", formatNumber(int(job.Size)))
+		fmt.Printf("SHA-256:   %s\n", job.Checksum)
+		return
+	}
+	fmt.Println("FILE                           BYTES        SHA-256")
+	fmt.Println("------------------------------ ------------ ----------------------------------------------------------------")
+	for _, f := range job.Files {
+		fmt.Printf("%-30s %12s %s\n", clip(f.Name, 30), formatNumber(int(f.Size)), f.Checksum)
+	}
+	fmt.Printf("%-30s %12s\n", "total", formatNumber(int(job.Size)))
+}
+
+// printRejectionSummary breaks a job's rejected rows down by reason and
+// column, most frequent first.
+func printRejectionSummary(job JobStatus) {
+	if len(job.RejectionSummary) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(job.RejectionSummary))
+	for key := range job.RejectionSummary {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := job.RejectionSummary[keys[i]], job.RejectionSummary[keys[j]]
+		if a != b {
+			return a > b
+		}
+		return keys[i] < keys[j]
+	})
+
+	fmt.Println()
+	fmt.Println("REASON                   COLUMN               ROWS       SHARE")
+	fmt.Println("------------------------ -------------------- ---------- ------")
+	for _, key := range keys {
+		reason, column, _ := strings.Cut(key, ":")
+		n := job.RejectionSummary[key]
+		share := ""
+		if job.Totals.Errors > 0 {
+			share = fmt.Sprintf("%5.1f%%", float64(n)/float64(job.Totals.Errors)*100)
+		}
+		fmt.Printf("%-24s %-20s %10s %s\n", reason, clip(column, 20), formatNumber(n), share)
+	}
+}
+
 func printRejectedTable(rejectedRows []RejectedRow) {
 	if len(rejectedRows) == 0 {
 		return
 	}
+	writeRejectedHeader(os.Stdout)
+	for _, row := range rejectedRows {
+		writeRejectedRow(os.Stdout, row)
+	}
+}
 
-	// Header
-	fmt.Println("ROW  EVENT_ID COLUMN      TYPE        ERROR               OBSERVED         MESSAGE")
-	fmt.Println("---- -------- ----------- ----------- ------------------- ---------------- ------------------------------------------------------------------------------------")
+func writeRejectedHeader(w io.Writer) {
+	fmt.Fprintln(w, "ROW  EVENT_ID COLUMN      TYPE        ERROR               OBSERVED         MESSAGE")
+	fmt.Fprintln(w, "---- -------- ----------- ----------- ------------------- ---------------- ------------------------------------------------------------------------------------")
+}
 
-	for i, row := range rejectedRows {
-		rowNum := fmt.Sprintf("%-4d", i+1)
+// writeRejectedRow writes one line of the rejected table. The columns have
+// fixed widths, so rows can be written as they arrive.
+func writeRejectedRow(w io.Writer, row RejectedRow) {
+	rowNum := fmt.Sprintf("%-4d", row.RowNumber)
 
-		// Parse error details from the error message
-		eventID, column, errorType, observed, message := parseErrorDetails(row.Error, row.RawData)
+	// Parse error details from the error message
+	eventID, column, errorType, code, observed, message := parseErrorDetails(row)
 
-		fmt.Printf("%s %-8s %-11s %-11s %-19s %-16s %s\n",
-			rowNum, eventID, column, errorType, errorType, observed, message)
-	}
+	fmt.Fprintf(w, "%s %-8s %-11s %-11s %-19s %-16s %s\n",
+		rowNum, clip(eventID, 8), clip(column, 11), clip(errorType, 11),
+		clip(code, 19), clip(observed, 16), message)
 }
 
-func createProgressBar(job JobStatus) string {
-	if job.Totals.Rows == 0 {
-		return "[-----------------]   0%"
+// clip shortens s to at most n characters, marking the cut with "..". It
+// counts and cuts runes, as fmt pads them, so columns stay aligned and a
+// multi-byte character is never split.
+func clip(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
 	}
+	return string([]rune(s)[:n-2]) + ".."
+}
 
-	percentage := float64(job.Totals.OK) / float64(job.Totals.Rows) * 100
-	progressChars := int(percentage / 100 * 17)
+// progressWidth is the number of cells between a progress bar's brackets.
+const progressWidth = 17
 
-	var bar strings.Builder
-	bar.WriteString("[")
+// createProgressBar draws a job's progress: # for rows done, X for
+// failures and - for what is left, followed by jobPercent.
+func createProgressBar(job JobStatus) string {
+	percentage := jobPercent(job)
+	done := min(int(percentage/100*progressWidth), progressWidth)
 
+	var bar string
 	switch job.State {
 	case "SUCCESS":
-		for i := 0; i < 17; i++ {
-			bar.WriteString("#")
-		}
+		bar = strings.Repeat("#", progressWidth)
 	case "PARTIAL_SUCCESS":
-		for i := 0; i < progressChars; i++ {
-			bar.WriteString("#")
-		}
-		if progressChars < 17 {
-			bar.WriteString("X")
-			progressChars++
-		}
-		for i := progressChars; i < 17; i++ {
-			bar.WriteString("-")
-		}
+		// Keep a cell for the X that marks the rejected rows
+		done = min(done, progressWidth-1)
+		bar = strings.Repeat("#", done) + "X" + strings.Repeat("-", progressWidth-done-1)
 	case "FAILED":
-		for i := 0; i < 17; i++ {
-			bar.WriteString("X")
-		}
+		bar = strings.Repeat("X", progressWidth)
 	case "CANCELLED":
-		cancelledPoint := int(float64(job.Totals.OK+job.Totals.Errors) / float64(job.Totals.Rows) * 17)
-		for i := 0; i < cancelledPoint; i++ {
-			bar.WriteString("#")
-		}
-		for i := cancelledPoint; i < 17; i++ {
-			bar.WriteString("X")
-		}
-	case "RUNNING":
-		for i := 0; i < progressChars; i++ {
-			bar.WriteString("#")
-		}
-		for i := progressChars; i < 17; i++ {
-			bar.WriteString("-")
-		}
-	case "PENDING":
-		for i := 0; i < 17; i++ {
-			bar.WriteString("-")
+		// Rows handled before the cancel, then X for the rest
+		handled := job.Totals.OK + job.Totals.Errors
+		stop := 0
+		if total := max(job.Totals.Expected, job.Totals.Rows, handled); total > 0 {
+			stop = handled * progressWidth / total
 		}
+		bar = strings.Repeat("#", stop) + strings.Repeat("X", progressWidth-stop)
+	case "RUNNING", "PAUSED":
+		bar = strings.Repeat("#", done) + strings.Repeat("-", progressWidth-done)
+	default: // PENDING
+		percentage = 0
+		bar = strings.Repeat("-", progressWidth)
 	}
+	return fmt.Sprintf("%-24s", fmt.Sprintf("[%s] %3.0f%%", bar, percentage))
+}
 
-	bar.WriteString("]")
-
-	// Add percentage
-	if job.State == "PENDING" {
-		bar.WriteString("   0%")
-	} else {
-		bar.WriteString(fmt.Sprintf(" %3.0f%%", percentage))
+// jobPercent returns how far a running job is through the rows the server
+// expects, or otherwise the share of rows produced successfully or skipped
+// as duplicates. Rows rejected before they were counted in Totals.Rows
+// (parse errors) widen the denominator, so the result stays within 0-100.
+// A running or paused job is never shown as complete.
+func jobPercent(job JobStatus) float64 {
+	done := job.Totals.OK + job.Totals.Errors + job.Totals.Duplicates
+	if job.State == "RUNNING" || job.State == "PAUSED" {
+		if job.Totals.Expected > 0 {
+			return min(percentOf(done, job.Totals.Expected), 99)
+		}
+		return min(percentOf(job.Totals.OK+job.Totals.Duplicates, max(job.Totals.Rows, done)), 99)
 	}
+	return percentOf(job.Totals.OK+job.Totals.Duplicates, max(job.Totals.Rows, done))
+}
 
-	return fmt.Sprintf("%-24s", bar.String())
+// percentOf returns n as a percentage of total, clamped to 0-100; 0 when
+// total is not positive.
+func percentOf(n, total int) float64 {
+	if total <= 0 || n <= 0 {
+		return 0
+	}
+	return min(float64(n)/float64(total)*100, 100)
 }
 
+// formatNumber renders n with a thousands separator every three digits.
 func formatNumber(n int) string {
-	if n < 1000 {
-		return strconv.Itoa(n)
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= 3 {
+		return sign + digits
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > len(sign) {
+			b.WriteByte(',')
+		}
+		b.WriteString(digits[i : i+3])
 	}
-	return fmt.Sprintf("%d,%03d", n/1000, n%1000)
+	return b.String()
 }
 
 func formatDuration(ms int64) string {
@@ -479,86 +2195,360 @@ func formatDuration(ms int64) string {
 	return fmt.Sprintf("   %02d:%02d", minutes, seconds)
 }
 
+// modelNames maps model IDs to display names for the life of the command.
+// It is filled from a single GET /models on first use, revalidated against
+// the model cache; nil means not yet loaded.
+var modelNames map[string]string
+
+// getModelName returns the model's name, or its ID if it has none or
+// cannot be found. IDs missing from the cached list (models created since,
+// or a failed listing) are fetched individually and cached as well.
 func getModelName(modelID string) string {
-	// Try to fetch model name from API
-	resp, err := http.Get(apiURL + "/models/" + modelID)
-	if err != nil {
-		return modelID
+	if modelNames == nil {
+		modelNames = map[string]string{}
+		var list []Model
+		if body, err := apiGetCached("/models"); err == nil && json.Unmarshal(body, &list) == nil {
+			for _, m := range list {
+				modelNames[m.ID] = displayName(m)
+			}
+		}
+	}
+	if name, ok := modelNames[modelID]; ok {
+		return name
 	}
-	defer resp.Body.Close()
 
-	var model Model
-	if err := json.NewDecoder(resp.Body).Decode(&model); err != nil {
-		return modelID
+	name := modelID
+	if body, err := apiGetCached("/models/" + modelID); err == nil {
+		var model Model
+		if json.Unmarshal(body, &model) == nil {
+			name = displayName(model)
+		}
 	}
+	modelNames[modelID] = name
+	return name
+}
 
-	if model.Name != "" {
-		return model.Name
+func displayName(m Model) string {
+	if m.Name != "" {
+		return m.Name
 	}
-	return modelID
+	return m.ID
 }
 
-func parseErrorDetails(errorMsg, rawData string) (eventID, column, errorType, observed, message string) {
-	// For demo purposes, create realistic error parsing
-	// In a real implementation, this would parse structured error information
+var (
+	parseColumnRe = regexp.MustCompile(`column (\d+)`)
+	errorCodeRe   = regexp.MustCompile(`^([A-Z][A-Z0-9_]+):`)
+)
+
+// parseErrorDetails maps a rejected row onto the rejected-table columns.
+// Structured fields from the server win; the flat error string is only
+// inspected for rows produced by servers that predate them.
+func parseErrorDetails(row RejectedRow) (eventID, column, errorType, code, observed, message string) {
+	eventID = rowEventID(row.RawData)
+	column = row.Column
+	errorType = row.ErrorType
+	observed = row.ObservedValue
+	message = row.Error
+
+	if errorType == "" {
+		switch {
+		case strings.HasPrefix(message, "Kafka write error: "):
+			errorType = "KAFKA_ERROR"
+		case strings.HasPrefix(message, "JSON marshal error: "):
+			errorType = "SCHEMA_VIOLATION"
+		case strings.Contains(message, "parse error"),
+			strings.Contains(message, "wrong number of fields"),
+			strings.Contains(message, "quote"):
+			errorType = "PARSE_ERROR"
+		default:
+			errorType = "UNKNOWN_ERROR"
+		}
+	}
+
+	if errorType == "PARSE_ERROR" {
+		if column == "" {
+			if m := parseColumnRe.FindStringSubmatch(message); m != nil {
+				column = "col " + m[1]
+			}
+		}
+		if observed == "" {
+			observed = row.RawData
+		}
+	}
+	return eventID, column, errorType, shortErrorCode(errorType, message), observed, message
+}
 
-	if strings.Contains(errorMsg, "parse error") {
-		return "", "data", "PARSE_ERROR", rawData, errorMsg
+// shortErrorCode condenses an error message into the code shown in the
+// ERROR column. Messages of the form "CODE: detail" use CODE directly.
+func shortErrorCode(errorType, message string) string {
+	if m := errorCodeRe.FindStringSubmatch(message); m != nil {
+		return m[1]
 	}
+	switch {
+	case strings.Contains(message, "wrong number of fields"),
+		strings.Contains(message, "fields, expected"):
+		return "FIELD_COUNT"
+	case strings.Contains(message, `bare "`):
+		return "BARE_QUOTE"
+	case strings.Contains(message, `extraneous or missing "`):
+		return "QUOTE_MISMATCH"
+	case errorType == "KAFKA_ERROR":
+		return "WRITE_FAILED"
+	}
+	return errorType
+}
 
-	// Default fallback
-	return "", "unknown", "UNKNOWN_ERROR", rawData, errorMsg
+// rowEventID returns the first field of a raw CSV row, which holds the
+// event ID in the sample schemas. Rows that don't parse yield "".
+func rowEventID(rawData string) string {
+	if rawData == "" {
+		return ""
+	}
+	r := csv.NewReader(strings.NewReader(rawData))
+	r.LazyQuotes = true
+	rec, err := r.Read()
+	if err != nil || len(rec) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(rec[0])
 }
 
 // ---------------- HTTP helpers ----------------
 
-func httpGet(path string) error {
-	resp, err := http.Get(apiURL + path)
+// apiError is the {error,message} envelope the server returns with
+// non-2xx responses.
+type apiError struct {
+	Status    int    `json:"-"`
+	RequestID string `json:"-"`
+	Code      string `json:"error"`
+	Message   string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("%s: %s (HTTP %d)", e.Code, e.Message, e.Status)
+	if e.Code == "" {
+		msg = fmt.Sprintf("HTTP %d: %s", e.Status, e.Message)
+	}
+	if e.RequestID != "" {
+		msg += " [request ID " + e.RequestID + "]"
+	}
+	return msg
+}
+
+func newAPIError(status int, requestID string, body []byte) *apiError {
+	e := &apiError{Status: status, RequestID: requestID}
+	if err := json.Unmarshal(body, e); err != nil || (e.Code == "" && e.Message == "") {
+		e.Code = ""
+		e.Message = strings.TrimSpace(string(body))
+		if e.Message == "" {
+			e.Message = http.StatusText(status)
+		}
+	}
+	return e
+}
+
+// doRequest sends req and returns the response body. Non-2xx responses are
+// returned as an *apiError. Idempotent methods are retried on 5xx too; PUT
+// is not one here, as PUT /models/{id} creates a new version.
+func doRequest(req *http.Request) ([]byte, error) {
+	return doRequestRetry(apiClient, req, idempotent(req.Method))
+}
+
+// doRequestRetry sends req with client, retrying transport failures (and
+// 5xx responses when retry5xx is set) up to --retries times with
+// exponential backoff. POST and DELETE requests are retried only when the
+// connection was never made, since the server may otherwise have acted on
+// them.
+func doRequestRetry(client *http.Client, req *http.Request, retry5xx bool) ([]byte, error) {
+	_, body, err := sendRequest(client, req, retry5xx)
+	return body, err
+}
+
+// sendRequest is doRequestRetry that also returns the response, whose body
+// has been read and closed. A 304 answering If-None-Match is not an error.
+func sendRequest(client *http.Client, req *http.Request, retry5xx bool) (*http.Response, []byte, error) {
+	reqID := prepareRequest(req)
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+			if req.GetBody != nil {
+				b, err := req.GetBody()
+				if err != nil {
+					return nil, nil, err
+				}
+				req.Body = b
+			}
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if attempt < retries && (idempotent(req.Method) || dialFailed(err)) {
+				continue
+			}
+			return nil, nil, requestError(client, err, reqID)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			if attempt < retries && idempotent(req.Method) {
+				continue
+			}
+			return nil, nil, requestError(client, err, reqID)
+		}
+		if resp.StatusCode >= 500 && retry5xx && attempt < retries {
+			continue
+		}
+		if resp.StatusCode == http.StatusNotModified && req.Header.Get("If-None-Match") != "" {
+			return resp, body, nil
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return resp, body, newAPIError(resp.StatusCode, reqID, body)
+		}
+		return resp, body, nil
+	}
+}
+
+// idempotent reports whether sending a request with method twice has the
+// same effect as sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+// dialFailed reports whether err means the request never reached the
+// server: the connection was refused or could not be made. A dial that
+// timed out is not counted, as the client's whole deadline may have passed.
+func dialFailed(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial" && !opErr.Timeout()
+}
+
+// requestError tags a transport failure with the request ID, naming the
+// timeout flag when the client gave up waiting.
+func requestError(client *http.Client, err error, reqID string) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		flag := "--api-timeout"
+		if client == uploadClient {
+			flag = "--upload-timeout"
+		}
+		return fmt.Errorf("no response from %s within %s (%s) [request ID %s]", apiURL, client.Timeout, flag, reqID)
+	}
+	return fmt.Errorf("%w [request ID %s]", err, reqID)
+}
+
+// prepareRequest sets the auth, namespace and correlation headers on req
+// and returns the request ID it was tagged with.
+func prepareRequest(req *http.Request) string {
+	if apiToken != "" {
+		req.Header.Set("Authorization", "Bearer "+apiToken)
+	}
+	if namespace != "" {
+		req.Header.Set("X-Namespace", namespace)
+	}
+	reqID := newRequestID()
+	req.Header.Set("X-Request-ID", reqID)
+	return reqID
+}
+
+// apiDownload copies the body of GET path to dst as it arrives. It is not
+// retried, since part of the body may already have been written.
+func apiDownload(path string, dst io.Writer) error {
+	req, err := http.NewRequest("GET", apiURL+path, nil)
 	if err != nil {
 		return err
 	}
+	reqID := prepareRequest(req)
+	resp, err := uploadClient.Do(req)
+	if err != nil {
+		return requestError(uploadClient, err, reqID)
+	}
 	defer resp.Body.Close()
-	io.Copy(os.Stdout, resp.Body)
-	fmt.Println()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp.StatusCode, reqID, body)
+	}
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		return requestError(uploadClient, err, reqID)
+	}
 	return nil
 }
 
-func httpPost(path string, body []byte) error {
-	resp, err := http.Post(apiURL+path, "application/json", bytes.NewReader(body))
+func apiGet(path string) ([]byte, error) {
+	req, err := http.NewRequest("GET", apiURL+path, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
-	io.Copy(os.Stdout, resp.Body)
-	fmt.Println()
-	return nil
+	return doRequest(req)
 }
 
-func httpPut(path string, body []byte) error {
-	req, _ := http.NewRequest("PUT", apiURL+path, bytes.NewReader(body))
+// apiSend sends a JSON body with method and returns the response body.
+func apiSend(method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, apiURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	return doRequest(req)
+}
+
+func httpGet(path string) error {
+	body, err := apiGet(path)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	io.Copy(os.Stdout, resp.Body)
-	fmt.Println()
-	return nil
+	return printOutput(body, nil, nil)
+}
+
+func httpPost(path string, body []byte) error {
+	req, _ := http.NewRequest("POST", apiURL+path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return printResponse(req)
+}
+
+func httpPut(path string, body []byte) error {
+	req, _ := http.NewRequest("PUT", apiURL+path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return printResponse(req)
 }
 
 func httpDelete(path string) error {
 	req, _ := http.NewRequest("DELETE", apiURL+path, nil)
-	resp, err := http.DefaultClient.Do(req)
+	return printResponse(req)
+}
+
+// printResponse sends req and copies the response body to stdout.
+func printResponse(req *http.Request) error {
+	body, err := doRequest(req)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	io.Copy(os.Stdout, resp.Body)
+	if quiet {
+		return printIDs(body)
+	}
+	os.Stdout.Write(body)
 	fmt.Println()
 	return nil
 }
 
+// newRequestID returns a random correlation ID for the X-Request-ID header.
+func newRequestID() string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, 16)
+	for i := range b {
+		b[i] = letters[rand.Intn(len(letters))]
+	}
+	return string(b)
+}
+
 func getenv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
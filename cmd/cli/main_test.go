@@ -0,0 +1,268 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseErrorDetails(t *testing.T) {
+	tests := []struct {
+		name string
+		row  RejectedRow
+		// want is eventID, column, errorType, code, observed, message
+		want [6]string
+	}{
+		{
+			name: "schema violation",
+			row: RejectedRow{
+				RawData:       "1001,abc,red",
+				Error:         `TYPE_MISMATCH: column 'id' expected integer: "abc" is not an integer`,
+				ErrorType:     "SCHEMA_VIOLATION",
+				Column:        "id",
+				ObservedValue: "abc",
+			},
+			want: [6]string{"1001", "id", "SCHEMA_VIOLATION", "TYPE_MISMATCH", "abc",
+				`TYPE_MISMATCH: column 'id' expected integer: "abc" is not an integer`},
+		},
+		{
+			name: "kafka error from an older server",
+			row: RejectedRow{
+				RawData: "1002,b,blue",
+				Error:   "Kafka write error: leader not available",
+			},
+			want: [6]string{"1002", "", "KAFKA_ERROR", "WRITE_FAILED", "",
+				"Kafka write error: leader not available"},
+		},
+		{
+			name: "malformed csv",
+			row: RejectedRow{
+				RawData: `1003,"c,red`,
+				Error:   `parse error on line 4, column 5: extraneous or missing " in quoted-field`,
+			},
+			want: [6]string{"1003", "col 5", "PARSE_ERROR", "QUOTE_MISMATCH", `1003,"c,red`,
+				`parse error on line 4, column 5: extraneous or missing " in quoted-field`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eventID, column, errorType, code, observed, message := parseErrorDetails(tt.row)
+			got := [6]string{eventID, column, errorType, code, observed, message}
+			if got != tt.want {
+				t.Errorf("parseErrorDetails() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRejectedTableLayout(t *testing.T) {
+	rows := []RejectedRow{
+		{
+			RowNumber: 3, RawData: "1001abcd,x", Column: "timestamp", ErrorType: "SCHEMA_VIOLATION",
+			Error: "TYPE_MISMATCH: column 'timestamp' expected integer", ObservedValue: "yesterday",
+		},
+		{
+			RowNumber: 12, RawData: "1012,ünïcödé", Column: "description_text", ErrorType: "SCHEMA_VIOLATION",
+			Error: "PATTERN_MISMATCH: column 'description_text' does not match", ObservedValue: "ünïcödé ünïcödé ünïcödé",
+		},
+	}
+	var b strings.Builder
+	writeRejectedHeader(&b)
+	for _, row := range rows {
+		writeRejectedRow(&b, row)
+	}
+
+	want := "" +
+		"ROW  EVENT_ID COLUMN      TYPE        ERROR               OBSERVED         MESSAGE\n" +
+		"---- -------- ----------- ----------- ------------------- ---------------- ------------------------------------------------------------------------------------\n" +
+		"3    1001abcd timestamp   SCHEMA_VI.. TYPE_MISMATCH       yesterday        TYPE_MISMATCH: column 'timestamp' expected integer\n" +
+		"12   1012     descripti.. SCHEMA_VI.. PATTERN_MISMATCH    ünïcödé ünïcöd.. PATTERN_MISMATCH: column 'description_text' does not match\n"
+	if got := b.String(); got != want {
+		t.Errorf("rejected table:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestClip(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 8, "short"},
+		{"exactly8", 8, "exactly8"},
+		{"longer than eight", 8, "longer.."},
+		{"ünïcödé", 7, "ünïcödé"},
+		{"ünïcödé!", 7, "ünïcö.."},
+	}
+	for _, tt := range tests {
+		if got := clip(tt.s, tt.n); got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("clip(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{999999, "999,999"},
+		{1000000, "1,000,000"},
+		{1234567890, "1,234,567,890"},
+		{-1234, "-1,234"},
+	}
+	for _, tt := range tests {
+		if got := formatNumber(tt.n); got != tt.want {
+			t.Errorf("formatNumber(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestCreateProgressBar(t *testing.T) {
+	job := func(state string, rows, ok, errs, dups, expected int) JobStatus {
+		var j JobStatus
+		j.State = state
+		j.Totals.Rows, j.Totals.OK, j.Totals.Errors = rows, ok, errs
+		j.Totals.Duplicates, j.Totals.Expected = dups, expected
+		return j
+	}
+	tests := []struct {
+		name string
+		job  JobStatus
+		want string
+	}{
+		{"pending", job("PENDING", 0, 0, 0, 0, 0), "[-----------------]   0%"},
+		{"running", job("RUNNING", 50, 50, 0, 0, 100), "[########---------]  50%"},
+		{"running never complete", job("RUNNING", 100, 100, 0, 0, 100), "[################-]  99%"},
+		{"paused", job("PAUSED", 25, 25, 0, 0, 100), "[####-------------]  25%"},
+		{"success", job("SUCCESS", 10, 10, 0, 0, 10), "[#################] 100%"},
+		{"partial success", job("PARTIAL_SUCCESS", 10, 10, 2, 0, 10), "[##############X--]  83%"},
+		// Every row counted as done would fill the bar; the X keeps the
+		// last cell rather than running past the 17th
+		{"partial success overflow", job("PARTIAL_SUCCESS", 10, 5, 0, 5, 10), "[################X] 100%"},
+		{"failed", job("FAILED", 10, 0, 10, 0, 10), "[XXXXXXXXXXXXXXXXX]   0%"},
+		{"cancelled", job("CANCELLED", 50, 40, 10, 0, 100), "[########XXXXXXXXX]  80%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := createProgressBar(tt.job)
+			if got != tt.want {
+				t.Errorf("createProgressBar() = %q, want %q", got, tt.want)
+			}
+			open, end := strings.Index(got, "["), strings.Index(got, "]")
+			if end-open-1 != progressWidth {
+				t.Errorf("bar %q has %d cells, want %d", got, end-open-1, progressWidth)
+			}
+		})
+	}
+}
+
+func TestJobPercent(t *testing.T) {
+	tests := []struct {
+		name                           string
+		state                          string
+		rows, ok, errs, dups, expected int
+		want                           float64
+	}{
+		{"running against expected rows", "RUNNING", 40, 30, 10, 0, 200, 20},
+		{"running capped below 100", "RUNNING", 200, 200, 0, 0, 200, 99},
+		{"running without an estimate", "RUNNING", 10, 5, 0, 0, 0, 50},
+		{"finished counts duplicates as done", "SUCCESS", 10, 8, 0, 2, 10, 100},
+		{"parse errors widen the denominator", "PARTIAL_SUCCESS", 8, 8, 2, 0, 0, 80},
+		{"nothing read", "PENDING", 0, 0, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		var j JobStatus
+		j.State = tt.state
+		j.Totals.Rows, j.Totals.OK, j.Totals.Errors = tt.rows, tt.ok, tt.errs
+		j.Totals.Duplicates, j.Totals.Expected = tt.dups, tt.expected
+		if got := jobPercent(j); got != tt.want {
+			t.Errorf("%s: jobPercent() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSendRequestRetries(t *testing.T) {
+	var attempts atomic.Int32
+	// The server reads each request, then drops the connection unanswered
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	// A closed listener's address refuses connections
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + ln.Addr().String()
+	ln.Close()
+
+	defer func(n int) { retries = n }(retries)
+	retries = 2
+	for _, tt := range []struct {
+		method string
+		want   int32
+	}{
+		{"GET", 3},
+		{"PUT", 1},
+		{"POST", 1},
+		{"DELETE", 1},
+	} {
+		attempts.Store(0)
+		req, _ := http.NewRequest(tt.method, srv.URL, nil)
+		if _, _, err := sendRequest(&http.Client{}, req, true); err == nil {
+			t.Fatalf("%s: want an error from a dropped connection", tt.method)
+		}
+		if got := attempts.Load(); got != tt.want {
+			t.Errorf("%s after the request was sent: %d attempts, want %d", tt.method, got, tt.want)
+		}
+
+		req, _ = http.NewRequest(tt.method, refused, nil)
+		_, _, err := sendRequest(&http.Client{}, req, true)
+		if err == nil || !dialFailed(err) {
+			t.Errorf("%s to a closed port: got %v, want a dial failure", tt.method, err)
+		}
+	}
+}
+
+func TestDoRequestRetries5xx(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	defer func(n int) { retries = n }(retries)
+	retries = 2
+	for _, tt := range []struct {
+		method string
+		want   int32
+	}{
+		{"GET", 3},
+		{"PUT", 1},
+		{"POST", 1},
+		{"DELETE", 1},
+	} {
+		attempts.Store(0)
+		req, _ := http.NewRequest(tt.method, srv.URL, nil)
+		if _, err := doRequest(req); err == nil {
+			t.Fatalf("%s: want an error from a 503", tt.method)
+		}
+		if got := attempts.Load(); got != tt.want {
+			t.Errorf("%s: %d attempts, want %d", tt.method, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// cachedResponse is a model read kept between commands with the ETag it
+// was served with.
+type cachedResponse struct {
+	ETag string          `json:"etag"`
+	Body json.RawMessage `json:"body"`
+}
+
+// modelCache holds model reads by API URL, namespace and path; nil means
+// not yet loaded.
+var modelCache map[string]cachedResponse
+
+// modelCachePath is where the model cache is kept, or "" if the user has
+// no cache directory.
+func modelCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "batch", "models.json")
+}
+
+// apiGetCached is apiGet for model reads. It sends the ETag of the last
+// copy of path it saw and reuses that copy when the server answers 304
+// Not Modified. The cache is best effort: one that cannot be read or
+// written just means a full download.
+func apiGetCached(path string) ([]byte, error) {
+	if modelCache == nil {
+		modelCache = map[string]cachedResponse{}
+		if data, err := os.ReadFile(modelCachePath()); err == nil {
+			_ = json.Unmarshal(data, &modelCache)
+		}
+	}
+	key := apiURL + " " + namespace + " " + path
+
+	req, err := http.NewRequest("GET", apiURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	cached, ok := modelCache[key]
+	if ok {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	resp, body, err := sendRequest(apiClient, req, true)
+	var apiErr *apiError
+	if ok && errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		delete(modelCache, key)
+		saveModelCache()
+	}
+	if err != nil {
+		return body, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return cached.Body, nil
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" && json.Valid(body) {
+		modelCache[key] = cachedResponse{ETag: etag, Body: body}
+		saveModelCache()
+	}
+	return body, nil
+}
+
+// saveModelCache writes the model cache through a temporary file, so a
+// concurrent command never reads half of it.
+func saveModelCache() {
+	path := modelCachePath()
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(modelCache)
+	if err != nil || os.MkdirAll(filepath.Dir(path), 0o700) != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "models-*.json")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
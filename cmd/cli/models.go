@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/keithchambers/batch-ingestion/internal/rowschema"
+)
+
+// modelImportResult is one model of a model import run.
+type modelImportResult struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Action string `json:"action"` // created, updated, unchanged, skipped or failed
+	Error  string `json:"error,omitempty"`
+}
+
+func cmdModelExport() *cobra.Command {
+	var file string
+	cmd := &cobra.Command{
+		Use:   "export [model_id...]",
+		Short: "Write models as JSON for model import",
+		Long: "Write the id, name, schema, job defaults, column mapping, computed fields and\n" +
+			"null values of the given models, or of every model when none are given, as a\n" +
+			"JSON array to stdout or --file.",
+		ValidArgsFunction: completeModelArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return modelExport(args, file)
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Write the export to this path instead of stdout")
+	return cmd
+}
+
+func cmdModelImport() *cobra.Command {
+	var overwrite bool
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Create models from a model export",
+		Long: "Create each model in an export file with its original id. Models that already exist\n" +
+			"are skipped unless --overwrite is set, which adds a new version when anything in\n" +
+			"the export differs. Exits 1 if any model could not be imported.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return modelImport(args[0], overwrite)
+		},
+	}
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Update models that already exist")
+	return cmd
+}
+
+func modelExport(ids []string, file string) error {
+	var list []Model
+	if len(ids) == 0 {
+		body, err := apiGet("/models")
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return err
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	}
+	for _, id := range ids {
+		body, err := apiGet("/models/" + id)
+		if err != nil {
+			return err
+		}
+		var m Model
+		if err := json.Unmarshal(body, &m); err != nil {
+			return err
+		}
+		list = append(list, m)
+	}
+	if list == nil {
+		list = []Model{}
+	}
+
+	out, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	if file == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	if err := os.WriteFile(file, out, 0o644); err != nil {
+		return err
+	}
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "%d models exported to %s\n", len(list), file)
+	}
+	return nil
+}
+
+// readModelExport parses an export file. A single model object, as printed
+// by model describe, is accepted too.
+func readModelExport(path string) ([]Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []Model
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var m Model
+		if err := json.Unmarshal(trimmed, &m); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		list = []Model{m}
+	} else if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, m := range list {
+		if m.ID == "" || len(m.Schema) == 0 {
+			return nil, fmt.Errorf("%s: model %d needs an id and a schema", path, i+1)
+		}
+	}
+	return list, nil
+}
+
+func modelImport(path string, overwrite bool) error {
+	list, err := readModelExport(path)
+	if err != nil {
+		return err
+	}
+
+	results := make([]modelImportResult, len(list))
+	failed := 0
+	for i, m := range list {
+		results[i] = modelImportResult{ID: m.ID, Name: m.Name}
+		action, err := importModel(m, overwrite)
+		if err != nil {
+			results[i].Action = "failed"
+			results[i].Error = err.Error()
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", m.ID, err)
+			continue
+		}
+		results[i].Action = action
+	}
+
+	body, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	body = append(body, '\n')
+	err = printOutput(body, func() {
+		printModelImportTable(results)
+		counts := map[string]int{}
+		for _, r := range results {
+			counts[r.Action]++
+		}
+		fmt.Printf("\n%d created, %d updated, %d unchanged, %d skipped, %d failed\n",
+			counts["created"], counts["updated"], counts["unchanged"], counts["skipped"], counts["failed"])
+	}, func() [][]string {
+		records := [][]string{{"id", "name", "action", "error"}}
+		for _, r := range results {
+			records = append(records, []string{r.ID, r.Name, r.Action, r.Error})
+		}
+		return records
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return &exitError{code: 1, msg: fmt.Sprintf("%d of %d models could not be imported", failed, len(list))}
+	}
+	return nil
+}
+
+// importModel creates m with its original ID. An existing model is left
+// alone unless overwrite is set, in which case it gets a new version only
+// if it differs from m.
+func importModel(m Model, overwrite bool) (string, error) {
+	body, _ := json.Marshal(m)
+	_, err := apiSend("POST", "/models", body)
+	if err == nil {
+		return "created", nil
+	}
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.Code != "MODEL_EXISTS" {
+		return "", err
+	}
+	if !overwrite {
+		return "skipped", nil
+	}
+
+	current, err := apiGet("/models/" + m.ID)
+	if err != nil {
+		return "", err
+	}
+	var existing Model
+	if err := json.Unmarshal(current, &existing); err != nil {
+		return "", err
+	}
+	body, _ = json.Marshal(modelUpdate(m))
+	if current, _ := json.Marshal(modelUpdate(existing)); sameJSON(current, body) {
+		return "unchanged", nil
+	}
+	if _, err := apiSend("PUT", "/models/"+m.ID, body); err != nil {
+		return "", err
+	}
+	return "updated", nil
+}
+
+// modelUpdate is the PUT /models/{id} body that makes a model match m.
+// Settings m lacks are sent empty, since the server keeps those left out.
+func modelUpdate(m Model) map[string]interface{} {
+	nulls := m.Nulls
+	if nulls == nil {
+		nulls = &rowschema.Nulls{}
+	}
+	return map[string]interface{}{
+		"name":     m.Name,
+		"schema":   m.Schema,
+		"defaults": orEmpty(m.Defaults, "{}"),
+		"mapping":  orEmpty(m.Mapping, "{}"),
+		"computed": orEmpty(m.Computed, "[]"),
+		"nulls":    nulls,
+	}
+}
+
+// orEmpty returns v, or the JSON value empty when v is missing or null.
+func orEmpty(v json.RawMessage, empty string) json.RawMessage {
+	if len(v) == 0 || string(v) == "null" {
+		return json.RawMessage(empty)
+	}
+	return v
+}
+
+// sameJSON reports whether a and b encode the same value, ignoring
+// formatting and key order.
+func sameJSON(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func printModelImportTable(results []modelImportResult) {
+	fmt.Printf("%-10s %-24s %-9s %s\n", "ID", "NAME", "ACTION", "ERROR")
+	fmt.Println("---------- ------------------------ --------- -----")
+	for _, r := range results {
+		fmt.Printf("%-10s %-24s %-9s %s\n", r.ID, clip(r.Name, 24), r.Action, r.Error)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeModelServer answers model import's requests for one existing model,
+// recording the body of any PUT.
+func fakeModelServer(t *testing.T, existing string, put *json.RawMessage) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `{"error":"MODEL_EXISTS","message":"model already exists"}`)
+		case "GET":
+			io.WriteString(w, existing)
+		case "PUT":
+			*put, _ = io.ReadAll(r.Body)
+			io.WriteString(w, existing)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	old := apiURL
+	t.Cleanup(func() { apiURL = old })
+	apiURL = srv.URL
+}
+
+func TestImportModelOverwrite(t *testing.T) {
+	const existing = `{"id":"m1","name":"events","schema":{"type":"object"},"version":2,` +
+		`"defaults":{"key_column":"id"}}`
+	tests := []struct {
+		name    string
+		model   string
+		action  string
+		wantPut string
+	}{
+		{
+			name:   "same model",
+			model:  `{"id":"m1","name":"events","schema":{"type":"object"},"defaults":{"key_column":"id"}}`,
+			action: "unchanged",
+		},
+		{
+			name:    "changed defaults",
+			model:   `{"id":"m1","name":"events","schema":{"type":"object"},"defaults":{"key_column":"ts"}}`,
+			action:  "updated",
+			wantPut: `{"name":"events","schema":{"type":"object"},"defaults":{"key_column":"ts"},"mapping":{},"computed":[],"nulls":{}}`,
+		},
+		{
+			name:    "defaults dropped from the export",
+			model:   `{"id":"m1","name":"events","schema":{"type":"object"}}`,
+			action:  "updated",
+			wantPut: `{"name":"events","schema":{"type":"object"},"defaults":{},"mapping":{},"computed":[],"nulls":{}}`,
+		},
+		{
+			name:   "same mapping",
+			model:  `{"id":"m1","name":"events","schema":{"type":"object"},"defaults":{"key_column":"id"},"mapping":{},"computed":[],"nulls":{}}`,
+			action: "unchanged",
+		},
+		{
+			name: "changed mapping",
+			model: `{"id":"m1","name":"events","schema":{"type":"object"},"defaults":{"key_column":"id"},` +
+				`"mapping":{"rename":{"ts":"timestamp"}}}`,
+			action: "updated",
+			wantPut: `{"name":"events","schema":{"type":"object"},"defaults":{"key_column":"id"},` +
+				`"mapping":{"rename":{"ts":"timestamp"}},"computed":[],"nulls":{}}`,
+		},
+		{
+			name: "added computed field",
+			model: `{"id":"m1","name":"events","schema":{"type":"object"},"defaults":{"key_column":"id"},` +
+				`"computed":[{"name":"loaded_at","builtin":"now()"}]}`,
+			action: "updated",
+			wantPut: `{"name":"events","schema":{"type":"object"},"defaults":{"key_column":"id"},"mapping":{},` +
+				`"computed":[{"name":"loaded_at","builtin":"now()"}],"nulls":{}}`,
+		},
+		{
+			name: "changed nulls",
+			model: `{"id":"m1","name":"events","schema":{"type":"object"},"defaults":{"key_column":"id"},` +
+				`"nulls":{"values":["NULL","\\N"]}}`,
+			action: "updated",
+			wantPut: `{"name":"events","schema":{"type":"object"},"defaults":{"key_column":"id"},"mapping":{},` +
+				`"computed":[],"nulls":{"values":["NULL","\\N"]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var put json.RawMessage
+			fakeModelServer(t, existing, &put)
+			var m Model
+			if err := json.Unmarshal([]byte(tt.model), &m); err != nil {
+				t.Fatal(err)
+			}
+			action, err := importModel(m, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action != tt.action {
+				t.Errorf("action = %q, want %q", action, tt.action)
+			}
+			if tt.wantPut == "" {
+				if put != nil {
+					t.Errorf("unexpected PUT %s", put)
+				}
+			} else if !sameJSON(put, json.RawMessage(tt.wantPut)) {
+				t.Errorf("PUT body = %s, want %s", put, tt.wantPut)
+			}
+		})
+	}
+}
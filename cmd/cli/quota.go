@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// QuotaStatus is the reply to GET /quota. A zero limit is unlimited.
+type QuotaStatus struct {
+	Namespace string `json:"namespace"`
+	Window    string `json:"window"`
+	Limits    struct {
+		MaxConcurrentJobs    int64 `json:"max_concurrent_jobs"`
+		MaxRowsPerDay        int64 `json:"max_rows_per_day"`
+		MaxUploadBytesPerDay int64 `json:"max_upload_bytes_per_day"`
+	} `json:"limits"`
+	Usage struct {
+		ConcurrentJobs int64 `json:"concurrent_jobs"`
+		Rows           int64 `json:"rows"`
+		UploadBytes    int64 `json:"upload_bytes"`
+	} `json:"usage"`
+}
+
+func cmdQuota() *cobra.Command {
+	return &cobra.Command{
+		Use:   "quota",
+		Short: "Show the namespace's quotas and current usage",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			body, err := apiGet("/quota")
+			if err != nil {
+				return err
+			}
+			var q QuotaStatus
+			if err := json.Unmarshal(body, &q); err != nil {
+				return err
+			}
+			rows := [][]string{
+				{"concurrent_jobs", strconv.FormatInt(q.Usage.ConcurrentJobs, 10), quotaLimit(q.Limits.MaxConcurrentJobs)},
+				{"rows_per_day", strconv.FormatInt(q.Usage.Rows, 10), quotaLimit(q.Limits.MaxRowsPerDay)},
+				{"upload_bytes_per_day", strconv.FormatInt(q.Usage.UploadBytes, 10), quotaLimit(q.Limits.MaxUploadBytesPerDay)},
+			}
+			return printOutput(body,
+				func() {
+					fmt.Printf("namespace %s, daily usage over the last %s\n", q.Namespace, q.Window)
+					fmt.Printf("%-22s %14s %14s\n", "QUOTA", "USED", "LIMIT")
+					for _, r := range rows {
+						fmt.Printf("%-22s %14s %14s\n", r[0], r[1], r[2])
+					}
+				},
+				func() [][]string {
+					return append([][]string{{"quota", "used", "limit"}}, rows...)
+				})
+		},
+	}
+}
+
+func quotaLimit(n int64) string {
+	if n == 0 {
+		return "unlimited"
+	}
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+
+	"github.com/keithchambers/batch-ingestion/internal/rowschema"
+)
+
+// validationProblem is one row the server would reject.
+type validationProblem struct {
+	Row           int    `json:"row_number"`
+	Column        string `json:"column,omitempty"`
+	ErrorType     string `json:"error_type"`
+	ObservedValue string `json:"observed_value,omitempty"`
+	ExpectedType  string `json:"expected_type,omitempty"`
+	Error         string `json:"error"`
+}
+
+// validationResult is what job validate reports for a file.
+type validationResult struct {
+	File      string              `json:"file"`
+	ModelID   string              `json:"model_id"`
+	HasHeader bool                `json:"has_header"`
+	Columns   []string            `json:"columns,omitempty"`
+	Rows      int                 `json:"rows"`
+	Problems  []validationProblem `json:"problems"`
+}
+
+// validateOptions are the job settings job validate reads a file with.
+type validateOptions struct {
+	limit          int  // data rows to check, 0 for all
+	strict         bool // reject rows of the wrong width rather than fit them
+	trimSpace      bool
+	skipBlankLines bool
+	comma          rune
+}
+
+// The server's default row limits (MAX_ROW_BYTES, MAX_FIELD_BYTES and
+// MAX_COLUMNS), which job validate checks rows against.
+const (
+	maxRowBytes   = 1 << 20
+	maxFieldBytes = 512 << 10
+	maxColumns    = 1000
+)
+
+// rowPreviewLen is how much of an oversized row or cell a problem shows.
+const rowPreviewLen = 256
+
+func cmdJobValidate() *cobra.Command {
+	var opts validateOptions
+	var delimiter string
+	cmd := &cobra.Command{
+		Use:   "validate <model_id> <file>",
+		Short: "Check a CSV file against a model without uploading it",
+		Long: "Read the header and the first --rows rows of a CSV file and check them with the\n" +
+			"same rules the server applies to the model's schema and its default row size\n" +
+			"limits. Nothing is uploaded. Exits 1 if any row would be rejected.",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeModelArg(cobra.ShellCompDirectiveDefault),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.limit < 0 {
+				return fmt.Errorf("invalid --rows %d: must not be negative", opts.limit)
+			}
+			comma, err := csvComma(args[1], delimiter)
+			if err != nil {
+				return err
+			}
+			opts.comma = comma
+			return jobValidate(args[0], args[1], opts)
+		},
+	}
+	cmd.Flags().IntVar(&opts.limit, "rows", 100, "Number of data rows to check (0 checks the whole file)")
+	cmd.Flags().BoolVar(&opts.strict, "strict-columns", true, "Report rows whose column count differs from the header or schema")
+	cmd.Flags().StringVar(&delimiter, "delimiter", "", "CSV field separator: one character, or tab (default tab for .tsv files, comma otherwise)")
+	cmd.Flags().BoolVar(&opts.trimSpace, "trim-space", false, "Strip leading and trailing white space from every field, as job create --trim-space does")
+	cmd.Flags().BoolVar(&opts.skipBlankLines, "skip-blank-lines", false, "Skip lines whose fields are all blank, as job create --skip-blank-lines does")
+	return cmd
+}
+
+// csvComma picks the field separator the server would use for path: the
+// delimiter if given, a tab for .tsv files, and a comma otherwise.
+func csvComma(path, delimiter string) (rune, error) {
+	switch delimiter {
+	case "":
+		if strings.HasSuffix(strings.TrimSuffix(strings.ToLower(path), ".gz"), ".tsv") {
+			return '\t', nil
+		}
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(delimiter)
+	if size != len(delimiter) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid --delimiter %q: want a single character other than a quote or line break, or tab", delimiter)
+	}
+	return r, nil
+}
+
+func jobValidate(modelID, path string, opts validateOptions) error {
+	body, err := apiGet("/models/" + modelID)
+	if err != nil {
+		return err
+	}
+	var model Model
+	if err := json.Unmarshal(body, &model); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	result, err := validateCSV(f, model.Schema, model.Nulls, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	result.File = path
+	result.ModelID = modelID
+
+	out, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	err = printOutput(out, func() {
+		if len(result.Problems) > 0 {
+			printValidationTable(result.Problems)
+			fmt.Println()
+		}
+		fmt.Printf("%s rows checked, %d problems\n", formatNumber(result.Rows), len(result.Problems))
+	}, func() [][]string {
+		return validationCSVRecords(result.Problems)
+	})
+	if err != nil {
+		return err
+	}
+	if len(result.Problems) > 0 {
+		return &exitError{code: 1, msg: fmt.Sprintf("%d of %d rows would be rejected", rejectedRowCount(result.Problems), result.Rows)}
+	}
+	return nil
+}
+
+// validateCSV checks up to opts.limit data rows of r the way the server
+// ingests them: the first row is a header only if every cell names a schema
+// property, otherwise cells are matched to properties in schema order.
+func validateCSV(r io.Reader, schema json.RawMessage, nulls *rowschema.Nulls, opts validateOptions) (*validationResult, error) {
+	br := bufio.NewReader(r)
+	// The server strips a UTF-8 byte order mark before parsing
+	if b, err := br.Peek(3); err == nil && string(b) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+	rl := csv.NewReader(br)
+	rl.Comma = opts.comma
+	rs := rowschema.New(schema)
+	result := &validationResult{Problems: []validationProblem{}}
+
+	first, err := rl.Read()
+	if err == io.EOF {
+		return result, nil
+	}
+	var perr *csv.ParseError
+	if err != nil && !errors.As(err, &perr) {
+		return nil, err
+	}
+	if err == nil && opts.trimSpace {
+		trimCells(first)
+	}
+
+	rowNumber := 0
+	pending := [][]string{first}
+	if err == nil && rs != nil && rs.IsHeader(first) {
+		result.HasHeader = true
+		result.Columns = first
+		rowNumber++
+		pending = nil
+	} else if rs != nil {
+		result.Columns = rowschema.Columns(schema)
+		if err == nil {
+			result.Problems = append(result.Problems, headerProblems(first, rs)...)
+		}
+	}
+	if len(result.Columns) > 0 {
+		rl.FieldsPerRecord = -1
+	}
+
+	for opts.limit == 0 || result.Rows < opts.limit {
+		var rec []string
+		if len(pending) > 0 {
+			rec, pending = pending[0], nil
+		} else {
+			rec, err = rl.Read()
+		}
+		if err == io.EOF {
+			break
+		}
+		rowNumber++
+		if err == nil && opts.skipBlankLines && blankRecord(rec) {
+			continue
+		}
+		result.Rows++
+		if err != nil {
+			if !errors.As(err, &perr) {
+				return nil, err
+			}
+			raw := strings.Join(rec, ",")
+			result.Problems = append(result.Problems, validationProblem{
+				Row: rowNumber, ErrorType: "PARSE_ERROR", ObservedValue: raw, Error: err.Error(),
+			})
+			err = nil
+			continue
+		}
+		if opts.trimSpace {
+			trimCells(rec)
+		}
+		if p := checkRowLimits(rec, result.Columns, opts.comma); p != nil {
+			p.Row = rowNumber
+			result.Problems = append(result.Problems, *p)
+			continue
+		}
+		if !utf8.ValidString(strings.Join(rec, "")) {
+			result.Problems = append(result.Problems, validationProblem{
+				Row:       rowNumber,
+				ErrorType: "PARSE_ERROR",
+				Error:     "INVALID_ENCODING: row contains bytes that cannot be decoded",
+			})
+			continue
+		}
+		if p := checkRow(rec, result.Columns, rs, nulls, opts.strict); p != nil {
+			p.Row = rowNumber
+			result.Problems = append(result.Problems, *p)
+		}
+	}
+	return result, nil
+}
+
+// checkRow applies the server's column count and schema rules to one row.
+func checkRow(rec, cols []string, rs *rowschema.Schema, nulls *rowschema.Nulls, strict bool) *validationProblem {
+	if n := len(cols); n > 0 && len(rec) != n {
+		if strict {
+			return schemaProblem(rowschema.CheckWidth(rec, n))
+		}
+		rec = rowschema.Fit(rec, n)
+	}
+	if _, err := rs.Coerce(rec, cols, nulls); err != nil {
+		return schemaProblem(err)
+	}
+	return nil
+}
+
+// checkRowLimits applies the server's default row size limits to one row.
+// cols names the cells, if known.
+func checkRowLimits(rec, cols []string, comma rune) *validationProblem {
+	if len(rec) > maxColumns {
+		return &validationProblem{
+			ErrorType:     "PARSE_ERROR",
+			ObservedValue: strconv.Itoa(len(rec)),
+			Error:         fmt.Sprintf("TOO_MANY_COLUMNS: row has %d columns, limit is %d", len(rec), maxColumns),
+		}
+	}
+	size := len(rec) - 1
+	for i, cell := range rec {
+		if len(cell) > maxFieldBytes {
+			p := &validationProblem{
+				ErrorType:     "PARSE_ERROR",
+				ObservedValue: clip(cell, rowPreviewLen),
+				Error:         fmt.Sprintf("ROW_TOO_LARGE: field %d is %d bytes, limit is %d", i+1, len(cell), maxFieldBytes),
+			}
+			if i < len(cols) {
+				p.Column = cols[i]
+				p.Error = fmt.Sprintf("ROW_TOO_LARGE: field '%s' is %d bytes, limit is %d", cols[i], len(cell), maxFieldBytes)
+			}
+			return p
+		}
+		size += len(cell)
+	}
+	if size > maxRowBytes {
+		return &validationProblem{
+			ErrorType:     "PARSE_ERROR",
+			ObservedValue: clip(strings.Join(rec, string(comma)), rowPreviewLen),
+			Error:         fmt.Sprintf("ROW_TOO_LARGE: row is %d bytes, limit is %d", size, maxRowBytes),
+		}
+	}
+	return nil
+}
+
+// trimCells strips leading and trailing white space from each cell of rec
+// in place.
+func trimCells(rec []string) {
+	for i, v := range rec {
+		rec[i] = strings.TrimSpace(v)
+	}
+}
+
+// blankRecord reports whether every cell of rec is empty or white space,
+// as for a line of spaces or of delimiters alone.
+func blankRecord(rec []string) bool {
+	for _, v := range rec {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func schemaProblem(err error) *validationProblem {
+	p := &validationProblem{ErrorType: "SCHEMA_VIOLATION", Error: err.Error()}
+	var serr *rowschema.Error
+	if errors.As(err, &serr) {
+		p.Column = serr.Column
+		p.ObservedValue = serr.Observed
+		p.ExpectedType = serr.Expected
+	}
+	return p
+}
+
+// headerProblems explains a first row that names some schema properties but
+// not all of them: the server will not treat it as a header, so it and
+// every later row are read in schema property order.
+func headerProblems(rec []string, rs *rowschema.Schema) []validationProblem {
+	var known, unknown []string
+	for _, name := range rec {
+		if rs.Has(name) {
+			known = append(known, name)
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(known) == 0 {
+		return nil
+	}
+	problems := make([]validationProblem, len(unknown))
+	for i, name := range unknown {
+		problems[i] = validationProblem{
+			Row:           1,
+			Column:        name,
+			ErrorType:     "SCHEMA_VIOLATION",
+			ObservedValue: name,
+			Error:         fmt.Sprintf("HEADER_MISMATCH: column '%s' is not in the model schema, so the first row will be read as data", name),
+		}
+	}
+	return problems
+}
+
+// rejectedRowCount counts the distinct rows among problems.
+func rejectedRowCount(problems []validationProblem) int {
+	rows := map[int]bool{}
+	for _, p := range problems {
+		rows[p.Row] = true
+	}
+	return len(rows)
+}
+
+func printValidationTable(problems []validationProblem) {
+	fmt.Printf("%-7s %-20s %-24s %-20s %s\n", "ROW", "COLUMN", "ERROR", "OBSERVED", "MESSAGE")
+	fmt.Println("------- -------------------- ------------------------ -------------------- -------")
+	for _, p := range problems {
+		code, message := splitErrorCode(p.Error)
+		fmt.Printf("%-7d %-20s %-24s %-20s %s\n", p.Row, clip(p.Column, 20), code, clip(p.ObservedValue, 20), message)
+	}
+}
+
+// validationCSVRecords returns the validation problems without padding.
+func validationCSVRecords(problems []validationProblem) [][]string {
+	records := [][]string{{"row", "column", "type", "error", "observed", "message", "expected"}}
+	for _, p := range problems {
+		code, message := splitErrorCode(p.Error)
+		records = append(records, []string{
+			strconv.Itoa(p.Row), p.Column, p.ErrorType, code, p.ObservedValue, message, p.ExpectedType,
+		})
+	}
+	return records
+}
+
+// splitErrorCode separates a leading CODE: prefix from a row error message.
+func splitErrorCode(msg string) (code, message string) {
+	c, rest, ok := strings.Cut(msg, ": ")
+	if !ok || c == "" || strings.ToUpper(c) != c || strings.ContainsAny(c, " ") {
+		return "", msg
+	}
+	return c, rest
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const validateSchema = `{
+	"type": "object",
+	"properties": {
+		"id":   {"type": "integer"},
+		"name": {"type": "string"}
+	},
+	"required": ["id"]
+}`
+
+// problemCodes lists each problem as row:CODE.
+func problemCodes(problems []validationProblem) []string {
+	codes := make([]string, len(problems))
+	for i, p := range problems {
+		code, _ := splitErrorCode(p.Error)
+		codes[i] = fmt.Sprintf("%d:%s", p.Row, code)
+	}
+	return codes
+}
+
+func TestValidateCSVTrimAndBlankLines(t *testing.T) {
+	const data = " id , name \n 1 , a \n,\n2,b\n"
+	tests := []struct {
+		name string
+		opts validateOptions
+		rows int
+		want []string
+	}{
+		{
+			name: "untrimmed",
+			opts: validateOptions{strict: true, comma: ','},
+			rows: 4,
+			// The padded header is not recognised, so it is read as data
+			want: []string{"1:TYPE_MISMATCH", "3:REQUIRED_FIELD_EMPTY"},
+		},
+		{
+			name: "trimmed",
+			opts: validateOptions{strict: true, trimSpace: true, comma: ','},
+			rows: 3,
+			want: []string{"3:REQUIRED_FIELD_EMPTY"},
+		},
+		{
+			name: "trimmed, blank lines skipped",
+			opts: validateOptions{strict: true, trimSpace: true, skipBlankLines: true, comma: ','},
+			rows: 2,
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := validateCSV(strings.NewReader(data), json.RawMessage(validateSchema), nil, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Rows != tt.rows {
+				t.Errorf("rows = %d, want %d", result.Rows, tt.rows)
+			}
+			if got := problemCodes(result.Problems); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("problems = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCSVRowLimits(t *testing.T) {
+	opts := validateOptions{strict: true, comma: ','}
+	wide := strings.Repeat("x,", maxColumns) + "x"
+	big := strings.Repeat("y", maxFieldBytes+1)
+	long := strings.Repeat("z", maxFieldBytes)
+	data := "id,name\n" + wide + "\n1," + big + "\n" + long + "," + long + "\n3,ok\n"
+
+	result, err := validateCSV(strings.NewReader(data), json.RawMessage(validateSchema), nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Problems) != 3 {
+		t.Fatalf("got %d problems, want 3: %v", len(result.Problems), problemCodes(result.Problems))
+	}
+	for i, want := range []string{
+		"TOO_MANY_COLUMNS: row has 1001 columns, limit is 1000",
+		"ROW_TOO_LARGE: field 'name' is 524289 bytes, limit is 524288",
+		"ROW_TOO_LARGE: row is 1048577 bytes, limit is 1048576",
+	} {
+		p := result.Problems[i]
+		if p.Row != i+2 || p.Error != want || p.ErrorType != "PARSE_ERROR" {
+			t.Errorf("problem %d = row %d %s %q, want row %d PARSE_ERROR %q", i, p.Row, p.ErrorType, p.Error, i+2, want)
+		}
+		if len([]rune(p.ObservedValue)) > rowPreviewLen {
+			t.Errorf("problem %d observed value is %d characters, want at most %d", i, len([]rune(p.ObservedValue)), rowPreviewLen)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+var (
+	// quiet reduces output to the IDs of what a command touched.
+	quiet bool
+	// verbose logs every API call to stderr.
+	verbose bool
+)
+
+// loggingTransport logs each request's method, URL, status and duration
+// to stderr once its response headers arrive.
+type loggingTransport struct {
+	next http.RoundTripper
+}
+
+func (t loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	reqID := req.Header.Get("X-Request-ID")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %v (%s) [request ID %s]\n", req.Method, req.URL, err, elapsed, reqID)
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "%s %s: %s (%s) [request ID %s]\n", req.Method, req.URL, resp.Status, elapsed, reqID)
+	return resp, nil
+}
+
+// logRequests makes every API client log its calls.
+func logRequests() {
+	for _, c := range []*http.Client{apiClient, uploadClient, streamClient} {
+		next := c.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		c.Transport = loggingTransport{next}
+	}
+}
+
+// printIDs prints the job_id, or failing that the id, of a response
+// object, or of each object in a response array, one per line. It is
+// what --quiet leaves of a command's output.
+func printIDs(body []byte) error {
+	var list []map[string]interface{}
+	if err := json.Unmarshal(body, &list); err != nil {
+		var obj map[string]interface{}
+		if json.Unmarshal(body, &obj) != nil {
+			return nil
+		}
+		list = append(list, obj)
+	}
+	for _, obj := range list {
+		for _, key := range []string{"job_id", "id"} {
+			if id, ok := obj[key].(string); ok && id != "" {
+				fmt.Println(id)
+				break
+			}
+		}
+	}
+	return nil
+}
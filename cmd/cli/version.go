@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// Set at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=...".
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// BuildInfo identifies a binary; the server's GET /version has the same
+// shape.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// buildInfo reports the -ldflags values, falling back to the VCS stamp Go
+// embeds when the binary was built from a checkout without them.
+func buildInfo() BuildInfo {
+	info := BuildInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = s.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+func cmdVersion() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Show CLI and server build information",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showVersion()
+		},
+	}
+}
+
+// showVersion prints the CLI's build and, when the API answers, the
+// server's. An unreachable server is reported but is not an error.
+func showVersion() error {
+	result := struct {
+		Client BuildInfo  `json:"client"`
+		Server *BuildInfo `json:"server,omitempty"`
+		Error  string     `json:"server_error,omitempty"`
+	}{Client: buildInfo()}
+
+	if body, err := apiGet("/version"); err != nil {
+		result.Error = err.Error()
+	} else {
+		var server BuildInfo
+		if err := json.Unmarshal(body, &server); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Server = &server
+		}
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return printOutput(body, func() {
+		printBuildInfo("Client", result.Client)
+		if result.Server != nil {
+			printBuildInfo("Server", *result.Server)
+		} else {
+			fmt.Printf("Server (%s): %s\n", apiURL, result.Error)
+		}
+	}, func() [][]string {
+		records := [][]string{{"component", "version", "commit", "build_date", "go_version"}, buildInfoRecord("client", result.Client)}
+		if result.Server != nil {
+			records = append(records, buildInfoRecord("server", *result.Server))
+		}
+		return records
+	})
+}
+
+func printBuildInfo(component string, info BuildInfo) {
+	fmt.Printf("%s:\n  Version:    %s\n  Commit:     %s\n  Build date: %s\n  Go version: %s\n",
+		component, info.Version, info.Commit, info.BuildDate, info.GoVersion)
+}
+
+func buildInfoRecord(component string, info BuildInfo) []string {
+	return []string{component, info.Version, info.Commit, info.BuildDate, info.GoVersion}
+}
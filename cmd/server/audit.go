@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// AuditEntry records one successful state-changing request.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Namespace  string    `json:"namespace"`
+	Action     string    `json:"action"`   // e.g. model.create, job.cancel
+	Resource   string    `json:"resource"` // model or job
+	ResourceID string    `json:"resource_id"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Principal  string    `json:"principal,omitempty"` // API key fingerprint; empty when auth is off
+}
+
+// The audit log is appended to the AUDIT_TOPIC topic (default
+// batch.audit) by a background writer. The newest AUDIT_MEMORY_MAX
+// entries (default 10000) are also kept in memory for GET /audit.
+var (
+	auditTopic     = getenv("AUDIT_TOPIC", "batch.audit")
+	auditMemoryMax = envInt("AUDIT_MEMORY_MAX", 10000)
+
+	auditMu    sync.RWMutex
+	auditLog   []AuditEntry // oldest first
+	auditQueue = make(chan AuditEntry, 1000)
+)
+
+// audit records action on a resource by r. It never blocks or fails the
+// request: an entry the writer cannot keep up with is logged and dropped
+// from the topic, though it stays in memory.
+func audit(r *http.Request, action, resource, id string) {
+	e := AuditEntry{
+		Time:       time.Now().UTC(),
+		Namespace:  namespace(r),
+		Action:     action,
+		Resource:   resource,
+		ResourceID: id,
+		RequestID:  requestID(r),
+		Principal:  principal(r),
+	}
+	auditMu.Lock()
+	auditLog = append(auditLog, e)
+	if n := len(auditLog) - auditMemoryMax; n > 0 {
+		auditLog = append(auditLog[:0:0], auditLog[n:]...)
+	}
+	auditMu.Unlock()
+
+	select {
+	case auditQueue <- e:
+	default:
+		requestLogger(r).Error("audit queue full, entry not written to Kafka", "action", action, "resource_id", id)
+	}
+}
+
+// writeAudit produces queued entries to the audit topic until ctx ends,
+// then flushes what is still queued.
+func writeAudit(ctx context.Context) {
+	created := false
+	write := func(e AuditEntry) {
+		if !created {
+			created = createAuditTopic()
+		}
+		value, _ := json.Marshal(e)
+		wctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := kafkaWriter.WriteMessages(wctx, kafka.Message{Topic: auditTopic, Key: []byte(e.ResourceID), Value: value})
+		if err != nil {
+			slog.Error("failed to write audit entry", "action", e.Action, "resource_id", e.ResourceID, "request_id", e.RequestID, "error", err)
+		}
+	}
+	for {
+		select {
+		case e := <-auditQueue:
+			write(e)
+		case <-ctx.Done():
+			for {
+				select {
+				case e := <-auditQueue:
+					write(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// createAuditTopic creates the audit topic with unlimited retention and
+// reports whether it now exists.
+func createAuditTopic() bool {
+	conn, err := kafkaDialer.Dial("tcp", kafkaBrokers()[0])
+	if err != nil {
+		slog.Error("failed to create audit topic", "topic", auditTopic, "error", err)
+		return false
+	}
+	defer conn.Close()
+	err = conn.CreateTopics(kafka.TopicConfig{
+		Topic:             auditTopic,
+		NumPartitions:     1,
+		ReplicationFactor: 1,
+		ConfigEntries:     []kafka.ConfigEntry{{ConfigName: "retention.ms", ConfigValue: "-1"}},
+	})
+	if err != nil {
+		// It may already exist; a missing topic shows up as a write error
+		slog.Debug("audit topic not created", "topic", auditTopic, "error", err)
+	}
+	return true
+}
+
+// principal names the caller r authenticated as: a fingerprint of its API
+// key, never the key itself.
+func principal(r *http.Request) string {
+	p, _ := r.Context().Value(principalKey).(string)
+	return p
+}
+
+// keyFingerprint returns the first 12 hex digits of the key's SHA-256.
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// listAudit returns the caller's namespace's in-memory audit entries,
+// oldest first, filtered by resource, resource_id, action and a
+// since/until time range (RFC 3339).
+func listAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var since, until time.Time
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				badRequest(w, "INVALID_TIME", p.name+" must be an RFC 3339 time such as 2024-01-02T15:04:05Z")
+				return
+			}
+			*p.t = t
+		}
+	}
+	ns := namespace(r)
+	list := []AuditEntry{}
+	auditMu.RLock()
+	for _, e := range auditLog {
+		switch {
+		case e.Namespace != ns,
+			q.Get("resource") != "" && e.Resource != q.Get("resource"),
+			q.Get("resource_id") != "" && e.ResourceID != q.Get("resource_id"),
+			q.Get("action") != "" && e.Action != q.Get("action"),
+			!since.IsZero() && e.Time.Before(since),
+			!until.IsZero() && !e.Time.Before(until):
+			continue
+		}
+		list = append(list, e)
+	}
+	auditMu.RUnlock()
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/keithchambers/batch-ingestion/internal/rowschema"
+)
+
+// avroField is one field of the record schema derived from a model.
+type avroField struct {
+	Name     string // sanitised Avro name
+	Column   string // model property it is filled from
+	Type     string // long, double, boolean or string
+	Nullable bool   // encoded as the union ["null", Type]
+}
+
+// avroCodec encodes typed rows as Confluent wire-format Avro. schemaID is
+// filled in once the schema has been registered for the job's topic.
+type avroCodec struct {
+	name     string
+	fields   []avroField
+	schemaID uint32
+}
+
+var avroNameRe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// avroName turns s into a valid Avro name.
+func avroName(s string) string {
+	s = avroNameRe.ReplaceAllString(s, "_")
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+	return s
+}
+
+// newAvroCodec derives a record schema from the model's properties in
+// declaration order, named and filtered by its column mapping. Fields not
+// listed in required are nullable; object and array properties are
+// carried as JSON strings.
+func newAvroCodec(model Model, rs *rowschema.Schema) (*avroCodec, error) {
+	cols := rowschema.Columns(model.Schema)
+	if rs == nil || len(cols) == 0 {
+		return nil, fmt.Errorf("model schema declares no properties")
+	}
+	c := &avroCodec{name: avroName(model.Name)}
+	if model.Name == "" {
+		c.name = avroName("model_" + model.ID)
+	}
+	seen := map[string]bool{}
+	for _, col := range cols {
+		name, ok := model.Mapping.outputName(col)
+		if !ok {
+			continue
+		}
+		f := avroField{Name: avroName(name), Column: col, Nullable: !rs.Required(col)}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("properties collide as Avro field %q", f.Name)
+		}
+		seen[f.Name] = true
+		switch rs.Field(col).Type {
+		case "integer":
+			f.Type = "long"
+		case "number":
+			f.Type = "double"
+		case "boolean":
+			f.Type = "boolean"
+		default:
+			f.Type = "string"
+		}
+		c.fields = append(c.fields, f)
+	}
+	return c, nil
+}
+
+// schema returns the Avro schema as JSON text.
+func (c *avroCodec) schema() string {
+	fields := make([]map[string]interface{}, 0, len(c.fields))
+	for _, f := range c.fields {
+		fd := map[string]interface{}{"name": f.Name, "type": f.Type}
+		if f.Nullable {
+			fd["type"] = []string{"null", f.Type}
+			fd["default"] = nil
+		}
+		fields = append(fields, fd)
+	}
+	b, _ := json.Marshal(map[string]interface{}{
+		"type":   "record",
+		"name":   c.name,
+		"fields": fields,
+	})
+	return string(b)
+}
+
+// encode writes values, whose cells are named by cols, as a Confluent
+// wire-format message: magic byte 0, the big-endian schema ID, then the
+// Avro binary record.
+func (c *avroCodec) encode(values []interface{}, cols []string) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(0)
+	_ = binary.Write(&buf, binary.BigEndian, c.schemaID)
+
+	for _, f := range c.fields {
+		var v interface{}
+		if i := indexOf(cols, f.Column); i >= 0 && i < len(values) {
+			v = values[i]
+		}
+		if v == nil {
+			if !f.Nullable {
+				return nil, &RowError{
+					Type:     ErrorTypeSchemaViolation,
+					Column:   f.Column,
+					Expected: f.Type,
+					Message:  fmt.Sprintf("REQUIRED_FIELD_EMPTY: required column '%s' is missing or empty", f.Column),
+				}
+			}
+			writeLong(&buf, 0) // union branch "null"
+			continue
+		}
+		if f.Nullable {
+			writeLong(&buf, 1)
+		}
+		if err := writeAvroValue(&buf, f.Type, v); err != nil {
+			return nil, &RowError{
+				Type:     ErrorTypeSchemaViolation,
+				Column:   f.Column,
+				Expected: f.Type,
+				Message:  fmt.Sprintf("TYPE_MISMATCH: column '%s' cannot be encoded as Avro %s: %v", f.Column, f.Type, err),
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func writeAvroValue(buf *bytes.Buffer, typ string, v interface{}) error {
+	switch typ {
+	case "long":
+		n, ok := v.(int64)
+		if !ok {
+			return fmt.Errorf("unexpected %T", v)
+		}
+		writeLong(buf, n)
+	case "double":
+		n, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("unexpected %T", v)
+		}
+		_ = binary.Write(buf, binary.LittleEndian, math.Float64bits(n))
+	case "boolean":
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("unexpected %T", v)
+		}
+		if b {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	default:
+		s, ok := v.(string)
+		if !ok {
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			s = string(raw)
+		}
+		writeLong(buf, int64(len(s)))
+		buf.WriteString(s)
+	}
+	return nil
+}
+
+// writeLong writes n as an Avro zig-zag varint.
+func writeLong(buf *bytes.Buffer, n int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutVarint(tmp[:], n)])
+}
+
+// registerSchema registers schema, of the registry's schemaType AVRO or
+// PROTOBUF, under the topic's value subject in the Schema Registry at
+// SCHEMA_REGISTRY_URL and returns its ID.
+func registerSchema(topic, schemaType, schema string) (uint32, error) {
+	base := strings.TrimRight(getenv("SCHEMA_REGISTRY_URL", ""), "/")
+	if base == "" {
+		return 0, fmt.Errorf("SCHEMA_REGISTRY_URL is not set")
+	}
+	body, _ := json.Marshal(map[string]string{"schemaType": schemaType, "schema": schema})
+	req, err := http.NewRequest("POST", base+"/subjects/"+url.PathEscape(topic+"-value")+"/versions", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ID      uint32 `json:"id"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("schema registry returned HTTP %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("schema registry returned HTTP %d: %s", resp.StatusCode, result.Message)
+	}
+	return result.ID, nil
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// modelCloneRequest is the optional body of POST /models/{id}/clone.
+type modelCloneRequest struct {
+	Name string `json:"name,omitempty"` // defaults to the source's name with "-copy" appended
+}
+
+// cloneModel creates a model from the latest version of another: the same
+// schema, defaults, mapping and computed fields under a new ID, starting
+// again at version 1.
+func cloneModel(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var req modelCloneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		badRequest(w, "INVALID_JSON", err.Error())
+		return
+	}
+
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	ns := namespace(r)
+	src, ok := models[modelKey(ns, id)]
+	if !ok {
+		notFound(w, "MODEL_NOT_FOUND", "model not found")
+		return
+	}
+	m := src
+	m.ID = randomID()
+	m.Name = req.Name
+	if m.Name == "" {
+		m.Name = src.Name + "-copy"
+	}
+	m.Version = 1
+	m.CreatedAt = time.Now().UTC()
+	if err := validateModel(m); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if other, taken := modelNameTaken(ns, m.Name, m.ID); taken {
+		conflict(w, "DUPLICATE_MODEL_NAME", "model name already used by "+other)
+		return
+	}
+	key := modelKey(ns, m.ID)
+	models[key] = m
+	versions[key] = []Model{m}
+	audit(r, "model.clone", "model", m.ID)
+	writeJSON(w, http.StatusCreated, m)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema compatibility modes for PUT /models/{id}, named as in Confluent
+// Schema Registry.
+const (
+	CompatBackward = "BACKWARD" // consumers on the new schema can read rows written with the old one
+	CompatForward  = "FORWARD"  // consumers on the old schema can read rows written with the new one
+	CompatFull     = "FULL"     // both
+	CompatNone     = "NONE"     // no check
+)
+
+// parseCompatibility reads the compatibility query parameter. Without it
+// updates must be FULL compatible, so neither a removed field nor a
+// narrowed type gets through unnoticed.
+func parseCompatibility(r *http.Request) (string, error) {
+	mode := strings.ToUpper(r.URL.Query().Get("compatibility"))
+	switch mode {
+	case "":
+		return CompatFull, nil
+	case CompatBackward, CompatForward, CompatFull, CompatNone:
+		return mode, nil
+	}
+	return "", invalid("INVALID_COMPATIBILITY", "compatibility must be BACKWARD, FORWARD, FULL or NONE")
+}
+
+// compatProperty is what the compatibility check compares of one schema
+// property.
+type compatProperty struct {
+	Types   []string // JSON Schema types; empty accepts anything
+	Format  string
+	Pattern string
+	Enum    []string // allowed values as JSON text; empty allows any
+
+	// Bounds on numbers and on string lengths; nil is unbounded
+	Minimum, Maximum, MinLength, MaxLength *float64
+}
+
+// compatSchema is the part of a model schema the compatibility check
+// compares.
+type compatSchema struct {
+	Properties map[string]compatProperty
+	Required   []string
+}
+
+func parseCompatSchema(raw json.RawMessage) compatSchema {
+	var doc struct {
+		Properties map[string]struct {
+			Type    json.RawMessage   `json:"type"`
+			Format  string            `json:"format"`
+			Pattern string            `json:"pattern"`
+			Enum    []json.RawMessage `json:"enum"`
+
+			Minimum   *float64 `json:"minimum"`
+			Maximum   *float64 `json:"maximum"`
+			MinLength *float64 `json:"minLength"`
+			MaxLength *float64 `json:"maxLength"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	_ = json.Unmarshal(raw, &doc) // already compiled, so it decodes
+	s := compatSchema{Properties: make(map[string]compatProperty, len(doc.Properties)), Required: doc.Required}
+	for name, p := range doc.Properties {
+		prop := compatProperty{
+			Format: p.Format, Pattern: p.Pattern,
+			Minimum: p.Minimum, Maximum: p.Maximum, MinLength: p.MinLength, MaxLength: p.MaxLength,
+		}
+		var single string
+		if err := json.Unmarshal(p.Type, &single); err == nil {
+			prop.Types = []string{single}
+		} else {
+			_ = json.Unmarshal(p.Type, &prop.Types)
+		}
+		for _, v := range p.Enum {
+			prop.Enum = append(prop.Enum, string(v))
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// schemaChanges lists the changes from current to proposed that break
+// mode, one sentence each, sorted. It only compares JSON schemas, so it can
+// equally gate a schema on its way to a Schema Registry.
+func schemaChanges(current, proposed json.RawMessage, mode string) []string {
+	if mode == CompatNone {
+		return nil
+	}
+	before, after := parseCompatSchema(current), parseCompatSchema(proposed)
+	backward := mode == CompatBackward || mode == CompatFull
+	forward := mode == CompatForward || mode == CompatFull
+
+	var changes []string
+	add := func(format string, args ...interface{}) {
+		changes = append(changes, fmt.Sprintf(format, args...))
+	}
+	for name, o := range before.Properties {
+		n, ok := after.Properties[name]
+		if !ok {
+			if forward {
+				add("field '%s' was removed", name)
+			}
+			continue
+		}
+		wasRequired := indexOf(before.Required, name) >= 0
+		isRequired := indexOf(after.Required, name) >= 0
+		if backward && isRequired && !wasRequired {
+			add("field '%s' became required", name)
+		}
+		if forward && wasRequired && !isRequired {
+			add("field '%s' is no longer required", name)
+		}
+		narrowed, widened := !typesCover(n.Types, o.Types), !typesCover(o.Types, n.Types)
+		switch {
+		case narrowed && widened:
+			add("field '%s' type changed from %s to %s", name, typeList(o.Types), typeList(n.Types))
+		case narrowed && backward:
+			add("field '%s' type narrowed from %s to %s", name, typeList(o.Types), typeList(n.Types))
+		case widened && forward:
+			add("field '%s' type widened from %s to %s", name, typeList(o.Types), typeList(n.Types))
+		}
+		if backward && !enumCovers(n.Enum, o.Enum) {
+			add("field '%s' allows fewer enum values", name)
+		}
+		if forward && !enumCovers(o.Enum, n.Enum) {
+			add("field '%s' allows more enum values", name)
+		}
+		if o.Pattern != n.Pattern && ((backward && n.Pattern != "") || (forward && o.Pattern != "")) {
+			add("field '%s' pattern changed from %q to %q", name, o.Pattern, n.Pattern)
+		}
+		if o.Format != n.Format && ((backward && n.Format != "") || (forward && o.Format != "")) {
+			add("field '%s' format changed from %q to %q", name, o.Format, n.Format)
+		}
+		for _, b := range []struct {
+			name          string
+			before, after *float64
+			upper         bool
+		}{
+			{"minimum", o.Minimum, n.Minimum, false},
+			{"maximum", o.Maximum, n.Maximum, true},
+			{"minLength", o.MinLength, n.MinLength, false},
+			{"maxLength", o.MaxLength, n.MaxLength, true},
+		} {
+			if (backward && boundNarrows(b.before, b.after, b.upper)) || (forward && boundNarrows(b.after, b.before, b.upper)) {
+				add("field '%s' %s changed from %s to %s", name, b.name, boundText(b.before), boundText(b.after))
+			}
+		}
+	}
+	if backward {
+		for name := range after.Properties {
+			if _, ok := before.Properties[name]; !ok && indexOf(after.Required, name) >= 0 {
+				add("required field '%s' was added", name)
+			}
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// typesCover reports whether every value of types inner is also a value of
+// outer. An integer is a number, and no types at all accepts anything.
+func typesCover(outer, inner []string) bool {
+	if len(outer) == 0 {
+		return true
+	}
+	if len(inner) == 0 {
+		return false
+	}
+	for _, t := range inner {
+		if indexOf(outer, t) < 0 && !(t == "integer" && indexOf(outer, "number") >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// enumCovers reports whether outer allows every value inner does. An empty
+// enum allows any value.
+func enumCovers(outer, inner []string) bool {
+	if len(outer) == 0 {
+		return true
+	}
+	if len(inner) == 0 {
+		return false
+	}
+	for _, v := range inner {
+		if indexOf(outer, v) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// boundNarrows reports whether changing a lower (or, with upper, an
+// upper) bound from before to after rejects values before allowed.
+func boundNarrows(before, after *float64, upper bool) bool {
+	switch {
+	case after == nil:
+		return false
+	case before == nil:
+		return true
+	case upper:
+		return *after < *before
+	}
+	return *after > *before
+}
+
+func boundText(b *float64) string {
+	if b == nil {
+		return "none"
+	}
+	return strconv.FormatFloat(*b, 'f', -1, 64)
+}
+
+func typeList(types []string) string {
+	if len(types) == 0 {
+		return "any"
+	}
+	return strings.Join(types, "|")
+}
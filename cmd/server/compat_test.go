@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const compatBase = `{
+	"type": "object",
+	"properties": {
+		"id":    {"type": "integer"},
+		"name":  {"type": "string"},
+		"color": {"type": "string", "enum": ["red", "blue"]}
+	},
+	"required": ["id"]
+}`
+
+func TestSchemaChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		proposed string
+		// want is the changes reported under BACKWARD, FORWARD and FULL
+		backward, forward, full []string
+	}{
+		{
+			name:     "unchanged",
+			proposed: compatBase,
+		},
+		{
+			name: "optional field added",
+			proposed: `{"type": "object", "properties": {"id": {"type": "integer"}, "name": {"type": "string"},
+				"color": {"type": "string", "enum": ["red", "blue"]}, "note": {"type": "string"}}, "required": ["id"]}`,
+		},
+		{
+			name: "required field added",
+			proposed: `{"type": "object", "properties": {"id": {"type": "integer"}, "name": {"type": "string"},
+				"color": {"type": "string", "enum": ["red", "blue"]}, "ts": {"type": "integer"}}, "required": ["id", "ts"]}`,
+			backward: []string{"required field 'ts' was added"},
+			full:     []string{"required field 'ts' was added"},
+		},
+		{
+			name: "field removed",
+			proposed: `{"type": "object", "properties": {"id": {"type": "integer"},
+				"color": {"type": "string", "enum": ["red", "blue"]}}, "required": ["id"]}`,
+			forward: []string{"field 'name' was removed"},
+			full:    []string{"field 'name' was removed"},
+		},
+		{
+			name: "field became required",
+			proposed: `{"type": "object", "properties": {"id": {"type": "integer"}, "name": {"type": "string"},
+				"color": {"type": "string", "enum": ["red", "blue"]}}, "required": ["id", "name"]}`,
+			backward: []string{"field 'name' became required"},
+			full:     []string{"field 'name' became required"},
+		},
+		{
+			name: "field no longer required",
+			proposed: `{"type": "object", "properties": {"id": {"type": "integer"}, "name": {"type": "string"},
+				"color": {"type": "string", "enum": ["red", "blue"]}}}`,
+			forward: []string{"field 'id' is no longer required"},
+			full:    []string{"field 'id' is no longer required"},
+		},
+		{
+			name: "type widened",
+			proposed: `{"type": "object", "properties": {"id": {"type": "number"}, "name": {"type": "string"},
+				"color": {"type": "string", "enum": ["red", "blue"]}}, "required": ["id"]}`,
+			forward: []string{"field 'id' type widened from integer to number"},
+			full:    []string{"field 'id' type widened from integer to number"},
+		},
+		{
+			name: "enum value removed",
+			proposed: `{"type": "object", "properties": {"id": {"type": "integer"}, "name": {"type": "string"},
+				"color": {"type": "string", "enum": ["red"]}}, "required": ["id"]}`,
+			backward: []string{"field 'color' allows fewer enum values"},
+			full:     []string{"field 'color' allows fewer enum values"},
+		},
+		{
+			name: "type changed",
+			proposed: `{"type": "object", "properties": {"id": {"type": "string"}, "name": {"type": "string"},
+				"color": {"type": "string", "enum": ["red", "blue"]}}, "required": ["id"]}`,
+			backward: []string{"field 'id' type changed from integer to string"},
+			forward:  []string{"field 'id' type changed from integer to string"},
+			full:     []string{"field 'id' type changed from integer to string"},
+		},
+		{
+			name: "enum value added",
+			proposed: `{"type": "object", "properties": {"id": {"type": "integer"}, "name": {"type": "string"},
+				"color": {"type": "string", "enum": ["red", "blue", "green"]}}, "required": ["id"]}`,
+			forward: []string{"field 'color' allows more enum values"},
+			full:    []string{"field 'color' allows more enum values"},
+		},
+		{
+			name: "pattern added",
+			proposed: `{"type": "object", "properties": {"id": {"type": "integer"}, "name": {"type": "string", "pattern": "^[a-z]+$"},
+				"color": {"type": "string", "enum": ["red", "blue"]}}, "required": ["id"]}`,
+			backward: []string{`field 'name' pattern changed from "" to "^[a-z]+$"`},
+			full:     []string{`field 'name' pattern changed from "" to "^[a-z]+$"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, mode := range []struct {
+				name string
+				want []string
+			}{{CompatBackward, tt.backward}, {CompatForward, tt.forward}, {CompatFull, tt.full}} {
+				got := schemaChanges(json.RawMessage(compatBase), json.RawMessage(tt.proposed), mode.name)
+				if strings.Join(got, "; ") != strings.Join(mode.want, "; ") {
+					t.Errorf("%s: got %q, want %q", mode.name, got, mode.want)
+				}
+			}
+			if got := schemaChanges(json.RawMessage(compatBase), json.RawMessage(tt.proposed), CompatNone); got != nil {
+				t.Errorf("NONE: got %q, want no changes", got)
+			}
+		})
+	}
+}
+
+func TestSchemaChangesTypeNarrowed(t *testing.T) {
+	current := `{"type": "object", "properties": {"id": {"type": "number"}}}`
+	proposed := `{"type": "object", "properties": {"id": {"type": "integer"}}}`
+	want := "field 'id' type narrowed from number to integer"
+	for _, mode := range []string{CompatBackward, CompatFull} {
+		if got := schemaChanges(json.RawMessage(current), json.RawMessage(proposed), mode); strings.Join(got, "; ") != want {
+			t.Errorf("%s: got %q, want %q", mode, got, want)
+		}
+	}
+	if got := schemaChanges(json.RawMessage(current), json.RawMessage(proposed), CompatForward); len(got) != 0 {
+		t.Errorf("FORWARD: got %q, want no changes", got)
+	}
+}
+
+func TestTypesCover(t *testing.T) {
+	tests := []struct {
+		outer, inner []string
+		want         bool
+	}{
+		{nil, []string{"string"}, true},
+		{[]string{"string"}, nil, false},
+		{[]string{"number"}, []string{"integer"}, true},
+		{[]string{"integer"}, []string{"number"}, false},
+		{[]string{"string", "null"}, []string{"string"}, true},
+		{[]string{"string"}, []string{"string", "null"}, false},
+	}
+	for _, tt := range tests {
+		if got := typesCover(tt.outer, tt.inner); got != tt.want {
+			t.Errorf("typesCover(%q, %q) = %v, want %v", tt.outer, tt.inner, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// ComputedField is a field a model adds to every produced row: either a
+// constant Value or one of computedBuiltins.
+type ComputedField struct {
+	Name    string          `json:"name"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Builtin string          `json:"builtin,omitempty"`
+}
+
+// computedBuiltins are the values a computed field can take from the row
+// being produced.
+var computedBuiltins = []string{"now()", "job_id", "row_number", "source_filename"}
+
+// rowContext identifies the row being encoded, for computed fields.
+type rowContext struct {
+	JobID string
+	Row   int    // as reported in the DLQ
+	File  string // uploaded file name; "" for retries
+}
+
+func (c ComputedField) value(rc rowContext) interface{} {
+	switch c.Builtin {
+	case "now()":
+		return time.Now().UTC().Format(time.RFC3339Nano)
+	case "job_id":
+		return rc.JobID
+	case "row_number":
+		return rc.Row
+	case "source_filename":
+		return rc.File
+	}
+	return c.Value
+}
+
+// addComputed adds the computed fields to a row's output names and
+// values. A column wins over a computed field of the same name unless its
+// value is empty, in which case the computed value fills it.
+func addComputed(names []string, values []interface{}, computed []ComputedField, rc rowContext) ([]string, []interface{}) {
+	names = append(make([]string, 0, len(values)+len(computed)), names...)
+	for len(names) < len(values) {
+		names = append(names, "")
+	}
+	values = append(make([]interface{}, 0, len(names)+len(computed)), values...)
+	for _, c := range computed {
+		if i := indexOf(names, c.Name); i >= 0 {
+			if values[i] == nil || values[i] == "" {
+				values[i] = c.value(rc)
+			}
+			continue
+		}
+		names = append(names, c.Name)
+		values = append(values, c.value(rc))
+	}
+	return names, values
+}
+
+// validateComputed checks the model's computed fields.
+func validateComputed(m Model) error {
+	seen := map[string]bool{}
+	for i, c := range m.Computed {
+		field := "computed[" + strconv.Itoa(i) + "]"
+		switch {
+		case c.Name == "":
+			return invalid("INVALID_COMPUTED_FIELD", field+" has no name")
+		case seen[c.Name]:
+			return invalid("INVALID_COMPUTED_FIELD", "computed field '"+c.Name+"' is declared twice")
+		case (c.Builtin == "") == (len(c.Value) == 0):
+			return invalid("INVALID_COMPUTED_FIELD", "computed field '"+c.Name+"' needs exactly one of value or builtin")
+		case c.Builtin != "" && indexOf(computedBuiltins, c.Builtin) < 0:
+			return invalid("INVALID_COMPUTED_FIELD", "computed field '"+c.Name+"' has unknown builtin '"+c.Builtin+"'; use now(), job_id, row_number or source_filename")
+		}
+		seen[c.Name] = true
+	}
+	if len(m.Computed) > 0 && m.Defaults != nil && (m.Defaults.OutputFormat == OutputAvro || m.Defaults.OutputFormat == OutputProtobuf) {
+		return errComputedTyped
+	}
+	return nil
+}
+
+var errComputedTyped = invalid("INVALID_OUTPUT_FORMAT", "avro and protobuf output do not support computed fields; use array or object")
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight reply.
+const corsMaxAge = "600"
+
+// corsMiddleware lets browser apps on the origins in CORS_ALLOWED_ORIGINS
+// (comma-separated, or * for any) call the API. It answers their preflight
+// OPTIONS requests itself, ahead of authentication since browsers send
+// those without credentials, and marks other responses readable by them.
+// CORS_ALLOWED_METHODS and CORS_ALLOWED_HEADERS set what a preflight
+// allows. With CORS_ALLOWED_ORIGINS unset, the default, nothing changes.
+func corsMiddleware() mux.MiddlewareFunc {
+	origins := envList("CORS_ALLOWED_ORIGINS", "")
+	for i, o := range origins {
+		origins[i] = strings.TrimSuffix(o, "/")
+	}
+	methods := strings.Join(envList("CORS_ALLOWED_METHODS", "GET,POST,PUT,DELETE"), ", ")
+	headers := strings.Join(envList("CORS_ALLOWED_HEADERS",
+		"Authorization,Content-Type,X-Namespace,X-Request-ID,If-None-Match,Prefer"), ", ")
+	anyOrigin := indexOf(origins, "*") >= 0
+
+	return func(next http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+			if origin == "" || (!anyOrigin && indexOf(origins, origin) < 0) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			// Scripts can only read the headers they are told about
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, ETag, Content-Disposition")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// envList splits the comma-separated environment variable key, dropping
+// blank entries, or def when key is unset.
+func envList(key, def string) []string {
+	var list []string
+	for _, v := range strings.Split(getenv(key, def), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
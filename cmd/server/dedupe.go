@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"os"
+)
+
+// Row deduplication remembers a 16-byte hash of every key value a job has
+// produced. The first DEDUPE_MEMORY_KEYS (default 1M, roughly 40 MB) are
+// held in memory; later ones go to an on-disk hash table in the temp
+// directory. Past DEDUPE_MAX_KEYS (default 10M) the job stops
+// deduplicating and records a warning.
+var (
+	dedupeMemoryKeys = envInt("DEDUPE_MEMORY_KEYS", 1_000_000)
+	dedupeMaxKeys    = envInt("DEDUPE_MAX_KEYS", 10_000_000)
+)
+
+type dedupeKey [16]byte
+
+// dedupeSet tracks the key values seen by one job.
+type dedupeSet struct {
+	mem      map[dedupeKey]struct{}
+	disk     *diskKeySet // created once mem is full
+	n        int
+	disabled bool
+	warn     func(msg string) // called once if deduplication stops
+}
+
+func newDedupeSet(warn func(msg string)) *dedupeSet {
+	return &dedupeSet{mem: map[dedupeKey]struct{}{}, warn: warn}
+}
+
+// Seen reports whether value was added before. Empty values are never
+// duplicates. Once the set is disabled every value is new.
+func (d *dedupeSet) Seen(value string) bool {
+	if d.disabled || value == "" {
+		return false
+	}
+	k := hashDedupeKey(value)
+	if _, ok := d.mem[k]; ok {
+		return true
+	}
+	if d.disk == nil {
+		return false
+	}
+	found, _, err := d.disk.find(k)
+	if err != nil {
+		d.disable("deduplication stopped: " + err.Error())
+		return false
+	}
+	return found
+}
+
+// Add records value once its row has been produced, so a row that failed
+// to be written does not make later copies duplicates.
+func (d *dedupeSet) Add(value string) {
+	if d.disabled || value == "" {
+		return
+	}
+	k := hashDedupeKey(value)
+	if _, ok := d.mem[k]; ok {
+		return
+	}
+	var slot int64 = -1 // where k goes in the on-disk table
+	if d.disk != nil {
+		found, off, err := d.disk.find(k)
+		if err != nil {
+			d.disable("deduplication stopped: " + err.Error())
+			return
+		}
+		if found {
+			return
+		}
+		slot = off
+	}
+	if d.n >= dedupeMaxKeys {
+		d.disable(fmt.Sprintf("deduplication stopped after %d distinct keys (DEDUPE_MAX_KEYS); later duplicates were produced", d.n))
+		return
+	}
+	if d.n < dedupeMemoryKeys {
+		d.mem[k] = struct{}{}
+		d.n++
+		return
+	}
+	if d.disk == nil {
+		disk, err := newDiskKeySet(dedupeMaxKeys - dedupeMemoryKeys)
+		if err != nil {
+			d.disable("deduplication stopped: " + err.Error())
+			return
+		}
+		d.disk = disk
+		_, slot, err = disk.find(k)
+		if err != nil {
+			d.disable("deduplication stopped: " + err.Error())
+			return
+		}
+	}
+	if err := d.disk.insert(k, slot); err != nil {
+		d.disable("deduplication stopped: " + err.Error())
+		return
+	}
+	d.n++
+}
+
+func hashDedupeKey(value string) dedupeKey {
+	h := fnv.New128a()
+	h.Write([]byte(value))
+	var k dedupeKey
+	h.Sum(k[:0])
+	return k
+}
+
+func (d *dedupeSet) disable(msg string) {
+	d.disabled = true
+	d.Close()
+	if d.warn != nil {
+		d.warn(msg)
+	}
+}
+
+// Close releases the set's memory and removes its on-disk table.
+func (d *dedupeSet) Close() {
+	d.mem = nil
+	if d.disk != nil {
+		d.disk.close()
+		d.disk = nil
+	}
+}
+
+// diskKeySet is a fixed-size, linear-probing hash table of dedupeKeys in a
+// sparse temporary file. An all-zero slot is empty.
+type diskKeySet struct {
+	f     *os.File
+	slots uint64
+}
+
+// newDiskKeySet creates a table for up to n keys at half load.
+func newDiskKeySet(n int) (*diskKeySet, error) {
+	f, err := os.CreateTemp("", "batch-dedupe-*")
+	if err != nil {
+		return nil, err
+	}
+	slots := uint64(2 * n)
+	if err := f.Truncate(int64(slots) * int64(len(dedupeKey{}))); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &diskKeySet{f: f, slots: slots}, nil
+}
+
+// find looks k up, returning whether it is present and otherwise the
+// offset of the empty slot it belongs in.
+func (s *diskKeySet) find(k dedupeKey) (bool, int64, error) {
+	k = s.stored(k)
+	var slot dedupeKey
+	i := binary.LittleEndian.Uint64(k[:8]) % s.slots
+	for {
+		off := int64(i) * int64(len(slot))
+		if _, err := s.f.ReadAt(slot[:], off); err != nil {
+			return false, 0, err
+		}
+		if slot == (dedupeKey{}) {
+			return false, off, nil
+		}
+		if slot == k {
+			return true, off, nil
+		}
+		i = (i + 1) % s.slots
+	}
+}
+
+// insert writes k into the empty slot at off found by find.
+func (s *diskKeySet) insert(k dedupeKey, off int64) error {
+	k = s.stored(k)
+	_, err := s.f.WriteAt(k[:], off)
+	return err
+}
+
+// stored keeps the all-zero empty-slot marker free of real keys.
+func (s *diskKeySet) stored(k dedupeKey) dedupeKey {
+	if k == (dedupeKey{}) {
+		k[len(k)-1] = 1
+	}
+	return k
+}
+
+func (s *diskKeySet) close() {
+	s.f.Close()
+	os.Remove(s.f.Name())
+}
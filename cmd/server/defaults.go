@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/keithchambers/batch-ingestion/internal/rowschema"
+)
+
+// ModelDefaults are ingestion settings stored on a model. Jobs for the
+// model start from them; any setting the job request gives wins.
+type ModelDefaults struct {
+	// TargetTopic may use {model_id}, {model_name} and {version}, which
+	// are filled in from the model version the job uses.
+	TargetTopic   string `json:"target_topic,omitempty"`
+	KeyColumn     string `json:"key_column,omitempty"`
+	OutputFormat  string `json:"output_format,omitempty"`
+	CleanupPolicy string `json:"cleanup_policy,omitempty"`
+}
+
+// applyModelDefaults fills the settings f leaves empty from m's defaults.
+func applyModelDefaults(f jobFields, m Model) jobFields {
+	d := m.Defaults
+	if d == nil {
+		return f
+	}
+	if f.TargetTopic == "" {
+		f.TargetTopic = expandTopicTemplate(d.TargetTopic, m)
+	}
+	if f.KeyColumn == "" {
+		f.KeyColumn = d.KeyColumn
+	}
+	if f.OutputFormat == "" {
+		f.OutputFormat = d.OutputFormat
+	}
+	if f.CleanupPolicy == "" {
+		f.CleanupPolicy = d.CleanupPolicy
+	}
+	return f
+}
+
+func expandTopicTemplate(tmpl string, m Model) string {
+	if tmpl == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{model_id}", m.ID,
+		"{model_name}", m.Name,
+		"{version}", strconv.Itoa(m.Version),
+	).Replace(tmpl)
+}
+
+// validateModel checks the settings a model carries besides its schema.
+func validateModel(m Model) error {
+	if err := validateModelDefaults(m); err != nil {
+		return err
+	}
+	if err := validateMapping(m); err != nil {
+		return err
+	}
+	if err := validateNulls(m); err != nil {
+		return err
+	}
+	return validateComputed(m)
+}
+
+// validateModelDefaults checks m's defaults by the same rules as a job
+// request, so a job that relies on them cannot be refused for them later.
+// m must already have its ID, name and version.
+func validateModelDefaults(m Model) error {
+	if m.Defaults == nil {
+		return nil
+	}
+	if _, err := parseJobOptions(applyModelDefaults(jobFields{}, m), ""); err != nil {
+		var rerr *requestError
+		if errors.As(err, &rerr) {
+			return invalid(rerr.Code, "defaults: "+rerr.Message)
+		}
+		return err
+	}
+	if k := m.Defaults.KeyColumn; k != "" {
+		if cols := rowschema.Columns(m.Schema); len(cols) > 0 && indexOf(cols, k) < 0 {
+			return invalid("UNKNOWN_KEY_COLUMN", "defaults: key_column '"+k+"' is not a property of the schema")
+		}
+	}
+	return nil
+}
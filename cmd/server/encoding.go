@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// inputEncodings maps the accepted values of the encoding field to their
+// decoders. UTF-8 needs no decoder.
+var inputEncodings = map[string]encoding.Encoding{
+	"utf-8":        nil,
+	"utf8":         nil,
+	"latin1":       charmap.ISO8859_1,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"windows-1252": charmap.Windows1252,
+	"cp1252":       charmap.Windows1252,
+	"utf-16":       unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf-16le":     unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf-16be":     unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+}
+
+// inputEncoding resolves an encoding field value; "" is UTF-8.
+func inputEncoding(name string) (encoding.Encoding, error) {
+	if name == "" {
+		return nil, nil
+	}
+	enc, ok := inputEncodings[strings.ToLower(name)]
+	if !ok {
+		return nil, invalid("UNSUPPORTED_ENCODING", "encoding must be one of utf-8, latin1, windows-1252, utf-16, utf-16le or utf-16be")
+	}
+	return enc, nil
+}
+
+// decodeInput converts r to UTF-8. A leading byte order mark is dropped and,
+// when it names UTF-8 or UTF-16, takes precedence over enc.
+func decodeInput(r io.Reader, enc encoding.Encoding) io.Reader {
+	var t transform.Transformer = transform.Nop
+	if enc != nil {
+		t = enc.NewDecoder()
+	}
+	return transform.NewReader(r, unicode.BOMOverride(t))
+}
+
+// validText reports whether every cell decoded cleanly. Raw UTF-8 input is
+// checked for invalid sequences; decoders mark bytes they cannot map with
+// U+FFFD.
+func validText(rec []string, decoded bool) bool {
+	for _, cell := range rec {
+		if !utf8.ValidString(cell) || (decoded && strings.ContainsRune(cell, utf8.RuneError)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	eventsPollInterval = 250 * time.Millisecond
+	eventsHeartbeat    = 5 * time.Second
+)
+
+// jobEvents streams JobStatus snapshots as Server-Sent Events. A snapshot is
+// pushed whenever the state or totals change and at least every
+// eventsHeartbeat; the stream ends after the terminal snapshot.
+func jobEvents(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	snapshot := func() (JobStatus, []byte, bool) {
+		jobsMu.RLock()
+		defer jobsMu.RUnlock()
+		j, ok := scopedJob(r, id)
+		if !ok {
+			return JobStatus{}, nil, false
+		}
+		body, _ := json.Marshal(j)
+		return *j, body, true
+	}
+
+	job, body, ok := snapshot()
+	if !ok {
+		notFound(w, "JOB_NOT_FOUND", "job not found")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		internalError(w, r, fmt.Errorf("streaming unsupported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(body []byte) {
+		fmt.Fprintf(w, "event: status\ndata: %s\n\n", body)
+		flusher.Flush()
+	}
+	send(body)
+	last, lastSent := job, time.Now()
+
+	ticker := time.NewTicker(eventsPollInterval)
+	defer ticker.Stop()
+	for !last.State.Terminal() {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		job, body, ok := snapshot()
+		if !ok {
+			return // reaped or purged while streaming
+		}
+		if job.State != last.State || job.Totals != last.Totals || time.Since(lastSent) >= eventsHeartbeat {
+			send(body)
+			last, lastSent = job, time.Now()
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// Exactly-once jobs produce their rows in Kafka transactions. kafka-go
+// cannot write transactional batches, so these jobs use a franz-go client
+// of their own; everything else, including their DLQ, goes through
+// kafkaWriter. A transaction is committed every TXN_BATCH_ROWS rows
+// (default 1000), once it has been open txnBatchInterval, and at the end
+// of each input file.
+var txnBatchRows = envInt("TXN_BATCH_ROWS", 1000)
+
+// txnBatchInterval keeps a slow (e.g. rate-limited) job's transactions
+// well inside the broker's transaction timeout.
+const txnBatchInterval = 5 * time.Second
+
+// txnRow is a row in the open transaction, kept so it can go to the DLQ if
+// the transaction is aborted.
+type txnRow struct {
+	Number int
+	Raw    string
+}
+
+// txnProducer writes one job's rows into its main topic transactionally.
+type txnProducer struct {
+	client  *kgo.Client
+	logger  *slog.Logger
+	pending []txnRow // rows in the open transaction
+	opened  time.Time
+
+	mu  sync.Mutex
+	err error // first failed write in the open transaction
+}
+
+// txnID is the job's transactional ID. It is stable for the job, so a
+// transaction it leaves open is fenced and aborted when the ID is reused,
+// or by the broker once the transaction times out.
+func txnID(jobID string) string { return "batch-txn-" + jobID }
+
+func newTxnProducer(jobID, topic string, logger *slog.Logger) (*txnProducer, error) {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(kafkaBrokers()...),
+		kgo.DialTimeout(kafkaDialer.Timeout),
+		kgo.TransactionalID(txnID(jobID)),
+		kgo.DefaultProduceTopic(topic),
+		// A write that cannot be delivered fails its transaction rather
+		// than holding the job until it times out
+		kgo.RecordDeliveryTimeout(30 * time.Second),
+	}
+	if kafkaDialer.TLS != nil {
+		opts = append(opts, kgo.DialTLSConfig(kafkaDialer.TLS))
+	}
+	if mech := txnSASL(); mech != nil {
+		opts = append(opts, kgo.SASL(mech))
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &txnProducer{client: client, logger: logger}, nil
+}
+
+// txnSASL returns the KAFKA_SASL_* settings as a franz-go mechanism. The
+// name was already checked by newKafkaDialer.
+func txnSASL() sasl.Mechanism {
+	user := getenv("KAFKA_SASL_USERNAME", "")
+	pass := getenv("KAFKA_SASL_PASSWORD", "")
+	switch strings.ToUpper(getenv("KAFKA_SASL_MECHANISM", "")) {
+	case "PLAIN":
+		return plain.Auth{User: user, Pass: pass}.AsMechanism()
+	case "SCRAM-SHA-256":
+		return scram.Auth{User: user, Pass: pass}.AsSha256Mechanism()
+	case "SCRAM-SHA-512":
+		return scram.Auth{User: user, Pass: pass}.AsSha512Mechanism()
+	}
+	return nil
+}
+
+// produce adds a row to the open transaction, beginning one if needed.
+// Write errors surface when the transaction is committed.
+func (p *txnProducer) produce(ctx context.Context, key, value []byte, row txnRow) error {
+	if len(p.pending) == 0 {
+		if err := p.client.BeginTransaction(); err != nil {
+			return err
+		}
+		p.opened = time.Now()
+	}
+	p.pending = append(p.pending, row)
+	p.client.Produce(ctx, &kgo.Record{Key: key, Value: value}, func(_ *kgo.Record, err error) {
+		if err != nil {
+			p.mu.Lock()
+			if p.err == nil {
+				p.err = err
+			}
+			p.mu.Unlock()
+		}
+	})
+	return nil
+}
+
+// due reports whether the open transaction should be committed.
+func (p *txnProducer) due() bool {
+	return len(p.pending) >= txnBatchRows || (len(p.pending) > 0 && time.Since(p.opened) >= txnBatchInterval)
+}
+
+// commit ends the open transaction, committing it if every row in it was
+// written and aborting it otherwise. It returns the transaction's rows and
+// the error that aborted them, if any.
+func (p *txnProducer) commit(ctx context.Context) ([]txnRow, error) {
+	rows := p.pending
+	p.pending = nil
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	err := p.client.Flush(ctx)
+	p.mu.Lock()
+	if err == nil {
+		err = p.err
+	}
+	p.err = nil
+	p.mu.Unlock()
+	if err == nil {
+		if err = p.client.EndTransaction(ctx, kgo.TryCommit); err == nil {
+			return rows, nil
+		}
+	}
+	p.abort()
+	return rows, err
+}
+
+// abort drops the open transaction. It does not use the job's context, so
+// a cancelled job still aborts cleanly instead of leaving the transaction
+// for the broker to time out.
+func (p *txnProducer) abort() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := p.client.AbortBufferedRecords(ctx)
+	if err == nil {
+		err = p.client.EndTransaction(ctx, kgo.TryAbort)
+	}
+	if err != nil {
+		p.logger.Error("failed to abort Kafka transaction; the broker aborts it on timeout", "error", err)
+	}
+}
+
+func (p *txnProducer) close() {
+	if len(p.pending) > 0 {
+		p.abort()
+	}
+	p.client.Close()
+}
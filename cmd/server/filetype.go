@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+)
+
+// Input file types.
+const (
+	FileCSV     = "csv"
+	FileNDJSON  = "ndjson"
+	FileParquet = "parquet"
+	FileORC     = "orc"
+)
+
+// sniffBytes is how much of a file, after decompression and decoding, the
+// text heuristic looks at.
+const sniffBytes = 4096
+
+// maxBinaryRatio is the share of non-printable characters above which a
+// file is treated as binary rather than text.
+const maxBinaryRatio = 0.05
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// detectFileType classifies f by its content and filename: Parquet by its
+// magic and ORC by the magic at both ends, then, once any gzip layer is removed, text that is NDJSON or
+// CSV. Anything else is UNSUPPORTED_FILE_TYPE. f is rewound.
+func detectFileType(f io.ReadSeeker, filename string, enc encoding.Encoding) (fileType string, gzipped bool, err error) {
+	magic, err := readHead(f, 4)
+	if err != nil {
+		return "", false, err
+	}
+	if string(magic) == "PAR1" {
+		return FileParquet, false, nil
+	}
+	if strings.HasPrefix(string(magic), orcMagic) {
+		// Text can start with the magic too, e.g. a CSV header "ORCID,..."
+		isORC, err := hasORCPostscript(f)
+		if err != nil {
+			return "", false, err
+		}
+		if isORC {
+			return FileORC, false, nil
+		}
+	}
+
+	name := strings.ToLower(filename)
+	gzipped = bytes.HasPrefix(magic, gzipMagic)
+	if gzipped {
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	head, err := sniff(f, JobOptions{Gzip: gzipped, Encoding: enc})
+	if err != nil {
+		return "", false, err
+	}
+	if !looksLikeText(head) {
+		return "", false, invalid("UNSUPPORTED_FILE_TYPE", "file is not CSV, NDJSON, Parquet or ORC (text optionally gzip-compressed); binary content found")
+	}
+
+	first := bytes.TrimLeftFunc(head, unicode.IsSpace)
+	switch filepath.Ext(name) {
+	case ".csv", ".tsv", ".txt":
+		return FileCSV, gzipped, nil
+	case ".ndjson", ".jsonl":
+		return FileNDJSON, gzipped, nil
+	case ".json":
+		if len(first) > 0 && first[0] != '{' {
+			return "", false, invalid("UNSUPPORTED_FILE_TYPE", "JSON input must be newline-delimited objects (NDJSON), not a single document")
+		}
+		return FileNDJSON, gzipped, nil
+	}
+	if len(first) > 0 && first[0] == '{' {
+		return FileNDJSON, gzipped, nil
+	}
+	return FileCSV, gzipped, nil
+}
+
+// parseDelimiter reads the delimiter job setting: one character, or "tab"
+// or "\t" for a tab. Empty leaves the choice to the file name, so 0 is
+// returned.
+func parseDelimiter(s string) (rune, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size != len(s) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, invalid("INVALID_DELIMITER", "delimiter must be a single character other than a quote or line break, or tab")
+	}
+	return r, nil
+}
+
+// fileDelimiter returns the CSV field separator for a file: the job's
+// delimiter if it set one, a tab for .tsv files, and a comma otherwise.
+func fileDelimiter(filename string, delimiter rune) rune {
+	if delimiter != 0 {
+		return delimiter
+	}
+	if filepath.Ext(strings.TrimSuffix(strings.ToLower(filename), ".gz")) == ".tsv" {
+		return '\t'
+	}
+	return ','
+}
+
+// readHead returns up to n bytes from the start of f, fewer for a shorter
+// file, and rewinds it.
+func readHead(f io.ReadSeeker, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, invalid("READ_ERROR", err.Error())
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// sniff returns the start of f as the job will read it, decompressed and
+// decoded to UTF-8, and rewinds f.
+func sniff(f io.ReadSeeker, opts JobOptions) ([]byte, error) {
+	r, err := openInput(f, opts)
+	if err != nil {
+		return nil, invalid("UNSUPPORTED_FILE_TYPE", "invalid gzip stream: "+err.Error())
+	}
+	buf := make([]byte, sniffBytes)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, invalid("UNSUPPORTED_FILE_TYPE", "unreadable input: "+err.Error())
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// looksLikeText reports whether b is UTF-8 made almost entirely of
+// printable characters and whitespace. A character cut off at the end of
+// b is ignored.
+func looksLikeText(b []byte) bool {
+	total, binary := 0, 0
+	for len(b) > 0 {
+		if !utf8.FullRune(b) {
+			break
+		}
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		total++
+		if r == 0 || (r == utf8.RuneError && size == 1) || (!unicode.IsPrint(r) && !unicode.IsSpace(r)) {
+			binary++
+		}
+	}
+	return float64(binary) <= maxBinaryRatio*float64(total)
+}
+
+// openInput returns r decompressed and decoded to UTF-8 as opts describe.
+func openInput(r io.Reader, opts JobOptions) (io.Reader, error) {
+	if opts.Gzip {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	}
+	return decodeInput(r, opts.Encoding), nil
+}
+
+// rowReader yields the records of one input file.
+type rowReader interface {
+	// Read returns the next record, a *csv.ParseError or *RowError for a
+	// record that cannot be parsed, io.EOF at the end, or any other error
+	// when the input itself cannot be read.
+	Read() ([]string, error)
+	// Raw returns the text of the last record read, as kept in the DLQ.
+	Raw(rec []string) string
+}
+
+// openRows opens in for reading records. ORC is read in place, since its
+// footer has to be read first; other types are decompressed and decoded
+// as a stream.
+func openRows(in jobInput) (rowReader, error) {
+	if in.Opts.FileType == FileORC {
+		return newORCRows(in.R, in.Opts.Columns)
+	}
+	r, err := openInput(in.R, in.Opts)
+	if err != nil {
+		return nil, err
+	}
+	return newRowReader(r, in.Opts), nil
+}
+
+// newRowReader reads r as opts.FileType; Parquet is not decoded yet and
+// is read as CSV.
+func newRowReader(r io.Reader, opts JobOptions) rowReader {
+	if opts.FileType == FileNDJSON {
+		return &ndjsonRows{r: bufio.NewReader(r), columns: opts.Columns}
+	}
+	rl := newCSVReader(r, opts)
+	if len(opts.Columns) > 0 {
+		// Row width is checked against the columns in rowPayload
+		rl.FieldsPerRecord = -1
+	}
+	return csvRows{rl}
+}
+
+// newCSVReader returns a CSV reader for r split on the job's delimiter.
+func newCSVReader(r io.Reader, opts JobOptions) *csv.Reader {
+	rl := csv.NewReader(r)
+	if opts.Delimiter != 0 {
+		rl.Comma = opts.Delimiter
+	}
+	return rl
+}
+
+type csvRows struct{ *csv.Reader }
+
+func (c csvRows) Raw(rec []string) string { return strings.Join(rec, string(c.Comma)) }
+
+// ndjsonRows turns each JSON object line into a record in column order.
+// Strings are taken as they are, null or absent keys become empty cells
+// and other values keep their JSON text, so rows are typed by the same
+// rules as CSV cells. Keys outside columns are ignored and blank lines
+// skipped.
+type ndjsonRows struct {
+	r       *bufio.Reader
+	columns []string
+	line    string
+}
+
+func (n *ndjsonRows) Read() ([]string, error) {
+	for {
+		line, err := n.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+		n.line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(n.line) != "" {
+			break
+		}
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(n.line), &obj); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, &RowError{Type: ErrorTypeParse, Message: "INVALID_JSON: line is not a JSON object"}
+		}
+		return nil, &RowError{Type: ErrorTypeParse, Message: "INVALID_JSON: " + err.Error()}
+	}
+	if obj == nil {
+		return nil, &RowError{Type: ErrorTypeParse, Message: "INVALID_JSON: line is not a JSON object"}
+	}
+	rec := make([]string, len(n.columns))
+	for i, col := range n.columns {
+		v, ok := obj[col]
+		if !ok || string(v) == "null" {
+			continue
+		}
+		var s string
+		if json.Unmarshal(v, &s) == nil {
+			rec[i] = s
+		} else {
+			rec[i] = string(v)
+		}
+	}
+	return rec, nil
+}
+
+func (n *ndjsonRows) Raw([]string) string { return n.line }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/scritchley/orc"
+)
+
+func TestDetectFileTypeORC(t *testing.T) {
+	schema, err := orc.ParseSchema("struct<id:int,name:string>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := orc.NewWriter(&buf, orc.SetSchema(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(int64(1), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		filename string
+		want     string
+	}{
+		{"ORC file", buf.Bytes(), "data.orc", FileORC},
+		{"CSV header starting with the magic", []byte("ORCID,name\n0000-0001,a\n"), "people.csv", FileCSV},
+		{"magic alone", []byte("ORC"), "data", FileCSV},
+		{"NDJSON starting with the magic", []byte("ORC{}\n"), "data.txt", FileCSV},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := detectFileType(bytes.NewReader(tt.data), tt.filename, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("detectFileType = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
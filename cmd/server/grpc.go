@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/keithchambers/batch-ingestion/internal/batchpb"
+)
+
+// grpcServer serves proto/batch/v1/batch.proto on GRPC_PORT. Every call is
+// dispatched in-process to the REST handler for the same operation, through
+// the same middleware, so authentication, namespaces, validation, quotas
+// and the audit log behave exactly as they do over REST. Only the network
+// HTTP layer and JSON on the wire are skipped.
+type grpcServer struct {
+	batchpb.UnimplementedBatchIngestionServer
+	handler http.Handler
+}
+
+// newGRPCServer returns a gRPC server and its listener on GRPC_PORT, or
+// nils when GRPC_PORT is unset.
+func newGRPCServer(handler http.Handler) (*grpc.Server, net.Listener, error) {
+	port := getenv("GRPC_PORT", "")
+	if port == "" {
+		return nil, nil, nil
+	}
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv := grpc.NewServer()
+	batchpb.RegisterBatchIngestionServer(srv, &grpcServer{handler: handler})
+	return srv, lis, nil
+}
+
+// grpcResponse collects what a REST handler writes.
+type grpcResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *grpcResponse) Header() http.Header { return w.header }
+
+func (w *grpcResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *grpcResponse) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// do runs one REST request for ctx's gRPC call and returns the body of a
+// successful reply. Incoming metadata becomes request headers, and the
+// reply's X-Request-ID is sent back as metadata.
+func (s *grpcServer) do(ctx context.Context, method, path, contentType string, body io.Reader) ([]byte, error) {
+	r, err := http.NewRequestWithContext(ctx, method, path, body)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			if strings.HasPrefix(key, ":") || key == "content-type" {
+				continue
+			}
+			for _, v := range values {
+				r.Header.Add(key, v)
+			}
+		}
+	}
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	w := &grpcResponse{header: http.Header{}}
+	s.handler.ServeHTTP(w, r)
+	if id := w.header.Get("X-Request-ID"); id != "" {
+		_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+	}
+	if w.status >= 300 {
+		return nil, grpcError(w)
+	}
+	return w.body.Bytes(), nil
+}
+
+// doJSON sends in as the JSON body of a REST request and decodes the reply
+// into out.
+func (s *grpcServer) doJSON(ctx context.Context, method, path string, in, out proto.Message) error {
+	var body io.Reader
+	if in != nil {
+		b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(in)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		body = bytes.NewReader(b)
+	}
+	reply, err := s.do(ctx, method, path, "application/json", body)
+	if err != nil {
+		return err
+	}
+	return decodeReply(reply, out)
+}
+
+// decodeReply decodes a REST JSON reply into out. The messages share the
+// JSON field names, and fields the proto does not have are dropped.
+func decodeReply(reply []byte, out proto.Message) error {
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(reply, out); err != nil {
+		return status.Error(codes.Internal, "decoding reply: "+err.Error())
+	}
+	return nil
+}
+
+// grpcCodes maps the REST status codes the handlers use onto gRPC codes.
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+}
+
+// grpcError turns a REST error reply into a gRPC status whose message
+// starts with the REST error code.
+func grpcError(w *grpcResponse) error {
+	code, ok := grpcCodes[w.status]
+	if !ok {
+		code = codes.Internal
+	}
+	var e ErrorResponse
+	if err := json.Unmarshal(w.body.Bytes(), &e); err != nil || e.Error == "" {
+		return status.Error(code, strings.TrimSpace(w.body.String()))
+	}
+	return status.Error(code, e.Error+": "+e.Message)
+}
+
+func (s *grpcServer) ListModels(ctx context.Context, _ *batchpb.ListModelsRequest) (*batchpb.ListModelsResponse, error) {
+	reply, err := s.do(ctx, "GET", "/models", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	// GET /models replies with a bare array
+	out := &batchpb.ListModelsResponse{}
+	return out, decodeReply([]byte(`{"models":`+string(reply)+`}`), out)
+}
+
+func (s *grpcServer) GetModel(ctx context.Context, in *batchpb.GetModelRequest) (*batchpb.Model, error) {
+	path := "/models/" + url.PathEscape(in.GetId())
+	if in.GetVersion() != 0 {
+		path += "?version=" + strconv.Itoa(int(in.GetVersion()))
+	}
+	out := &batchpb.Model{}
+	return out, s.doJSON(ctx, "GET", path, nil, out)
+}
+
+func (s *grpcServer) CreateModel(ctx context.Context, in *batchpb.CreateModelRequest) (*batchpb.Model, error) {
+	out := &batchpb.Model{}
+	return out, s.doJSON(ctx, "POST", "/models", in, out)
+}
+
+func (s *grpcServer) UpdateModel(ctx context.Context, in *batchpb.UpdateModelRequest) (*batchpb.Model, error) {
+	q := url.Values{}
+	if in.GetForce() {
+		q.Set("force", "true")
+	}
+	if in.GetCompatibility() != "" {
+		q.Set("compatibility", in.GetCompatibility())
+	}
+	path := "/models/" + url.PathEscape(in.GetId())
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	body := &batchpb.CreateModelRequest{
+		Name:     in.GetName(),
+		Schema:   in.GetSchema(),
+		Defaults: in.GetDefaults(),
+		Mapping:  in.GetMapping(),
+		Computed: in.GetComputed(),
+		Nulls:    in.GetNulls(),
+	}
+	out := &batchpb.Model{}
+	return out, s.doJSON(ctx, "PUT", path, body, out)
+}
+
+func (s *grpcServer) DeleteModel(ctx context.Context, in *batchpb.DeleteModelRequest) (*emptypb.Empty, error) {
+	path := "/models/" + url.PathEscape(in.GetId())
+	if in.GetForce() {
+		path += "?force=true"
+	}
+	_, err := s.do(ctx, "DELETE", path, "", nil)
+	return &emptypb.Empty{}, err
+}
+
+// CreateJob streams the files into a multipart POST /jobs as they arrive,
+// so an upload is never held in memory in full.
+func (s *grpcServer) CreateJob(stream batchpb.BatchIngestion_CreateJobServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	settings := first.GetSettings()
+	if settings == nil {
+		return status.Error(codes.InvalidArgument, "MISSING_SETTINGS: the first message must hold the job settings")
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close() // unblocks the writer if the handler stops reading early
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeJobForm(mw, settings, stream))
+	}()
+
+	reply, err := s.do(stream.Context(), "POST", "/jobs", mw.FormDataContentType(), pr)
+	if err != nil {
+		return err
+	}
+	out := &batchpb.CreateJobResponse{}
+	if err := decodeReply(reply, out); err != nil {
+		return err
+	}
+	return stream.SendAndClose(out)
+}
+
+// writeJobForm writes settings and the files that follow them on stream as
+// the multipart form of POST /jobs.
+func writeJobForm(mw *multipart.Writer, settings *batchpb.JobSettings, stream batchpb.BatchIngestion_CreateJobServer) error {
+	fields := map[string]string{
+		"model_id":         settings.GetModelId(),
+		"output_format":    settings.GetOutputFormat(),
+		"callback_url":     settings.GetCallbackUrl(),
+		"timeout":          settings.GetTimeout(),
+		"encoding":         settings.GetEncoding(),
+		"dedupe_column":    settings.GetDedupeColumn(),
+		"key_column":       settings.GetKeyColumn(),
+		"tombstone_column": settings.GetTombstoneColumn(),
+		"cleanup_policy":   settings.GetCleanupPolicy(),
+		"target_topic":     settings.GetTargetTopic(),
+		"delimiter":        settings.GetDelimiter(),
+	}
+	if settings.StrictColumns != nil {
+		fields["strict_columns"] = strconv.FormatBool(settings.GetStrictColumns())
+	}
+	if settings.GetRateLimit() != 0 {
+		fields["rate_limit"] = strconv.Itoa(int(settings.GetRateLimit()))
+	}
+	if settings.MaxErrors != nil {
+		fields["max_errors"] = strconv.Itoa(int(settings.GetMaxErrors()))
+	}
+	if settings.MaxErrorRate != nil {
+		fields["max_error_rate"] = strconv.FormatFloat(settings.GetMaxErrorRate(), 'g', -1, 64)
+	}
+	if settings.GetRetentionMs() != 0 {
+		fields["retention_ms"] = strconv.FormatInt(settings.GetRetentionMs(), 10)
+	}
+	for name, set := range map[string]bool{
+		"create_topic":     settings.GetCreateTopic(),
+		"exactly_once":     settings.GetExactlyOnce(),
+		"dedupe":           settings.GetDedupe(),
+		"trim_space":       settings.GetTrimSpace(),
+		"skip_blank_lines": settings.GetSkipBlankLines(),
+	} {
+		if set {
+			fields[name] = "true"
+		}
+	}
+	if len(settings.GetTags()) > 0 {
+		tags, _ := json.Marshal(settings.GetTags())
+		fields["tags"] = string(tags)
+	}
+	for name, v := range fields {
+		if v == "" {
+			continue
+		}
+		if err := mw.WriteField(name, v); err != nil {
+			return err
+		}
+	}
+
+	var part io.Writer
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		chunk := msg.GetChunk()
+		if chunk == nil {
+			return errors.New("job settings may only be sent once")
+		}
+		if chunk.GetFilename() != "" {
+			if part, err = mw.CreateFormFile("file", chunk.GetFilename()); err != nil {
+				return err
+			}
+		}
+		if part == nil {
+			return errors.New("the first file chunk must set filename")
+		}
+		if _, err := part.Write(chunk.GetData()); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func (s *grpcServer) GetJob(ctx context.Context, in *batchpb.GetJobRequest) (*batchpb.Job, error) {
+	out := &batchpb.Job{}
+	return out, s.doJSON(ctx, "GET", "/jobs/"+url.PathEscape(in.GetId()), nil, out)
+}
+
+func (s *grpcServer) CancelJob(ctx context.Context, in *batchpb.CancelJobRequest) (*batchpb.Job, error) {
+	out := &batchpb.Job{}
+	return out, s.doJSON(ctx, "POST", "/jobs/"+url.PathEscape(in.GetId())+"/cancel", nil, out)
+}
+
+func (s *grpcServer) DeleteJob(ctx context.Context, in *batchpb.DeleteJobRequest) (*emptypb.Empty, error) {
+	path := "/jobs/" + url.PathEscape(in.GetId())
+	if in.GetTopics() {
+		path += "?topics=true"
+	}
+	_, err := s.do(ctx, "DELETE", path, "", nil)
+	return &emptypb.Empty{}, err
+}
+
+func (s *grpcServer) StreamRejectedRows(in *batchpb.StreamRejectedRowsRequest, stream batchpb.BatchIngestion_StreamRejectedRowsServer) error {
+	reply, err := s.do(stream.Context(), "GET", "/jobs/"+url.PathEscape(in.GetId())+"/rejected", "", nil)
+	if err != nil {
+		return err
+	}
+	var page struct {
+		Rows []json.RawMessage `json:"rows"`
+	}
+	if err := json.Unmarshal(reply, &page); err != nil {
+		return status.Error(codes.Internal, "decoding reply: "+err.Error())
+	}
+	for _, raw := range page.Rows {
+		row := &batchpb.RejectedRow{}
+		if err := decodeReply(raw, row); err != nil {
+			return err
+		}
+		if err := stream.Send(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
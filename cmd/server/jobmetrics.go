@@ -0,0 +1,170 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// throughputSeconds is how much per-second throughput history a job
+	// keeps.
+	throughputSeconds = 300
+	// latencySamples is how many of a job's latest Kafka writes its
+	// percentiles are taken over.
+	latencySamples = 4096
+)
+
+// JobMetrics is the reply to GET /jobs/{id}/metrics.
+type JobMetrics struct {
+	JobID      string            `json:"job_id"`
+	State      JobState          `json:"state"`
+	ElapsedMS  int64             `json:"elapsed_ms"`   // processing time so far
+	RowsPerSec float64           `json:"rows_per_sec"` // produced rows over elapsed_ms
+	Throughput []ThroughputPoint `json:"throughput"`   // rows produced each second, latest 5 minutes
+	KafkaWrite LatencyStats      `json:"kafka_write_latency"`
+	Time       struct {
+		ValidatingMS int64 `json:"validating_ms"` // reading, parsing and validating rows
+		ProducingMS  int64 `json:"producing_ms"`  // waiting on Kafka writes and commits
+		ThrottledMS  int64 `json:"throttled_ms"`  // held back by the rate limit
+		PausedMS     int64 `json:"paused_ms"`     // held by POST /jobs/{id}/pause
+	} `json:"time"`
+}
+
+// ThroughputPoint is the number of rows produced in the second starting at
+// Time.
+type ThroughputPoint struct {
+	Time time.Time `json:"time"`
+	Rows int       `json:"rows"`
+}
+
+// LatencyStats summarises Kafka write latencies. Count, AvgMS and MaxMS
+// cover every write; P99MS the latest latencySamples.
+type LatencyStats struct {
+	Count int64   `json:"count"`
+	AvgMS float64 `json:"avg_ms"`
+	P99MS float64 `json:"p99_ms"`
+	MaxMS float64 `json:"max_ms"`
+}
+
+// jobMetrics collects one job's operational numbers while it runs. It has
+// its own lock so the row loop does not contend on jobsMu.
+type jobMetrics struct {
+	mu sync.Mutex
+
+	rows     [throughputSeconds]int // rows produced, indexed by Unix second
+	lastSec  int64                  // latest second in rows
+	firstSec int64                  // first second anything was produced
+
+	latencies  [latencySamples]time.Duration // ring of the latest writes
+	writes     int64
+	writeTotal time.Duration
+	writeMax   time.Duration
+
+	producing, throttled time.Duration
+}
+
+// produced records n rows produced at t.
+func (m *jobMetrics) produced(t time.Time, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sec := t.Unix()
+	if m.firstSec == 0 {
+		m.firstSec, m.lastSec = sec, sec
+	}
+	for s := m.lastSec + 1; s <= sec && s <= m.lastSec+throughputSeconds; s++ {
+		m.rows[s%throughputSeconds] = 0
+	}
+	if sec > m.lastSec {
+		m.lastSec = sec
+	}
+	m.rows[sec%throughputSeconds] += n
+}
+
+// write records one Kafka write, or one transaction commit, taking d.
+func (m *jobMetrics) write(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies[m.writes%latencySamples] = d
+	m.writes++
+	m.writeTotal += d
+	m.writeMax = max(m.writeMax, d)
+	m.producing += d
+}
+
+// throttle records time spent waiting for the rate limiter.
+func (m *jobMetrics) throttle(d time.Duration) {
+	m.mu.Lock()
+	m.throttled += d
+	m.mu.Unlock()
+}
+
+// snapshot reports the metrics of a job that has been processing for
+// elapsed, paused of it while PAUSED.
+func (m *jobMetrics) snapshot(elapsed, paused time.Duration) JobMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out JobMetrics
+	out.ElapsedMS = elapsed.Milliseconds()
+	out.Throughput = []ThroughputPoint{}
+	if m.firstSec != 0 {
+		for s := max(m.firstSec, m.lastSec-throughputSeconds+1); s <= m.lastSec; s++ {
+			out.Throughput = append(out.Throughput, ThroughputPoint{Time: time.Unix(s, 0).UTC(), Rows: m.rows[s%throughputSeconds]})
+		}
+	}
+
+	if m.writes > 0 {
+		n := min(m.writes, latencySamples)
+		sorted := make([]time.Duration, n)
+		copy(sorted, m.latencies[:n])
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		out.KafkaWrite = LatencyStats{
+			Count: m.writes,
+			AvgMS: ms(m.writeTotal / time.Duration(m.writes)),
+			P99MS: ms(sorted[int(math.Ceil(0.99*float64(n)))-1]),
+			MaxMS: ms(m.writeMax),
+		}
+	}
+	out.Time.ProducingMS = m.producing.Milliseconds()
+	out.Time.ThrottledMS = m.throttled.Milliseconds()
+	out.Time.PausedMS = paused.Milliseconds()
+	out.Time.ValidatingMS = max(0, elapsed-m.producing-m.throttled-paused).Milliseconds()
+	return out
+}
+
+func ms(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+
+// getJobMetrics reports a job's throughput, Kafka write latency and where
+// its processing time went.
+func getJobMetrics(w http.ResponseWriter, r *http.Request) {
+	jobsMu.RLock()
+	js, ok := scopedJob(r, mux.Vars(r)["id"])
+	if !ok {
+		jobsMu.RUnlock()
+		notFound(w, "JOB_NOT_FOUND", "job not found")
+		return
+	}
+	var elapsed time.Duration
+	switch {
+	case js.State.Terminal():
+		elapsed = time.Duration(js.Timings.ProcessingMS) * time.Millisecond
+	case !js.StartedAt.IsZero():
+		elapsed = time.Since(js.StartedAt)
+	}
+	jobID, state, produced, m, paused := js.JobID, js.State, js.Totals.OK, js.metrics, js.paused
+	jobsMu.RUnlock()
+
+	if m == nil {
+		m = &jobMetrics{}
+	}
+	out := m.snapshot(elapsed, paused)
+	out.JobID, out.State = jobID, state
+	if elapsed > 0 {
+		out.RowsPerSec = math.Round(float64(produced)/elapsed.Seconds()*10) / 10
+	}
+	writeJSON(w, http.StatusOK, out)
+}
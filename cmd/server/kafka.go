@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// kafkaDialer is shared by every writer, reader and admin connection so
+// they all pick up the SASL/TLS settings. It is replaced in main once the
+// environment has been read.
+var kafkaDialer = &kafka.Dialer{Timeout: 10 * time.Second, DualStack: true}
+
+// kafkaWriter produces every job's rows and rejects. Messages name their
+// topic, so one writer and its connection pool serve all jobs instead of
+// each job dialing its own pair of writers. It is closed on shutdown.
+var kafkaWriter *kafka.Writer
+
+// newKafkaWriter returns a topic-less writer that connects with the same
+// SASL/TLS settings as d.
+func newKafkaWriter(d *kafka.Dialer) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(kafkaBrokers()...),
+		Balancer:     &kafka.LeastBytes{},
+		RequiredAcks: kafka.RequireOne,
+		Transport: &kafka.Transport{
+			DialTimeout: d.Timeout,
+			SASL:        d.SASLMechanism,
+			TLS:         d.TLS,
+		},
+	}
+}
+
+// newKafkaDialer builds the dialer from the environment:
+//
+//	KAFKA_SASL_MECHANISM  PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512
+//	KAFKA_SASL_USERNAME / KAFKA_SASL_PASSWORD
+//	KAFKA_TLS_ENABLED     true to connect over TLS
+//	KAFKA_TLS_CA_FILE     PEM bundle to verify the brokers with
+//	KAFKA_TLS_CERT_FILE / KAFKA_TLS_KEY_FILE  client certificate
+//
+// With none of them set it connects in plaintext as before.
+func newKafkaDialer() (*kafka.Dialer, error) {
+	d := &kafka.Dialer{Timeout: 10 * time.Second, DualStack: true}
+
+	mech, err := saslMechanism()
+	if err != nil {
+		return nil, err
+	}
+	d.SASLMechanism = mech
+
+	if getenv("KAFKA_TLS_ENABLED", "false") == "true" {
+		cfg, err := kafkaTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		d.TLS = cfg
+	}
+	return d, nil
+}
+
+func saslMechanism() (sasl.Mechanism, error) {
+	name := strings.ToUpper(getenv("KAFKA_SASL_MECHANISM", ""))
+	user := getenv("KAFKA_SASL_USERNAME", "")
+	pass := getenv("KAFKA_SASL_PASSWORD", "")
+	switch name {
+	case "":
+		return nil, nil
+	case "PLAIN":
+		return plain.Mechanism{Username: user, Password: pass}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, user, pass)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, user, pass)
+	}
+	return nil, fmt.Errorf("unsupported KAFKA_SASL_MECHANISM %q (want PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512)", name)
+}
+
+func kafkaTLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile := getenv("KAFKA_TLS_CA_FILE", ""); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read KAFKA_TLS_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("KAFKA_TLS_CA_FILE %s contains no certificates", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	certFile, keyFile := getenv("KAFKA_TLS_CERT_FILE", ""), getenv("KAFKA_TLS_KEY_FILE", "")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load Kafka client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
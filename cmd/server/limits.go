@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Row limits keep one pathological row from exhausting memory when it is
+// encoded and produced. Rows over a limit go to the DLQ instead.
+var (
+	maxRowBytes   = envInt("MAX_ROW_BYTES", 1<<20)     // a row's cells plus separators
+	maxFieldBytes = envInt("MAX_FIELD_BYTES", 512<<10) // any one cell
+	maxColumns    = envInt("MAX_COLUMNS", 1000)
+)
+
+// rowPreviewBytes is how much of an oversized row a rejection shows.
+const rowPreviewBytes = 256
+
+// envInt parses a positive integer from the environment, falling back to
+// def when the variable is unset or invalid.
+func envInt(key string, def int) int {
+	v := getenv(key, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		slog.Warn("invalid integer, using default", "var", key, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+// checkRowLimits returns a TOO_MANY_COLUMNS or ROW_TOO_LARGE error for a
+// record over the configured limits. cols names the cells, if known.
+func checkRowLimits(rec, cols []string) *RowError {
+	if len(rec) > maxColumns {
+		return &RowError{
+			Type:     ErrorTypeParse,
+			Observed: strconv.Itoa(len(rec)),
+			Message:  fmt.Sprintf("TOO_MANY_COLUMNS: row has %d columns, limit is %d", len(rec), maxColumns),
+		}
+	}
+	size := len(rec) - 1
+	for i, cell := range rec {
+		if len(cell) > maxFieldBytes {
+			rerr := &RowError{
+				Type:     ErrorTypeParse,
+				Observed: preview(cell),
+				Message:  fmt.Sprintf("ROW_TOO_LARGE: field %d is %d bytes, limit is %d", i+1, len(cell), maxFieldBytes),
+			}
+			if i < len(cols) {
+				rerr.Column = cols[i]
+				rerr.Message = fmt.Sprintf("ROW_TOO_LARGE: field '%s' is %d bytes, limit is %d", cols[i], len(cell), maxFieldBytes)
+			}
+			return rerr
+		}
+		size += len(cell)
+	}
+	if size > maxRowBytes {
+		return &RowError{
+			Type:     ErrorTypeParse,
+			Observed: rowPreview(rec),
+			Message:  fmt.Sprintf("ROW_TOO_LARGE: row is %d bytes, limit is %d", size, maxRowBytes),
+		}
+	}
+	return nil
+}
+
+// rowPreview returns the start of rec as CSV-like text without joining
+// the whole row.
+func rowPreview(rec []string) string {
+	var b strings.Builder
+	for i, cell := range rec {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if b.Len()+len(cell) > rowPreviewBytes {
+			b.WriteString(cell[:min(len(cell), rowPreviewBytes+1)])
+			break
+		}
+		b.WriteString(cell)
+	}
+	return preview(b.String())
+}
+
+// preview returns the start of s, cut to rowPreviewBytes.
+func preview(s string) string {
+	if len(s) <= rowPreviewBytes {
+		return s
+	}
+	return strings.ToValidUTF8(s[:rowPreviewBytes], "") + "..."
+}
@@ -1,43 +1,128 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math/rand"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
-	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	kafka "github.com/segmentio/kafka-go"
+	"golang.org/x/text/encoding"
+	"golang.org/x/time/rate"
+
+	"github.com/keithchambers/batch-ingestion/internal/rowschema"
 )
 
-const maxUploadBytes = 1 << 30 // 1 GB
+// maxUploadBytes caps a job's combined file size (MAX_UPLOAD_BYTES,
+// default 1 GiB), whether uploaded or fetched from source_url.
+var maxUploadBytes = int64(envInt("MAX_UPLOAD_BYTES", 1<<30))
+
+// defaultRetentionMS keeps job topics for 7 days.
+const defaultRetentionMS = 7 * 24 * 60 * 60 * 1000
+
+// multipartMemory is how much of an upload is held in memory; the rest of
+// each file part is spooled to a temporary file.
+const multipartMemory = 32 << 20
+
+// multipartOverhead allows for boundaries and form fields around the file
+// parts when the request body is capped.
+const multipartOverhead = 1 << 20
 
 type Model struct {
-	ID     string          `json:"id"`
-	Name   string          `json:"name"`
-	Schema json.RawMessage `json:"schema"`
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	Schema    json.RawMessage  `json:"schema"`
+	Namespace string           `json:"namespace"`
+	Version   int              `json:"version"`
+	CreatedAt time.Time        `json:"created_at"`
+	Defaults  *ModelDefaults   `json:"defaults,omitempty"` // settings jobs inherit
+	Mapping   *ColumnMapping   `json:"mapping,omitempty"`  // renames and drops columns in the payload
+	Computed  []ComputedField  `json:"computed,omitempty"` // fields added to every produced row
+	Nulls     *rowschema.Nulls `json:"nulls,omitempty"`    // cell values produced as null
 }
 
+// ErrorType classifies why a row was rejected.
+type ErrorType string
+
+const (
+	ErrorTypeParse           ErrorType = "PARSE_ERROR"
+	ErrorTypeSchemaViolation ErrorType = "SCHEMA_VIOLATION"
+	ErrorTypeKafka           ErrorType = "KAFKA_ERROR"
+)
+
 type RejectedRow struct {
-	JobID     string    `json:"job_id"`
-	RowNumber int       `json:"row_number"`
-	RawData   string    `json:"raw_data"`
-	Error     string    `json:"error"`
-	Timestamp time.Time `json:"timestamp"`
+	JobID         string    `json:"job_id"`
+	RowNumber     int       `json:"row_number"`
+	RawData       string    `json:"raw_data"`
+	Error         string    `json:"error"`
+	ErrorType     ErrorType `json:"error_type"`
+	Column        string    `json:"column,omitempty"`
+	ObservedValue string    `json:"observed_value,omitempty"`
+	ExpectedType  string    `json:"expected_type,omitempty"`
+	SourceFile    string    `json:"source_file,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// RowError carries the structured detail for a row routed to the DLQ.
+type RowError struct {
+	Type     ErrorType
+	Column   string
+	Observed string
+	Expected string // type the schema declares for Column
+	Message  string
+}
+
+func (e *RowError) Error() string { return e.Message }
+
+// toRowError returns err as a *RowError, wrapping plain errors with the
+// given type and message prefix.
+func toRowError(err error, typ ErrorType, prefix string) *RowError {
+	var rerr *RowError
+	if errors.As(err, &rerr) {
+		return rerr
+	}
+	return &RowError{Type: typ, Message: prefix + err.Error()}
+}
+
+// schemaViolation reports a *rowschema.Error as a SCHEMA_VIOLATION row
+// error.
+func schemaViolation(err error) error {
+	var serr *rowschema.Error
+	if !errors.As(err, &serr) {
+		return err
+	}
+	return &RowError{
+		Type:     ErrorTypeSchemaViolation,
+		Column:   serr.Column,
+		Observed: serr.Observed,
+		Expected: serr.Expected,
+		Message:  serr.Message,
+	}
 }
 
 var (
 	modelsMu sync.RWMutex
-	models   = map[string]Model{}
+	models   = map[string]Model{}   // latest version of each model
+	versions = map[string][]Model{} // every version, oldest first
 	jobsMu   sync.RWMutex
 	jobs     = map[string]*JobStatus{}
 )
@@ -47,30 +132,135 @@ type JobState string
 const (
 	StatePending        JobState = "PENDING"
 	StateRunning        JobState = "RUNNING"
+	StatePaused         JobState = "PAUSED"
 	StateSuccess        JobState = "SUCCESS"
 	StatePartialSuccess JobState = "PARTIAL_SUCCESS"
 	StateFailed         JobState = "FAILED"
 	StateCancelled      JobState = "CANCELLED"
 )
 
+// Terminal reports whether a job in this state will not change again.
+func (s JobState) Terminal() bool {
+	switch s {
+	case StateSuccess, StatePartialSuccess, StateFailed, StateCancelled:
+		return true
+	}
+	return false
+}
+
+// Output formats for rows produced to the main topic.
+const (
+	OutputArray    = "array"
+	OutputObject   = "object"
+	OutputAvro     = "avro"     // Confluent wire format, schema from the model
+	OutputProtobuf = "protobuf" // message from the model, Confluent framed once registered
+)
+
+// JobOptions carries the per-job settings derived from the upload form.
+type JobOptions struct {
+	OutputFormat    string
+	Columns         []string          // keys used for object output
+	HasHeader       bool              // first CSV record holds the column names
+	RequestID       string            // request that created the job, for log correlation
+	CallbackURL     string            // receives the final JobStatus, if set
+	Schema          *rowschema.Schema // types cells by column; nil produces strings
+	Avro            *avroCodec        // encoder for avro output
+	Proto           *protoCodec       // encoder for protobuf output
+	Timeout         time.Duration     // processing deadline requested for this job; 0 uses JOB_TIMEOUT
+	FitColumns      bool              // pad or truncate rows to len(Columns) instead of rejecting them
+	Encoding        encoding.Encoding // input character set; nil is UTF-8
+	Tags            map[string]string // copied to the job's status
+	RateLimit       int               // rows per second requested for this job; 0 uses MAX_ROWS_PER_SEC
+	MaxErrors       *int              // rejected rows after which the job fails; nil is unlimited
+	MaxErrorRate    *float64          // fraction of rejected records after which the job fails; nil is unlimited
+	FileType        string            // FileCSV, FileNDJSON, FileParquet or FileORC
+	Delimiter       rune              // CSV field separator; 0 before inspectFile picks one for the file
+	Gzip            bool              // input is gzip-compressed
+	DedupeColumn    string            // rows repeating this column's value are skipped
+	KeyColumn       string            // column whose value is the message key; "" keys by job ID
+	TombstoneColumn string            // rows with a truthy value here are produced as tombstones
+	CleanupPolicy   string            // cleanup.policy of the main topic
+	RetentionMS     int64             // retention.ms of the main topic; -1 keeps rows forever
+	TargetTopic     string            // existing topic to produce into instead of batch_<job_id>
+	CreateTopic     bool              // create TargetTopic if it does not exist
+	Output          []outputField     // produced fields from the model's mapping; nil produces every column
+	Computed        []ComputedField   // fields the model adds to every produced row
+	Nulls           *rowschema.Nulls  // cell values the model reads as null
+	ExactlyOnce     bool              // produce rows in Kafka transactions
+	TrimSpace       bool              // strip leading and trailing white space from every cell
+	SkipBlankLines  bool              // drop records whose cells are all blank instead of rejecting them
+}
+
 type JobStatus struct {
-	JobID   string   `json:"job_id"`
-	ModelID string   `json:"model_id"`
-	State   JobState `json:"state"`
-	Totals  struct {
-		Rows   int `json:"rows"`
-		OK     int `json:"ok"`
-		Errors int `json:"errors"`
+	JobID        string   `json:"job_id"`
+	Namespace    string   `json:"namespace"`
+	ModelID      string   `json:"model_id"`
+	ModelVersion int      `json:"model_version"`
+	State        JobState `json:"state"`
+	OutputFormat string   `json:"output_format"`
+	Totals       struct {
+		Rows       int `json:"rows"`
+		OK         int `json:"ok"`
+		Errors     int `json:"errors"`
+		Duplicates int `json:"duplicates,omitempty"` // rows skipped by dedupe_column
+		Expected   int `json:"expected,omitempty"`   // rows estimated before processing; 0 if unknown
 	} `json:"totals"`
 	Timings struct {
 		WaitingMS    int64 `json:"waiting_ms"`
 		ProcessingMS int64 `json:"processing_ms"`
 	} `json:"timings"`
+	Reason    string    `json:"reason,omitempty"` // why a job ended early
 	UpdatedAt time.Time `json:"updated_at"`
 	StartedAt time.Time `json:"started_at"`
 	Cancelled bool      `json:"-"`
+
+	ProgressPercent *float64 `json:"progress_percent,omitempty"` // share of expected rows read; set once expected is known
+	ETASeconds      *int64   `json:"eta_seconds,omitempty"`      // estimated time left while running
+
+	ParentJobID string            `json:"parent_job_id,omitempty"` // set on retry jobs
+	Checksum    string            `json:"checksum,omitempty"`      // hex SHA-256 of the uploaded file
+	Size        int64             `json:"size,omitempty"`          // bytes uploaded or fetched, across all files
+	SourceURL   string            `json:"source_url,omitempty"`    // where the server fetched the data from
+	Files       []JobFile         `json:"files,omitempty"`         // set when the job was uploaded as several files
+	Tags        map[string]string `json:"tags,omitempty"`          // caller-supplied labels, filterable on GET /jobs
+	RateLimit   int               `json:"rate_limit,omitempty"`    // rows per second the job is held to; 0 is unlimited
+	Warnings    []string          `json:"warnings,omitempty"`      // problems that did not fail the job
+	Topic       string            `json:"topic,omitempty"`         // where rows were produced: batch_<job_id> or target_topic
+	ExactlyOnce bool              `json:"exactly_once,omitempty"`  // rows were produced in Kafka transactions
+
+	// RejectionSummary counts rejected rows by reason and column (see
+	// RowError.summaryKey), guarded by jobsMu
+	RejectionSummary map[string]int `json:"rejection_summary,omitempty"`
+
+	opts      JobOptions         // settings the job was started with, reused on retry
+	cancel    context.CancelFunc // stops processing; set once the job is running
+	rejected  []RejectedRow      // first rejectedCacheMax rows sent to the DLQ, guarded by jobsMu
+	truncated bool               // more rows were rejected than rejected holds
+	metrics   *jobMetrics        // throughput and latency, for GET /jobs/{id}/metrics
+	quota     *rowQuota          // charges rows read to the namespace's max_rows_per_day
+	read      int                // records read so far, rejected or not
+	resume    chan struct{}      // closed when a PAUSED job is resumed, guarded by jobsMu
+	paused    time.Duration      // time spent PAUSED, guarded by jobsMu
+	done      chan struct{}      // closed when processing has ended
+}
+
+// JobFile describes one of the files uploaded together as a single job.
+type JobFile struct {
+	Name     string `json:"name"`
+	Checksum string `json:"checksum"` // hex SHA-256 of the file as uploaded
+	Size     int64  `json:"size"`     // bytes as uploaded, before any decompression
 }
 
+// rejectedCacheMax is how many rejected rows each job keeps in memory
+// (REJECTED_CACHE_MAX, default 1000); the rest are only in the DLQ.
+var rejectedCacheMax = func() int {
+	n, err := strconv.Atoi(getenv("REJECTED_CACHE_MAX", "1000"))
+	if err != nil || n < 0 {
+		return 1000
+	}
+	return n
+}()
+
 func getenv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -78,6 +268,36 @@ func getenv(key, def string) string {
 	return def
 }
 
+func kafkaBrokers() []string {
+	return strings.Split(getenv("KAFKA_BROKERS", "localhost:19092"), ",")
+}
+
+// topicName matches the characters Kafka allows in a topic name.
+var topicName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,249}$`)
+
+// validTargetTopic checks a caller-named topic. Names the server manages
+// itself are refused: batch_ topics are deleted with their job, and
+// batch.jobs, the audit topic and __ topics are internal.
+func validTargetTopic(name string) error {
+	switch {
+	case !topicName.MatchString(name) || name == "." || name == "..":
+		return invalid("INVALID_TARGET_TOPIC", "target_topic must be 1-249 characters of letters, digits, '.', '_' or '-'")
+	case strings.HasPrefix(name, "batch_") || name == "batch.jobs" || name == auditTopic || strings.HasPrefix(name, "__"):
+		return invalid("INVALID_TARGET_TOPIC", "target_topic '"+name+"' is reserved for the server's own topics")
+	}
+	return nil
+}
+
+// jobTopics returns the main and dead-letter topic names for a job in
+// namespace ns. The default namespace keeps the original names.
+func jobTopics(ns, jobID string) (mainTopic, dlqTopic string) {
+	prefix := "batch_"
+	if ns != defaultNamespace {
+		prefix += ns + "_"
+	}
+	return prefix + jobID, prefix + jobID + "_dlq"
+}
+
 func main() {
 	rand.Seed(time.Now().UnixNano())
 	r := mux.NewRouter()
@@ -87,28 +307,77 @@ func main() {
 	r.HandleFunc("/models/{id}", getModel).Methods("GET")
 	r.HandleFunc("/models/{id}", updateModel).Methods("PUT")
 	r.HandleFunc("/models/{id}", deleteModel).Methods("DELETE")
+	r.HandleFunc("/models/{id}/versions", listModelVersions).Methods("GET")
+	r.HandleFunc("/models/{id}/clone", cloneModel).Methods("POST")
+	r.HandleFunc("/models/{id}/jobs/cancel", cancelModelJobs).Methods("POST")
 	r.HandleFunc("/jobs", createJob).Methods("POST")
 	r.HandleFunc("/jobs", listJobs).Methods("GET")
+	r.HandleFunc("/jobs/status", bulkJobStatus).Methods("POST")
 	r.HandleFunc("/jobs/{id}", getJob).Methods("GET")
-	r.HandleFunc("/jobs/{id}", cancelJob).Methods("DELETE")
+	r.HandleFunc("/jobs/{id}", deleteJob).Methods("DELETE")
+	r.HandleFunc("/jobs/{id}/cancel", cancelJob).Methods("POST")
+	r.HandleFunc("/jobs/{id}/events", jobEvents).Methods("GET")
+	r.HandleFunc("/jobs/{id}/metrics", getJobMetrics).Methods("GET")
+	r.HandleFunc("/jobs/{id}/pause", pauseJob).Methods("POST")
+	r.HandleFunc("/jobs/{id}/resume", resumeJob).Methods("POST")
 	r.HandleFunc("/jobs/{id}/rejected", rejectedRows).Methods("GET")
+	r.HandleFunc("/jobs/{id}/rejected/count", rejectedCount).Methods("GET")
+	r.HandleFunc("/jobs/{id}/rejected.csv", rejectedCSV).Methods("GET")
+	r.HandleFunc("/jobs/{id}/rejected.ndjson", rejectedNDJSON).Methods("GET")
+	r.HandleFunc("/jobs/{id}/retry", retryJob).Methods("POST")
+	r.HandleFunc("/jobs/{id}/topics", deleteJobTopics).Methods("DELETE")
+	r.HandleFunc("/audit", listAudit).Methods("GET")
+	r.HandleFunc("/quota", getQuota).Methods("GET")
 	r.HandleFunc("/healthz", healthCheck).Methods("GET")
+	r.HandleFunc("/readyz", readyCheck).Methods("GET")
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	r.HandleFunc("/version", versionHandler).Methods("GET")
+	r.HandleFunc("/openapi.json", openAPIHandler(r)).Methods("GET")
+	middleware := []mux.MiddlewareFunc{requestIDMiddleware, corsMiddleware(), gzipMiddleware, authMiddleware(), namespaceMiddleware}
+	r.Use(middleware...)
+	r.NotFoundHandler = withMiddleware(http.HandlerFunc(routeNotFound), middleware...)
+	r.MethodNotAllowedHandler = withMiddleware(methodNotAllowed(r), middleware...)
 
 	port := getenv("PORT", "8000")
-	log.Printf("listening on :%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	setupLogging()
+	dialer, err := newKafkaDialer()
+	if err != nil {
+		slog.Error("invalid Kafka connection settings", "error", err)
+		os.Exit(1)
+	}
+	kafkaDialer = dialer
+	if err := loadQuotas(); err != nil {
+		slog.Error("invalid quota file", "error", err)
+		os.Exit(1)
+	}
+	kafkaWriter = newKafkaWriter(dialer)
+	startBackground(reapJobs)
+	startBackground(reapStaleJobs)
+	startBackground(writeAudit)
+	grpcSrv, grpcLis, err := newGRPCServer(r)
+	if err != nil {
+		slog.Error("cannot listen on GRPC_PORT", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("listening", "addr", ":"+port)
+	serve(&http.Server{Addr: ":" + port, Handler: r}, grpcSrv, grpcLis)
 }
 
 // ------------------ model handlers ------------------
 
 func listModels(w http.ResponseWriter, r *http.Request) {
+	ns := namespace(r)
 	modelsMu.RLock()
 	defer modelsMu.RUnlock()
 	var list []Model
 	for _, m := range models {
-		list = append(list, m)
+		if m.Namespace == ns {
+			list = append(list, m)
+		}
 	}
-	writeJSON(w, http.StatusOK, list)
+	// Map order would change the ETag between identical listings
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	writeJSONCached(w, r, list)
 }
 
 func createModel(w http.ResponseWriter, r *http.Request) {
@@ -117,24 +386,68 @@ func createModel(w http.ResponseWriter, r *http.Request) {
 		badRequest(w, "INVALID_JSON", err.Error())
 		return
 	}
+	if _, err := compileSchema(m.Schema); err != nil {
+		badRequest(w, "INVALID_SCHEMA", err.Error())
+		return
+	}
 	if m.ID == "" {
 		m.ID = randomID()
 	}
+	m.Namespace = namespace(r)
+	m.Version = 1
+	m.CreatedAt = time.Now().UTC()
+	if err := validateModel(m); err != nil {
+		writeError(w, r, err)
+		return
+	}
 	modelsMu.Lock()
-	models[m.ID] = m
+	key := modelKey(m.Namespace, m.ID)
+	if _, exists := models[key]; exists {
+		modelsMu.Unlock()
+		conflict(w, "MODEL_EXISTS", "model "+m.ID+" already exists; use PUT to add a version")
+		return
+	}
+	if other, taken := modelNameTaken(m.Namespace, m.Name, m.ID); taken {
+		modelsMu.Unlock()
+		conflict(w, "DUPLICATE_MODEL_NAME", "model name already used by "+other)
+		return
+	}
+	models[key] = m
+	versions[key] = []Model{m}
 	modelsMu.Unlock()
+	audit(r, "model.create", "model", m.ID)
 	writeJSON(w, http.StatusCreated, m)
 }
 
 func getModel(w http.ResponseWriter, r *http.Request) {
-	id := mux.Vars(r)["id"]
+	key := modelKey(namespace(r), mux.Vars(r)["id"])
 	modelsMu.RLock()
 	defer modelsMu.RUnlock()
-	if m, ok := models[id]; ok {
-		writeJSON(w, http.StatusOK, m)
-	} else {
+	m, ok := models[key]
+	if !ok {
+		notFound(w, "MODEL_NOT_FOUND", "model not found")
+		return
+	}
+	if v := r.URL.Query().Get("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > len(versions[key]) {
+			notFound(w, "VERSION_NOT_FOUND", "model has no version "+v)
+			return
+		}
+		m = versions[key][n-1]
+	}
+	writeJSONCached(w, r, m)
+}
+
+func listModelVersions(w http.ResponseWriter, r *http.Request) {
+	key := modelKey(namespace(r), mux.Vars(r)["id"])
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	if _, ok := models[key]; !ok {
 		notFound(w, "MODEL_NOT_FOUND", "model not found")
+		return
 	}
+	writeJSON(w, http.StatusOK, versions[key])
 }
 
 func updateModel(w http.ResponseWriter, r *http.Request) {
@@ -144,273 +457,1548 @@ func updateModel(w http.ResponseWriter, r *http.Request) {
 		badRequest(w, "INVALID_JSON", err.Error())
 		return
 	}
+	if _, err := compileSchema(updated.Schema); err != nil {
+		badRequest(w, "INVALID_SCHEMA", err.Error())
+		return
+	}
+	compat, err := parseCompatibility(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !modelChangeAllowed(w, r, id) {
+		return
+	}
 	modelsMu.Lock()
 	defer modelsMu.Unlock()
-	if _, ok := models[id]; !ok {
+	key := modelKey(namespace(r), id)
+	current, ok := models[key]
+	if !ok {
 		notFound(w, "MODEL_NOT_FOUND", "model not found")
 		return
 	}
+	// force only overrides MODEL_IN_USE; compatibility=NONE skips this check
+	if changes := schemaChanges(current.Schema, updated.Schema, compat); len(changes) > 0 {
+		conflict(w, "INCOMPATIBLE_SCHEMA", "schema is not "+compat+" compatible with version "+strconv.Itoa(current.Version)+": "+strings.Join(changes, "; "))
+		return
+	}
+	if updated.Name == "" {
+		updated.Name = current.Name
+	}
+	if updated.Defaults == nil {
+		updated.Defaults = current.Defaults
+	}
+	if updated.Mapping == nil {
+		updated.Mapping = current.Mapping
+	}
+	if updated.Computed == nil {
+		updated.Computed = current.Computed
+	}
+	if updated.Nulls == nil {
+		updated.Nulls = current.Nulls
+	}
+	if other, taken := modelNameTaken(current.Namespace, updated.Name, id); taken {
+		conflict(w, "DUPLICATE_MODEL_NAME", "model name already used by "+other)
+		return
+	}
+	// Revisions are immutable; an update appends a new version
 	updated.ID = id
-	models[id] = updated
+	updated.Namespace = current.Namespace
+	updated.Version = current.Version + 1
+	updated.CreatedAt = time.Now().UTC()
+	if err := validateModel(updated); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	models[key] = updated
+	versions[key] = append(versions[key], updated)
+	audit(r, "model.update", "model", id)
 	writeJSON(w, http.StatusOK, updated)
 }
 
 func deleteModel(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
+	if !modelChangeAllowed(w, r, id) {
+		return
+	}
+	key := modelKey(namespace(r), id)
 	modelsMu.Lock()
 	defer modelsMu.Unlock()
-	if _, ok := models[id]; !ok {
+	if _, ok := models[key]; !ok {
 		notFound(w, "MODEL_NOT_FOUND", "model not found")
 		return
 	}
-	delete(models, id)
+	delete(models, key)
+	delete(versions, key)
+	audit(r, "model.delete", "model", id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// modelNameTaken reports whether another model in namespace ns already
+// uses name (case-insensitively) and returns its ID.
+// ALLOW_DUPLICATE_MODEL_NAMES=true disables the check. Callers must hold
+// modelsMu.
+func modelNameTaken(ns, name, exceptID string) (string, bool) {
+	if name == "" || getenv("ALLOW_DUPLICATE_MODEL_NAMES", "false") == "true" {
+		return "", false
+	}
+	for _, m := range models {
+		if m.Namespace == ns && m.ID != exceptID && strings.EqualFold(m.Name, name) {
+			return m.ID, true
+		}
+	}
+	return "", false
+}
+
+// activeJobsForModel returns the IDs of non-terminal jobs using a model.
+func activeJobsForModel(ns, modelID string) []string {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	var ids []string
+	for id, j := range jobs {
+		if j.Namespace == ns && j.ModelID == modelID && !j.State.Terminal() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// modelChangeAllowed writes 409 MODEL_IN_USE and returns false when the
+// model still has active jobs, unless the request sets force=true.
+func modelChangeAllowed(w http.ResponseWriter, r *http.Request, modelID string) bool {
+	if r.URL.Query().Get("force") == "true" {
+		return true
+	}
+	if ids := activeJobsForModel(namespace(r), modelID); len(ids) > 0 {
+		conflict(w, "MODEL_IN_USE", "model has active jobs: "+strings.Join(ids, ", "))
+		return false
+	}
+	return true
+}
+
 // ------------------ job handlers ------------------
 
 func createJob(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(maxUploadBytes); err != nil {
+	if draining.Load() {
+		serviceUnavailable(w, "SHUTTING_DOWN", "server is shutting down")
+		return
+	}
+	// A job could only fail once started, so refuse it before the upload
+	// is read rather than after
+	if err := kafkaReachable(); err != nil {
+		serviceUnavailable(w, "KAFKA_UNAVAILABLE", "kafka unreachable: "+err.Error())
+		return
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		createJobFromURL(w, r)
+		return
+	}
+	// Oversize uploads fail while the body is read, before they are spooled
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+multipartOverhead)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			fileTooLarge(w)
+			return
+		}
 		badRequest(w, "INVALID_MULTIPART", err.Error())
 		return
 	}
 	modelID := r.FormValue("model_id")
-	if modelID == "" {
-		badRequest(w, "MISSING_MODEL_ID", "model_id is required")
+	model, err := lookupModel(namespace(r), modelID)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
-	modelsMu.RLock()
-	if _, ok := models[modelID]; !ok {
-		modelsMu.RUnlock()
-		badRequest(w, "MODEL_NOT_FOUND", "model not found")
+	fields, err := formJobFields(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
-	modelsMu.RUnlock()
-
-	file, header, err := r.FormFile("file")
+	wait := syncWait(r, fields.Sync)
+	opts, err := parseJobOptions(applyModelDefaults(fields, model), requestID(r))
 	if err != nil {
-		badRequest(w, "MISSING_FILE", err.Error())
+		writeError(w, r, err)
 		return
 	}
-	defer file.Close()
 
-	if header.Size > maxUploadBytes {
-		badRequest(w, "FILE_TOO_LARGE", "file exceeds 1GiB limit")
+	headers := r.MultipartForm.File["file"]
+	if len(headers) == 0 {
+		badRequest(w, "MISSING_FILE", http.ErrMissingFile.Error())
 		return
 	}
 
-	buf := make([]byte, 4)
-	if _, err := io.ReadFull(file, buf); err != nil {
-		badRequest(w, "READ_ERROR", err.Error())
-		return
-	}
-	// Reset reader to beginning
-	if _, err := file.Seek(0, io.SeekStart); err != nil {
-		internalError(w, err)
-		return
+	// Every file part is checked up front so a bad file rejects the whole
+	// job before anything is written to Kafka.
+	var (
+		inputs  []jobInput
+		files   []JobFile
+		size    int64
+		started bool
+	)
+	defer func() {
+		if !started {
+			closeInputs(inputs)
+		}
+	}()
+	for _, header := range headers {
+		size += header.Size
+		if size > maxUploadBytes {
+			fileTooLarge(w)
+			return
+		}
+		file, path, err := openUpload(header)
+		if err != nil {
+			badRequest(w, "MISSING_FILE", err.Error())
+			return
+		}
+		inputs = append(inputs, jobInput{Name: header.Filename, R: file, path: path})
+
+		fileOpts := opts
+		jf, err := inspectFile(file, header.Filename, model, &fileOpts)
+		if err != nil {
+			var rerr *requestError
+			if len(headers) > 1 && errors.As(err, &rerr) {
+				err = &requestError{Status: rerr.Status, Code: rerr.Code, Message: header.Filename + ": " + rerr.Message}
+			}
+			writeError(w, r, err)
+			return
+		}
+		inputs[len(inputs)-1].Opts = fileOpts
+		files = append(files, jf)
 	}
 
-	var fileType string
-	if string(buf) == "PAR1" {
-		fileType = "parquet"
-	} else if strings.Contains(filepath.Ext(header.Filename), ".csv") || buf[0] != 0x50 { // simple check
-		fileType = "csv"
+	js := &JobStatus{
+		JobID:        randomID(),
+		Namespace:    model.Namespace,
+		ModelID:      modelID,
+		ModelVersion: model.Version,
+		State:        StatePending,
+		OutputFormat: opts.OutputFormat,
+		Size:         size,
+		UpdatedAt:    time.Now(),
+	}
+	if len(files) == 1 {
+		js.Checksum = files[0].Checksum
+		if r.FormValue("dedupe") == "true" {
+			if existing := completedJobFor(js.Namespace, modelID, js.Checksum); existing != "" {
+				writeJSON(w, http.StatusOK, map[string]interface{}{"job_id": existing, "deduplicated": true})
+				return
+			}
+		}
 	} else {
-		badRequest(w, "UNSUPPORTED_FILE_TYPE", "only .csv or .parquet files are allowed")
+		js.Files = files
+	}
+	if err := quotas.reserve(js.Namespace, size); err != nil {
+		writeError(w, r, err)
 		return
 	}
+	startJob(js, inputs, inputs[0].Opts)
+	started = true
+	audit(r, "job.create", "job", js.JobID)
 
-	jobID := randomID()
-	js := &JobStatus{
-		JobID:     jobID,
-		ModelID:   modelID,
-		State:     StatePending,
-		UpdatedAt: time.Now(),
-	}
-	jobsMu.Lock()
-	jobs[jobID] = js
-	jobsMu.Unlock()
+	respondJobCreated(w, r, js, wait)
+}
 
-	go processJob(js, file, fileType) // async
+// openUpload opens a file part for a job that outlives the request.
+// net/http deletes the parts it spooled to disk once the handler returns,
+// so such a part is first moved to a path of the job's own, which is
+// returned; closeInputs closes and removes it when the job ends. A part
+// held in memory has no path.
+func openUpload(header *multipart.FileHeader) (multipart.File, string, error) {
+	f, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	spooled, ok := f.(*os.File)
+	if !ok {
+		return f, "", nil
+	}
+	path := spooled.Name() + ".job"
+	if err := os.Rename(spooled.Name(), path); err != nil {
+		f.Close()
+		return nil, "", err
+	}
+	return f, path, nil
+}
 
-	writeJSON(w, http.StatusAccepted, map[string]string{"job_id": jobID})
+// closeInputs closes the inputs of a job and removes the uploads it owns.
+func closeInputs(inputs []jobInput) {
+	for _, in := range inputs {
+		if c, ok := in.R.(io.Closer); ok {
+			c.Close()
+		}
+		if in.path != "" {
+			os.Remove(in.path)
+		}
+	}
 }
 
-func processJob(js *JobStatus, f multipart.File, kind string) {
-	start := time.Now()
-	js.State = StateRunning
-	js.StartedAt = time.Now()
-	js.UpdatedAt = time.Now()
+// requestError is a client error found while validating a job request. It
+// is written as the usual {error,message} envelope.
+type requestError struct {
+	Status  int
+	Code    string
+	Message string
+}
 
-	brokers := strings.Split(getenv("KAFKA_BROKERS", "localhost:19092"), ",")
+func (e *requestError) Error() string { return e.Code + ": " + e.Message }
 
-	// Create main topic writer with auto-creation
-	mainTopic := "batch_" + js.JobID
-	writer := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:      brokers,
-		Topic:        mainTopic,
-		Balancer:     &kafka.LeastBytes{},
-		RequiredAcks: 1,
-		Async:        false,
-	})
-	defer writer.Close()
-
-	// Create DLQ topic writer with auto-creation
-	dlqTopic := "batch_" + js.JobID + "_dlq"
-	dlqWriter := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:      brokers,
-		Topic:        dlqTopic,
-		Balancer:     &kafka.LeastBytes{},
-		RequiredAcks: 1,
-		Async:        false,
-	})
-	defer dlqWriter.Close()
+func invalid(code, msg string) *requestError {
+	return &requestError{Status: http.StatusBadRequest, Code: code, Message: msg}
+}
 
-	// Create topics if they don't exist
-	conn, err := kafka.Dial("tcp", brokers[0])
-	if err != nil {
-		log.Printf("Failed to connect to Kafka: %v", err)
-		js.State = StateFailed
-		js.UpdatedAt = time.Now()
+// writeError writes a *requestError as-is and anything else as a 500.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var rerr *requestError
+	if errors.As(err, &rerr) {
+		writeJSON(w, rerr.Status, ErrorResponse{Error: rerr.Code, Message: rerr.Message})
 		return
 	}
-	defer conn.Close()
+	internalError(w, r, err)
+}
 
-	// Create main topic
-	mainTopicConfig := kafka.TopicConfig{
-		Topic:             mainTopic,
-		NumPartitions:     1,
-		ReplicationFactor: 1,
-		ConfigEntries: []kafka.ConfigEntry{
-			{ConfigName: "cleanup.policy", ConfigValue: "delete"},
-			{ConfigName: "retention.ms", ConfigValue: "604800000"}, // 7 days
-		},
+// lookupModel returns the current version of the model a job in namespace
+// ns targets.
+func lookupModel(ns, modelID string) (Model, error) {
+	if modelID == "" {
+		return Model{}, invalid("MISSING_MODEL_ID", "model_id is required")
 	}
-
-	// Create DLQ topic
-	dlqTopicConfig := kafka.TopicConfig{
-		Topic:             dlqTopic,
-		NumPartitions:     1,
-		ReplicationFactor: 1,
-		ConfigEntries: []kafka.ConfigEntry{
-			{ConfigName: "cleanup.policy", ConfigValue: "delete"},
-			{ConfigName: "retention.ms", ConfigValue: "604800000"}, // 7 days
-		},
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	model, ok := models[modelKey(ns, modelID)]
+	if !ok {
+		return Model{}, invalid("MODEL_NOT_FOUND", "model not found")
 	}
+	return model, nil
+}
 
-	err = conn.CreateTopics(mainTopicConfig, dlqTopicConfig)
+// jobFields are the optional job settings shared by the multipart and JSON
+// forms of POST /jobs.
+type jobFields struct {
+	OutputFormat    string            `json:"output_format"`
+	CallbackURL     string            `json:"callback_url"`
+	Timeout         string            `json:"timeout"`
+	StrictColumns   *bool             `json:"strict_columns"` // nil means true
+	Encoding        string            `json:"encoding"`
+	Delimiter       string            `json:"delimiter"`
+	Tags            map[string]string `json:"tags"`
+	RateLimit       int               `json:"rate_limit"`
+	MaxErrors       *int              `json:"max_errors"`     // nil means unlimited
+	MaxErrorRate    *float64          `json:"max_error_rate"` // nil means unlimited
+	DedupeColumn    string            `json:"dedupe_column"`
+	KeyColumn       string            `json:"key_column"`
+	TombstoneColumn string            `json:"tombstone_column"`
+	CleanupPolicy   string            `json:"cleanup_policy"`
+	RetentionMS     int64             `json:"retention_ms"`
+	TargetTopic     string            `json:"target_topic"`
+	CreateTopic     bool              `json:"create_topic"`
+	ExactlyOnce     bool              `json:"exactly_once"`
+	TrimSpace       bool              `json:"trim_space"`
+	SkipBlankLines  bool              `json:"skip_blank_lines"`
+	Sync            bool              `json:"sync"` // answer with the final status if the job finishes in time
+}
+
+// formJobFields reads jobFields from a multipart form.
+func formJobFields(r *http.Request) (jobFields, error) {
+	f := jobFields{
+		OutputFormat:    r.FormValue("output_format"),
+		CallbackURL:     r.FormValue("callback_url"),
+		Timeout:         r.FormValue("timeout"),
+		Encoding:        r.FormValue("encoding"),
+		Delimiter:       r.FormValue("delimiter"),
+		DedupeColumn:    r.FormValue("dedupe_column"),
+		KeyColumn:       r.FormValue("key_column"),
+		TombstoneColumn: r.FormValue("tombstone_column"),
+		CleanupPolicy:   r.FormValue("cleanup_policy"),
+		TargetTopic:     r.FormValue("target_topic"),
+	}
+	tags, err := parseTags(r.FormValue("tags"))
 	if err != nil {
-		log.Printf("Failed to create topics (may already exist): %v", err)
-		// Continue anyway - topics might already exist
+		return f, err
 	}
-
-	// Helper function to send rejected row to DLQ
-	sendToDLQ := func(rowNum int, rawData string, errorMsg string) {
-		rejectedRow := RejectedRow{
-			JobID:     js.JobID,
-			RowNumber: rowNum,
-			RawData:   rawData,
-			Error:     errorMsg,
-			Timestamp: time.Now(),
+	f.Tags = tags
+	if v := r.FormValue("strict_columns"); v != "" {
+		strict, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, invalid("INVALID_STRICT_COLUMNS", "strict_columns must be true or false")
 		}
-
-		payload, err := json.Marshal(rejectedRow)
+		f.StrictColumns = &strict
+	}
+	if v := r.FormValue("rate_limit"); v != "" {
+		n, err := strconv.Atoi(v)
 		if err != nil {
-			log.Printf("Failed to marshal rejected row: %v", err)
-			return
+			return f, invalid("INVALID_RATE_LIMIT", "rate_limit must be a whole number of rows per second")
 		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		err = dlqWriter.WriteMessages(ctx, kafka.Message{
-			Key:   []byte(js.JobID),
-			Value: payload,
-		})
+		f.RateLimit = n
+	}
+	if v := r.FormValue("max_errors"); v != "" {
+		n, err := strconv.Atoi(v)
 		if err != nil {
-			log.Printf("Failed to write to DLQ: %v", err)
+			return f, invalid("INVALID_MAX_ERRORS", "max_errors must be a whole number of rows")
 		}
+		f.MaxErrors = &n
 	}
-
-	rl := csv.NewReader(f)
-	rowNumber := 0
-
-	for {
-		rowNumber++
-		rec, err := rl.Read()
-		if err == io.EOF {
-			break
+	if v := r.FormValue("max_error_rate"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return f, invalid("INVALID_MAX_ERROR_RATE", "max_error_rate must be a fraction between 0 and 1")
 		}
+		f.MaxErrorRate = &rate
+	}
+	if v := r.FormValue("create_topic"); v != "" {
+		create, err := strconv.ParseBool(v)
 		if err != nil {
-			js.Totals.Errors++
-			// Convert row to string for DLQ
-			rawData := ""
-			if rec != nil {
-				rawData = strings.Join(rec, ",")
-			}
-			sendToDLQ(rowNumber, rawData, err.Error())
-			continue
+			return f, invalid("INVALID_CREATE_TOPIC", "create_topic must be true or false")
 		}
-
-		js.Totals.Rows++
-
-		// Try to send to main topic
-		payload, err := json.Marshal(rec)
+		f.CreateTopic = create
+	}
+	if v := r.FormValue("exactly_once"); v != "" {
+		exactlyOnce, err := strconv.ParseBool(v)
 		if err != nil {
-			js.Totals.Errors++
-			sendToDLQ(rowNumber, strings.Join(rec, ","), "JSON marshal error: "+err.Error())
-			continue
+			return f, invalid("INVALID_EXACTLY_ONCE", "exactly_once must be true or false")
 		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		err = writer.WriteMessages(ctx, kafka.Message{
-			Key:   []byte(js.JobID),
-			Value: payload,
-		})
-
+		f.ExactlyOnce = exactlyOnce
+	}
+	if v := r.FormValue("trim_space"); v != "" {
+		trim, err := strconv.ParseBool(v)
 		if err != nil {
-			js.Totals.Errors++
-			sendToDLQ(rowNumber, strings.Join(rec, ","), "Kafka write error: "+err.Error())
-			continue
+			return f, invalid("INVALID_TRIM_SPACE", "trim_space must be true or false")
 		}
-
-		js.Totals.OK++
+		f.TrimSpace = trim
 	}
-
-	js.Timings.ProcessingMS = time.Since(start).Milliseconds()
-
-	// Determine final state
-	if js.Totals.Errors > 0 && js.Totals.OK > 0 {
-		js.State = StatePartialSuccess
-	} else if js.Totals.Errors > 0 {
-		js.State = StateFailed
-	} else {
-		js.State = StateSuccess
+	if v := r.FormValue("skip_blank_lines"); v != "" {
+		skip, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, invalid("INVALID_SKIP_BLANK_LINES", "skip_blank_lines must be true or false")
+		}
+		f.SkipBlankLines = skip
 	}
-
-	js.UpdatedAt = time.Now()
-
-	log.Printf("Job %s completed: %d rows, %d ok, %d errors",
-		js.JobID, js.Totals.Rows, js.Totals.OK, js.Totals.Errors)
-}
-
-func listJobs(w http.ResponseWriter, r *http.Request) {
-	jobsMu.RLock()
-	defer jobsMu.RUnlock()
-	var list []*JobStatus
-	for _, j := range jobs {
-		list = append(list, j)
+	if v := r.FormValue("sync"); v != "" {
+		sync, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, invalid("INVALID_SYNC", "sync must be true or false")
+		}
+		f.Sync = sync
 	}
-	writeJSON(w, http.StatusOK, list)
+	if v := r.FormValue("retention_ms"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, invalid("INVALID_RETENTION_MS", "retention_ms must be a whole number of milliseconds")
+		}
+		f.RetentionMS = n
+	}
+	return f, nil
 }
 
-func getJob(w http.ResponseWriter, r *http.Request) {
-	id := mux.Vars(r)["id"]
-	jobsMu.RLock()
+// parseJobOptions validates the request-level job settings.
+func parseJobOptions(f jobFields, reqID string) (JobOptions, error) {
+	opts := JobOptions{
+		OutputFormat:    f.OutputFormat,
+		RequestID:       reqID,
+		CallbackURL:     f.CallbackURL,
+		FitColumns:      f.StrictColumns != nil && !*f.StrictColumns,
+		DedupeColumn:    f.DedupeColumn,
+		KeyColumn:       f.KeyColumn,
+		TombstoneColumn: f.TombstoneColumn,
+		CleanupPolicy:   f.CleanupPolicy,
+		TargetTopic:     f.TargetTopic,
+		CreateTopic:     f.CreateTopic,
+		ExactlyOnce:     f.ExactlyOnce,
+		TrimSpace:       f.TrimSpace,
+		SkipBlankLines:  f.SkipBlankLines,
+	}
+	if opts.TargetTopic != "" {
+		if err := validTargetTopic(opts.TargetTopic); err != nil {
+			return opts, err
+		}
+	} else if opts.CreateTopic {
+		return opts, invalid("CREATE_TOPIC_REQUIRES_TARGET", "create_topic requires target_topic, here or in the model's defaults")
+	}
+	switch opts.CleanupPolicy {
+	case "":
+		opts.CleanupPolicy = "delete"
+	case "delete", "compact":
+	default:
+		return opts, invalid("INVALID_CLEANUP_POLICY", "cleanup_policy must be delete or compact")
+	}
+	switch {
+	case f.RetentionMS == 0:
+		opts.RetentionMS = defaultRetentionMS
+	case f.RetentionMS == -1 || f.RetentionMS > 0:
+		opts.RetentionMS = f.RetentionMS
+	default:
+		return opts, invalid("INVALID_RETENTION_MS", "retention_ms must be positive, or -1 to keep rows forever")
+	}
+	if opts.TombstoneColumn != "" && opts.KeyColumn == "" {
+		return opts, invalid("TOMBSTONE_REQUIRES_KEY", "tombstone_column requires key_column, since a tombstone is only a key")
+	}
+	if opts.CallbackURL != "" && !validCallbackURL(opts.CallbackURL) {
+		return opts, invalid("INVALID_CALLBACK_URL", "callback_url must be an absolute http or https URL")
+	}
+	enc, err := inputEncoding(f.Encoding)
+	if err != nil {
+		return opts, err
+	}
+	opts.Encoding = enc
+	if opts.Delimiter, err = parseDelimiter(f.Delimiter); err != nil {
+		return opts, err
+	}
+	if err := validateTags(f.Tags); err != nil {
+		return opts, err
+	}
+	opts.Tags = f.Tags
+	if f.Timeout != "" {
+		d, err := time.ParseDuration(f.Timeout)
+		if err != nil || d <= 0 {
+			return opts, invalid("INVALID_TIMEOUT", "timeout must be a positive duration such as 30m")
+		}
+		opts.Timeout = d
+	}
+	if f.RateLimit < 0 {
+		return opts, invalid("INVALID_RATE_LIMIT", "rate_limit must not be negative")
+	}
+	opts.RateLimit = f.RateLimit
+	if err := parseErrorThresholds(f.MaxErrors, f.MaxErrorRate); err != nil {
+		return opts, err
+	}
+	opts.MaxErrors, opts.MaxErrorRate = f.MaxErrors, f.MaxErrorRate
+	if opts.OutputFormat == "" {
+		opts.OutputFormat = OutputArray
+	}
+	switch opts.OutputFormat {
+	case OutputArray, OutputObject:
+	case OutputAvro:
+		if getenv("SCHEMA_REGISTRY_URL", "") == "" {
+			return opts, invalid("INVALID_OUTPUT_FORMAT", "avro output requires SCHEMA_REGISTRY_URL to be configured on the server")
+		}
+	case OutputProtobuf:
+	default:
+		return opts, invalid("INVALID_OUTPUT_FORMAT", "output_format must be array, object, avro or protobuf")
+	}
+	return opts, nil
+}
+
+// inspectFile sniffs the file type, checksums and measures the content
+// and derives the file type and column layout into opts. f is rewound
+// before returning.
+func inspectFile(f io.ReadSeeker, filename string, model Model, opts *JobOptions) (jf JobFile, err error) {
+	jf.Name = filename
+	opts.FileType, opts.Gzip, err = detectFileType(f, filename, opts.Encoding)
+	if err != nil {
+		return jf, err
+	}
+	opts.Delimiter = fileDelimiter(filename, opts.Delimiter)
+
+	jf.Checksum, jf.Size, err = fileChecksum(f)
+	if err != nil {
+		return jf, err
+	}
+
+	// Object output keys rows by the header; typed schemas need it to find
+	// each column, falling back to schema property order without one.
+	opts.Schema = rowschema.New(model.Schema)
+	var orcNames []string
+	if opts.FileType == FileORC {
+		// ORC names its columns, so without schema properties they serve
+		// as the header
+		if orcNames, err = orcColumns(f); err != nil {
+			return jf, err
+		}
+		if opts.Schema == nil {
+			opts.Columns = orcNames
+		}
+	}
+	if opts.OutputFormat == OutputObject || opts.Schema != nil {
+		if opts.FileType == FileCSV {
+			header, err := readHeader(f, *opts)
+			if err != nil {
+				return jf, err
+			}
+			if len(header) > 0 && (opts.OutputFormat == OutputObject || opts.Schema.IsHeader(header)) {
+				opts.Columns = header
+				opts.HasHeader = true
+			}
+		}
+		if len(opts.Columns) == 0 {
+			opts.Columns = rowschema.Columns(model.Schema)
+		}
+		if opts.OutputFormat == OutputObject && len(opts.Columns) == 0 {
+			return jf, invalid("CANNOT_DERIVE_KEYS", "object output requires a header row or schema properties")
+		}
+	}
+	if opts.FileType == FileNDJSON && len(opts.Columns) == 0 {
+		return jf, invalid("CANNOT_DERIVE_COLUMNS", "NDJSON input requires a model schema with properties")
+	}
+	if opts.FileType == FileORC {
+		found := false
+		for _, col := range opts.Columns {
+			found = found || indexOf(orcNames, col) >= 0
+		}
+		if !found {
+			return jf, invalid("CANNOT_DERIVE_COLUMNS", "ORC file has none of the model schema's properties as columns")
+		}
+	}
+	opts.Output, err = outputFields(model.Mapping, opts.Columns)
+	if err != nil {
+		return jf, err
+	}
+	opts.Computed = model.Computed
+	opts.Nulls = model.Nulls
+	for _, c := range []struct{ field, name string }{
+		{"dedupe_column", opts.DedupeColumn},
+		{"key_column", opts.KeyColumn},
+		{"tombstone_column", opts.TombstoneColumn},
+	} {
+		if c.name != "" && indexOf(opts.Columns, c.name) < 0 {
+			return jf, invalid("UNKNOWN_"+strings.ToUpper(c.field), c.field+" '"+c.name+"' is not a column of the file header or model schema")
+		}
+	}
+	return jf, deriveCodec(model, opts)
+}
+
+// deriveCodec sets up the encoder for avro and protobuf output from the
+// model's schema.
+func deriveCodec(model Model, opts *JobOptions) error {
+	switch opts.OutputFormat {
+	case OutputAvro:
+		if len(opts.Computed) > 0 {
+			return errComputedTyped
+		}
+		codec, err := newAvroCodec(model, opts.Schema)
+		if err != nil {
+			return invalid("CANNOT_DERIVE_AVRO_SCHEMA", err.Error())
+		}
+		opts.Avro = codec
+	case OutputProtobuf:
+		if len(opts.Computed) > 0 {
+			return errComputedTyped
+		}
+		codec, err := newProtoCodec(model, opts.Schema)
+		if err != nil {
+			return invalid("CANNOT_DERIVE_PROTOBUF_SCHEMA", err.Error())
+		}
+		opts.Proto = codec
+	}
+	return nil
+}
+
+// startJob registers js and processes its inputs in the background. The
+// job owns the inputs from then on and closes them when it ends.
+func startJob(js *JobStatus, inputs []jobInput, opts JobOptions) {
+	runJob(js, opts, func(ctx context.Context) {
+		defer closeInputs(inputs)
+		processJob(ctx, js, inputs, opts)
+	})
+}
+
+// jobInput is one file of a job. Opts carries the column layout found in
+// that file; files of one job may have different headers.
+type jobInput struct {
+	Name string
+	R    io.Reader
+	Opts JobOptions
+	path string // upload file the job removes when it ends, if any
+}
+
+// runJob registers js, runs work in the background and then delivers the
+// job's callback, if any. work's context ends when the job is cancelled,
+// its timeout passes or the server shuts down. The caller has reserved
+// the job's quota; runJob releases it when work returns.
+func runJob(js *JobStatus, opts JobOptions, work func(ctx context.Context)) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout := jobTimeout(opts.Timeout); timeout > 0 {
+		ctx, cancel = context.WithTimeout(jobsCtx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(jobsCtx)
+	}
+	js.opts = opts
+	js.cancel = cancel
+	js.Tags = opts.Tags
+	js.RateLimit = jobRateLimit(opts.RateLimit)
+	js.ExactlyOnce = opts.ExactlyOnce
+	js.metrics = &jobMetrics{}
+	js.quota = &rowQuota{ns: js.Namespace}
+	js.done = make(chan struct{})
+	js.Topic, _ = jobTopics(js.Namespace, js.JobID)
+	if opts.TargetTopic != "" {
+		js.Topic = opts.TargetTopic
+	}
+	jobsMu.Lock()
+	jobs[js.JobID] = js
+	jobsMu.Unlock()
+
+	jobsCreated.Inc()
+	jobsWG.Add(1)
+	go func() {
+		defer jobsWG.Done()
+		defer cancel()
+		work(ctx)
+		close(js.done)
+		jobsMu.RLock()
+		rows := js.Totals.Rows
+		jobsMu.RUnlock()
+		quotas.release(js.Namespace, int64(rows)-js.quota.charged)
+		if opts.CallbackURL != "" {
+			notifyCallback(js, opts.CallbackURL, slog.With("job_id", js.JobID, "request_id", opts.RequestID))
+		}
+	}()
+}
+
+// jobTimeout returns the processing limit for a job that asked for
+// requested (0 if it did not). JOB_TIMEOUT, when set, caps every job.
+func jobTimeout(requested time.Duration) time.Duration {
+	limit := envDuration("JOB_TIMEOUT", 0)
+	if requested > 0 && (limit == 0 || requested < limit) {
+		return requested
+	}
+	return limit
+}
+
+// jobRateLimit returns the rows-per-second cap for a job that asked for
+// requested (0 if it did not). MAX_ROWS_PER_SEC, when set, caps every job;
+// 0 means unlimited.
+func jobRateLimit(requested int) int {
+	limit, err := strconv.Atoi(getenv("MAX_ROWS_PER_SEC", "0"))
+	if err != nil || limit < 0 {
+		slog.Warn("invalid MAX_ROWS_PER_SEC, ignoring it", "value", getenv("MAX_ROWS_PER_SEC", ""))
+		limit = 0
+	}
+	if requested > 0 && (limit == 0 || requested < limit) {
+		return requested
+	}
+	return limit
+}
+
+// failJob ends a job that could not start processing.
+func failJob(js *JobStatus, reason string, err error) {
+	slog.Error("job failed before processing", "job_id", js.JobID, "model_id", js.ModelID,
+		"reason", reason, "error", err)
+	finishJob(js, StateFailed, reason)
+}
+
+// finishJob moves js to its final state unless it was cancelled or has
+// already ended meanwhile, e.g. failed as STALE, and reports whether it did.
+func finishJob(js *JobStatus, state JobState, reason string) bool {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	if js.Cancelled || js.State.Terminal() {
+		return false
+	}
+	js.State = state
+	js.Reason = reason
+	js.UpdatedAt = time.Now()
+	jobsFinished.WithLabelValues(string(state)).Inc()
+	return true
+}
+
+// retryJob starts a child job that reprocesses only the rows the parent job
+// sent to its DLQ, validated against the model's current version.
+func retryJob(w http.ResponseWriter, r *http.Request) {
+	if draining.Load() {
+		serviceUnavailable(w, "SHUTTING_DOWN", "server is shutting down")
+		return
+	}
+	parentID := mux.Vars(r)["id"]
+	jobsMu.RLock()
+	parent, ok := scopedJob(r, parentID)
+	var (
+		state   JobState
+		modelID string
+		opts    JobOptions
+	)
+	if ok {
+		state, modelID, opts = parent.State, parent.ModelID, parent.opts
+	}
+	jobsMu.RUnlock()
+	if !ok {
+		notFound(w, "JOB_NOT_FOUND", "job not found")
+		return
+	}
+	if !state.Terminal() {
+		conflict(w, "JOB_RUNNING", "job is still "+string(state))
+		return
+	}
+
+	modelsMu.RLock()
+	model, ok := models[modelKey(namespace(r), modelID)]
+	modelsMu.RUnlock()
+	if !ok {
+		badRequest(w, "MODEL_NOT_FOUND", "model not found")
+		return
+	}
+
+	// Rebuild a headerless CSV from the raw rows the parent rejected
+	var src bytes.Buffer
+	rows, _ := jobRejected(r.Context(), parentID, requestLogger(r))
+	for _, row := range rows {
+		if row.RawData == "" {
+			continue
+		}
+		src.WriteString(row.RawData)
+		src.WriteByte('\n')
+	}
+	if src.Len() == 0 {
+		conflict(w, "NO_REJECTED_ROWS", "job has no rejected rows to retry")
+		return
+	}
+
+	opts.HasHeader = false
+	opts.Encoding = nil // raw_data was decoded by the parent job
+	opts.Gzip = false
+	if opts.FileType == FileORC {
+		opts.FileType = FileNDJSON // raw_data holds rejected ORC rows as JSON objects
+	}
+	opts.RequestID = requestID(r)
+	opts.Schema = rowschema.New(model.Schema)
+	if opts.Schema != nil && len(opts.Columns) == 0 {
+		opts.Columns = rowschema.Columns(model.Schema)
+	}
+	output, err := outputFields(model.Mapping, opts.Columns)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	opts.Output = output
+	opts.Computed = model.Computed
+	opts.Nulls = model.Nulls
+	if err := deriveCodec(model, &opts); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	js := &JobStatus{
+		JobID:        randomID(),
+		Namespace:    model.Namespace,
+		ModelID:      modelID,
+		ModelVersion: model.Version,
+		State:        StatePending,
+		OutputFormat: opts.OutputFormat,
+		ParentJobID:  parentID,
+		UpdatedAt:    time.Now(),
+	}
+	if err := quotas.reserve(js.Namespace, 0); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	startJob(js, []jobInput{{R: bytes.NewReader(src.Bytes()), Opts: opts}}, opts)
+	audit(r, "job.retry", "job", js.JobID)
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"job_id": js.JobID, "parent_job_id": parentID})
+}
+
+// fileChecksum streams f through SHA-256, counting its bytes, and rewinds
+// it.
+func fileChecksum(f io.ReadSeeker) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// completedJobFor returns the ID of a job that already ingested a file with
+// this checksum into modelID in namespace ns and finished with SUCCESS or
+// PARTIAL_SUCCESS.
+func completedJobFor(ns, modelID, checksum string) string {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	for id, j := range jobs {
+		if j.Namespace == ns && j.ModelID == modelID && j.Checksum == checksum &&
+			(j.State == StateSuccess || j.State == StatePartialSuccess) {
+			return id
+		}
+	}
+	return ""
+}
+
+// readHeader returns the first CSV record of f and rewinds it.
+// A header that cannot be parsed is reported as empty.
+func readHeader(f io.ReadSeeker, opts JobOptions) ([]string, error) {
+	var header []string
+	if r, err := openInput(f, opts); err == nil {
+		header, _ = newCSVReader(r, opts).Read()
+	}
+	if opts.TrimSpace {
+		trimCells(header)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// rowPayload encodes a record according to the job's output format, typing
+// each cell from the model schema.
+func rowPayload(rec []string, opts JobOptions, rc rowContext) ([]byte, error) {
+	if n := len(opts.Columns); n > 0 && len(rec) != n {
+		if !opts.FitColumns {
+			return nil, schemaViolation(rowschema.CheckWidth(rec, n))
+		}
+		rec = rowschema.Fit(rec, n)
+	}
+	values, err := opts.Schema.Coerce(rec, opts.Columns, opts.Nulls)
+	if err != nil {
+		return nil, schemaViolation(err)
+	}
+	switch opts.OutputFormat {
+	case OutputAvro:
+		return opts.Avro.encode(values, opts.Columns)
+	case OutputProtobuf:
+		return opts.Proto.encode(values, opts.Columns)
+	}
+	names := opts.Columns
+	if opts.Output != nil {
+		names = make([]string, len(opts.Output))
+		mapped := make([]interface{}, len(opts.Output))
+		for i, f := range opts.Output {
+			names[i], mapped[i] = f.Name, values[f.Index]
+		}
+		values = mapped
+	}
+	if len(opts.Computed) > 0 {
+		names, values = addComputed(names, values, opts.Computed, rc)
+	}
+	if opts.OutputFormat != OutputObject {
+		return json.Marshal(values)
+	}
+	obj := make(map[string]interface{}, len(values))
+	for i, v := range values {
+		obj[names[i]] = v
+	}
+	return json.Marshal(obj)
+}
+
+// cell returns rec[i], or "" when rec has no such cell.
+func cell(rec []string, i int) string {
+	if i < 0 || i >= len(rec) {
+		return ""
+	}
+	return rec[i]
+}
+
+// trimCells strips leading and trailing white space from each cell of rec
+// in place.
+func trimCells(rec []string) {
+	for i, v := range rec {
+		rec[i] = strings.TrimSpace(v)
+	}
+}
+
+// blankRecord reports whether every cell of rec is empty or white space,
+// as for a line of spaces or of delimiters alone.
+func blankRecord(rec []string) bool {
+	for _, v := range rec {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// truthy reports whether a tombstone_column value marks a deletion: true,
+// t, 1, yes or y in any case.
+func truthy(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "t", "1", "yes", "y":
+		return true
+	}
+	return false
+}
+
+// processJob writes the rows of every input, in order, to the job's topics.
+// Totals and the final state cover all inputs.
+func processJob(ctx context.Context, js *JobStatus, inputs []jobInput, opts JobOptions) {
+	start := time.Now()
+	logger := slog.With("job_id", js.JobID, "model_id", js.ModelID, "request_id", opts.RequestID)
+	if js.ParentJobID != "" {
+		logger = logger.With("parent_job_id", js.ParentJobID)
+	}
+	jobsMu.Lock()
+	if js.Cancelled || js.State.Terminal() {
+		jobsMu.Unlock()
+		return
+	}
+	js.State = StateRunning
+	js.StartedAt = time.Now()
+	js.UpdatedAt = js.StartedAt
+	jobsMu.Unlock()
+
+	brokers := kafkaBrokers()
+	mainTopic, dlqTopic := jobTopics(js.Namespace, js.JobID)
+	if opts.TargetTopic != "" {
+		mainTopic = opts.TargetTopic
+	}
+
+	// Create topics if they don't exist
+	conn, err := kafkaDialer.DialContext(ctx, "tcp", brokers[0])
+	if err != nil {
+		logger.Error("failed to connect to Kafka", "error", err)
+		reason := ""
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			reason = "TIMEOUT"
+		}
+		finishJob(js, StateFailed, reason)
+		return
+	}
+	defer conn.Close()
+	// Unblock topic creation when the job is cancelled or times out
+	defer context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })()
+
+	// Create main topic
+	mainTopicConfig := kafka.TopicConfig{
+		Topic:             mainTopic,
+		NumPartitions:     1,
+		ReplicationFactor: 1,
+		ConfigEntries: []kafka.ConfigEntry{
+			{ConfigName: "cleanup.policy", ConfigValue: opts.CleanupPolicy},
+			{ConfigName: "retention.ms", ConfigValue: strconv.FormatInt(opts.RetentionMS, 10)},
+		},
+	}
+
+	// Create DLQ topic
+	dlqTopicConfig := kafka.TopicConfig{
+		Topic:             dlqTopic,
+		NumPartitions:     1,
+		ReplicationFactor: 1,
+		ConfigEntries: []kafka.ConfigEntry{
+			{ConfigName: "cleanup.policy", ConfigValue: "delete"},
+			{ConfigName: "retention.ms", ConfigValue: strconv.FormatInt(defaultRetentionMS, 10)},
+		},
+	}
+
+	// A caller-named topic is only created when the job asks for it
+	configs := []kafka.TopicConfig{mainTopicConfig, dlqTopicConfig}
+	if opts.TargetTopic != "" && !opts.CreateTopic {
+		configs = configs[1:]
+	}
+	err = conn.CreateTopics(configs...)
+	if err != nil {
+		logger.Warn("failed to create topics (may already exist)", "error", err)
+		// Continue anyway - topics might already exist
+	} else {
+		logger.Debug("created topics", "topic", mainTopic, "dlq_topic", dlqTopic)
+	}
+	if opts.TargetTopic != "" {
+		if _, err := conn.ReadPartitions(mainTopic); err != nil {
+			logger.Error("target topic is not available", "topic", mainTopic, "error", err)
+			finishJob(js, StateFailed, "TOPIC_NOT_FOUND")
+			return
+		}
+	}
+
+	if opts.Avro != nil {
+		id, err := registerSchema(mainTopic, "AVRO", opts.Avro.schema())
+		if err != nil {
+			logger.Error("failed to register Avro schema", "error", err)
+			finishJob(js, StateFailed, "SCHEMA_REGISTRY")
+			return
+		}
+		opts.Avro.schemaID = id
+		for _, in := range inputs {
+			if in.Opts.Avro != nil {
+				in.Opts.Avro.schemaID = id
+			}
+		}
+		logger.Debug("registered Avro schema", "schema_id", id)
+	}
+	if opts.Proto != nil && getenv("SCHEMA_REGISTRY_URL", "") != "" {
+		id, err := registerSchema(mainTopic, "PROTOBUF", opts.Proto.schema())
+		if err != nil {
+			logger.Error("failed to register Protobuf schema", "error", err)
+			finishJob(js, StateFailed, "SCHEMA_REGISTRY")
+			return
+		}
+		opts.Proto.schemaID = id
+		for _, in := range inputs {
+			if in.Opts.Proto != nil {
+				in.Opts.Proto.schemaID = id
+			}
+		}
+		logger.Debug("registered Protobuf schema", "schema_id", id)
+	}
+
+	// Passing max_errors or max_error_rate stops the job's remaining work
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	overThreshold := false
+
+	// Helper function to send rejected row to DLQ
+	var sourceFile string
+	sendToDLQ := func(rowNum int, rawData string, rerr *RowError) {
+		rejectedRow := RejectedRow{
+			JobID:         js.JobID,
+			RowNumber:     rowNum,
+			RawData:       rawData,
+			Error:         rerr.Message,
+			ErrorType:     rerr.Type,
+			Column:        rerr.Column,
+			ObservedValue: rerr.Observed,
+			ExpectedType:  rerr.Expected,
+			SourceFile:    sourceFile,
+			Timestamp:     time.Now(),
+		}
+
+		rowsRejected.WithLabelValues(js.ModelID).Inc()
+
+		jobsMu.Lock()
+		if len(js.rejected) < rejectedCacheMax {
+			js.rejected = append(js.rejected, rejectedRow)
+		} else {
+			js.truncated = true
+		}
+		if js.RejectionSummary == nil {
+			js.RejectionSummary = map[string]int{}
+		}
+		js.RejectionSummary[rerr.summaryKey()]++
+		jobsMu.Unlock()
+
+		if !overThreshold && opts.errorsExceeded(js.Totals.Errors, js.read, false) {
+			overThreshold = true
+			logger.Warn("job passed its error threshold", "errors", js.Totals.Errors, "read", js.read)
+			stop()
+		}
+
+		payload, err := json.Marshal(rejectedRow)
+		if err != nil {
+			logger.Error("failed to marshal rejected row", "row_number", rowNum, "error", err)
+			return
+		}
+
+		// The DLQ write is allowed to outlive job interruption
+		writeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err = kafkaWriter.WriteMessages(writeCtx, kafka.Message{
+			Topic: dlqTopic,
+			Key:   []byte(js.JobID),
+			Value: payload,
+		})
+		if err != nil {
+			logger.Error("failed to write to DLQ", "row_number", rowNum, "error", err)
+		}
+	}
+
+	var limiter *rate.Limiter
+	if js.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(js.RateLimit), 1)
+	}
+	var dedupe *dedupeSet
+	if opts.DedupeColumn != "" {
+		dedupe = newDedupeSet(func(msg string) {
+			logger.Warn("row deduplication disabled", "reason", msg)
+			jobsMu.Lock()
+			js.Warnings = append(js.Warnings, msg)
+			jobsMu.Unlock()
+		})
+		defer dedupe.Close()
+	}
+	var txn *txnProducer
+	if opts.ExactlyOnce {
+		txn, err = newTxnProducer(js.JobID, mainTopic, logger)
+		if err != nil {
+			logger.Error("failed to create transactional producer", "error", err)
+			finishJob(js, StateFailed, "KAFKA_UNAVAILABLE")
+			return
+		}
+		defer txn.close()
+	}
+	estimateRows(ctx, js, inputs)
+	interrupted := false
+	for _, in := range inputs {
+		sourceFile = in.Name
+		if interrupted = processInput(ctx, js, in, mainTopic, limiter, dedupe, txn, logger, sendToDLQ); interrupted {
+			break
+		}
+	}
+
+	jobDuration.Observe(time.Since(start).Seconds())
+	if !interrupted && opts.errorsExceeded(js.Totals.Errors, js.read, true) {
+		overThreshold = true
+		logger.Warn("job passed its error threshold", "errors", js.Totals.Errors, "read", js.read)
+	}
+
+	// Determine the final state; a job that was cancelled or failed as
+	// stale meanwhile keeps its state
+	jobsMu.Lock()
+	js.Timings.ProcessingMS = time.Since(start).Milliseconds()
+	ended := js.Cancelled || js.State.Terminal()
+	js.finishProgress(!interrupted && !ended)
+	js.UpdatedAt = time.Now()
+	if !ended {
+		js.State, js.Reason = finalState(ctx, js, interrupted, overThreshold)
+		jobsFinished.WithLabelValues(string(js.State)).Inc()
+	}
+	state, reason := js.State, js.Reason
+	jobsMu.Unlock()
+
+	if reason == "TIMEOUT" && !ended {
+		logger.Warn("job timed out", "rows", js.Totals.Rows)
+	}
+	logger.Info("job completed", "state", state,
+		"rows", js.Totals.Rows, "ok", js.Totals.OK, "errors", js.Totals.Errors)
+}
+
+// finalState is the state and reason a job that ran to its end, or was
+// interrupted, finishes with.
+func finalState(ctx context.Context, js *JobStatus, interrupted, overThreshold bool) (JobState, string) {
+	switch {
+	case overThreshold:
+		// Rows already written stay written, but the batch as a whole failed
+		return StateFailed, "ERROR_THRESHOLD_EXCEEDED"
+	case interrupted && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		// Rows already written stay written, so a timeout keeps them
+		if js.Totals.OK > 0 {
+			return StatePartialSuccess, "TIMEOUT"
+		}
+		return StateFailed, "TIMEOUT"
+	case js.quota.exceeded:
+		// As with a timeout, rows written before the limit stay written
+		if js.Totals.OK > 0 {
+			return StatePartialSuccess, "QUOTA_EXCEEDED"
+		}
+		return StateFailed, "QUOTA_EXCEEDED"
+	case interrupted:
+		return StateFailed, "SHUTDOWN"
+	case js.Totals.Errors > 0 && js.Totals.OK > 0:
+		return StatePartialSuccess, ""
+	case js.Totals.Errors > 0:
+		return StateFailed, ""
+	}
+	return StateSuccess, ""
+}
+
+// count adjusts js's row totals under jobsMu; a row cut off by the job
+// ending is taken back with a negative count.
+func (js *JobStatus) count(rows, ok, errs int) {
+	jobsMu.Lock()
+	js.Totals.Rows += rows
+	js.Totals.OK += ok
+	js.Totals.Errors += errs
+	jobsMu.Unlock()
+}
+
+// heartbeatInterval is how often a running job refreshes UpdatedAt so it
+// can be told apart from a hung one.
+const heartbeatInterval = 2 * time.Second
+
+// heartbeat marks js as alive, refreshes its progress and returns the time
+// it recorded.
+func heartbeat(js *JobStatus) time.Time {
+	now := time.Now()
+	jobsMu.Lock()
+	js.UpdatedAt = now
+	js.setProgress(now)
+	jobsMu.Unlock()
+	return now
+}
+
+// processInput writes the rows of one input, numbering them from the start
+// of its file. It reports whether ctx ended before the input was finished.
+// With txn set the rows are produced in transactions, the last of which is
+// ended before processInput returns.
+func processInput(ctx context.Context, js *JobStatus, in jobInput, mainTopic string, limiter *rate.Limiter, dedupe *dedupeSet, txn *txnProducer, logger *slog.Logger, sendToDLQ func(int, string, *RowError)) (interrupted bool) {
+	// settle ends txn's open transaction and counts its rows
+	settle := func() bool {
+		commitStart := time.Now()
+		rows, err := txn.commit(ctx)
+		if len(rows) > 0 {
+			js.metrics.write(time.Since(commitStart))
+		}
+		if err != nil && ctx.Err() != nil {
+			// Aborted by the job ending, so the rows were never produced
+			js.count(-len(rows), 0, 0)
+			return true
+		}
+		if err != nil {
+			logger.Error("Kafka transaction aborted", "file", in.Name, "rows", len(rows), "error", err)
+			js.count(0, 0, len(rows))
+			for _, row := range rows {
+				sendToDLQ(row.Number, row.Raw, &RowError{
+					Type:    ErrorTypeKafka,
+					Message: "Kafka transaction aborted: " + err.Error(),
+				})
+			}
+			return false
+		}
+		js.count(0, len(rows), 0)
+		js.metrics.produced(time.Now(), len(rows))
+		rowsProduced.WithLabelValues(js.ModelID).Add(float64(len(rows)))
+		return false
+	}
+	if txn != nil {
+		defer func() {
+			if settle() {
+				interrupted = true
+			}
+		}()
+	}
+
+	rl, err := openRows(in)
+	if err != nil {
+		logger.Error("failed to open input", "file", in.Name, "error", err)
+		js.count(0, 0, 1)
+		sendToDLQ(0, "", &RowError{Type: ErrorTypeParse, Message: "READ_ERROR: " + err.Error()})
+		return false
+	}
+	rowNumber := 0
+	dedupeIndex := indexOf(in.Opts.Columns, in.Opts.DedupeColumn)
+	keyIndex := indexOf(in.Opts.Columns, in.Opts.KeyColumn)
+	tombstoneIndex := indexOf(in.Opts.Columns, in.Opts.TombstoneColumn)
+
+	if in.Opts.HasHeader {
+		rowNumber++
+		if _, err := rl.Read(); err != nil {
+			logger.Warn("failed to read header", "file", in.Name, "error", err)
+		}
+	}
+
+	lastBeat := time.Now()
+	for {
+		if ctx.Err() != nil {
+			return true
+		}
+		if resume := resumeSignal(js); resume != nil {
+			// Commit first so the transaction does not time out while paused
+			if txn != nil && settle() {
+				return true
+			}
+			if !awaitResume(ctx, js, resume) {
+				return true
+			}
+			lastBeat = heartbeat(js)
+		}
+		if time.Since(lastBeat) >= heartbeatInterval {
+			lastBeat = heartbeat(js)
+		}
+		rowNumber++
+		rec, err := rl.Read()
+		if err == io.EOF {
+			break
+		}
+		if err == nil && in.Opts.SkipBlankLines && blankRecord(rec) {
+			continue
+		}
+		jobsMu.Lock()
+		js.read++
+		jobsMu.Unlock()
+		var perr *csv.ParseError
+		var rerr *RowError
+		if err != nil && !errors.As(err, &perr) && !errors.As(err, &rerr) {
+			// The input itself failed, e.g. a truncated gzip stream, so
+			// nothing after this point can be read
+			logger.Error("failed to read input", "file", in.Name, "row_number", rowNumber, "error", err)
+			js.count(0, 0, 1)
+			sendToDLQ(rowNumber, "", &RowError{Type: ErrorTypeParse, Message: "READ_ERROR: " + err.Error()})
+			return false
+		}
+		if err != nil {
+			js.count(0, 0, 1)
+			// Convert row to string for DLQ
+			rawData := ""
+			if rec != nil || rerr != nil {
+				rawData = rl.Raw(rec)
+			}
+			rerr = toRowError(err, ErrorTypeParse, "")
+			rerr.Observed = rawData
+			sendToDLQ(rowNumber, rawData, rerr)
+			continue
+		}
+		if in.Opts.TrimSpace {
+			trimCells(rec)
+		}
+		if rerr := checkRowLimits(rec, in.Opts.Columns); rerr != nil {
+			js.count(0, 0, 1)
+			// Only a preview is kept, so the row is left out of raw_data
+			// and of any retry
+			sendToDLQ(rowNumber, "", rerr)
+			continue
+		}
+		if !validText(rec, in.Opts.Encoding != nil) {
+			js.count(0, 0, 1)
+			rawData := strings.ToValidUTF8(rl.Raw(rec), "\uFFFD")
+			sendToDLQ(rowNumber, rawData, &RowError{
+				Type:     ErrorTypeParse,
+				Observed: rawData,
+				Message:  "INVALID_ENCODING: row contains bytes that cannot be decoded",
+			})
+			continue
+		}
+
+		if err := js.quota.take(); err != nil {
+			logger.Warn("namespace row quota reached", "file", in.Name, "row_number", rowNumber, "error", err)
+			return true
+		}
+		js.count(1, 0, 0)
+
+		key := []byte(js.JobID)
+		if keyIndex >= 0 {
+			key = nil
+			if v := cell(rec, keyIndex); v != "" {
+				key = []byte(v)
+			}
+		}
+		var payload []byte
+		// Tombstones are never deduplicated
+		tombstone := truthy(cell(rec, tombstoneIndex))
+		dedupeValue := cell(rec, dedupeIndex)
+		if tombstone {
+			// A tombstone is only a key, so the row's other cells are not typed
+			if key == nil {
+				js.count(0, 0, 1)
+				sendToDLQ(rowNumber, rl.Raw(rec), &RowError{
+					Type:    ErrorTypeSchemaViolation,
+					Column:  in.Opts.KeyColumn,
+					Message: "MISSING_KEY: tombstone row has an empty '" + in.Opts.KeyColumn + "' value",
+				})
+				continue
+			}
+		} else {
+			payload, err = rowPayload(rec, in.Opts, rowContext{JobID: js.JobID, Row: rowNumber, File: in.Name})
+			if err != nil {
+				js.count(0, 0, 1)
+				sendToDLQ(rowNumber, rl.Raw(rec), toRowError(err, ErrorTypeSchemaViolation, "JSON marshal error: "))
+				continue
+			}
+			if dedupe != nil && dedupe.Seen(dedupeValue) {
+				jobsMu.Lock()
+				js.Totals.Duplicates++
+				jobsMu.Unlock()
+				continue
+			}
+		}
+
+		if limiter != nil {
+			waitStart := time.Now()
+			err := limiter.Wait(ctx)
+			js.metrics.throttle(time.Since(waitStart))
+			if err != nil {
+				// Wait fails early when the next token is past the deadline
+				<-ctx.Done()
+				js.count(-1, 0, 0)
+				return true
+			}
+		}
+
+		if txn != nil {
+			err := txn.produce(ctx, key, payload, txnRow{Number: rowNumber, Raw: rl.Raw(rec)})
+			if err != nil {
+				js.count(0, 0, 1)
+				sendToDLQ(rowNumber, rl.Raw(rec), &RowError{
+					Type:    ErrorTypeKafka,
+					Message: "Kafka transaction error: " + err.Error(),
+				})
+				continue
+			}
+			if dedupe != nil && !tombstone {
+				dedupe.Add(dedupeValue)
+			}
+			if txn.due() && settle() {
+				return true
+			}
+			continue
+		}
+
+		writeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		writeStart := time.Now()
+		err = kafkaWriter.WriteMessages(writeCtx, kafka.Message{
+			Topic: mainTopic,
+			Key:   key,
+			Value: payload,
+		})
+		cancel()
+		kafkaWriteLatency.Observe(time.Since(writeStart).Seconds())
+		js.metrics.write(time.Since(writeStart))
+
+		if err != nil && ctx.Err() != nil {
+			// The row was cut off by the job ending, not rejected by Kafka
+			js.count(-1, 0, 0)
+			return true
+		}
+		if err != nil {
+			js.count(0, 0, 1)
+			sendToDLQ(rowNumber, rl.Raw(rec), &RowError{
+				Type:    ErrorTypeKafka,
+				Message: "Kafka write error: " + err.Error(),
+			})
+			continue
+		}
+
+		if dedupe != nil && !tombstone {
+			dedupe.Add(dedupeValue)
+		}
+		js.count(0, 1, 0)
+		js.metrics.produced(time.Now(), 1)
+		rowsProduced.WithLabelValues(js.ModelID).Inc()
+	}
+	return false
+}
+
+func listJobs(w http.ResponseWriter, r *http.Request) {
+	filters := r.URL.Query()["tag"]
+	ns := namespace(r)
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	var list []*JobStatus
+	for _, j := range jobs {
+		if j.Namespace == ns && matchTags(j.Tags, filters) {
+			list = append(list, j)
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// maxBulkJobIDs bounds how many jobs one POST /jobs/status may ask for.
+const maxBulkJobIDs = 1000
+
+// BulkJobStatus is the reply to POST /jobs/status.
+type BulkJobStatus struct {
+	Jobs     []*JobStatus `json:"jobs"`      // in request order, each ID once
+	NotFound []string     `json:"not_found"` // requested IDs with no job
+}
+
+// bulkJobStatus returns the status of every job named in a JSON array of
+// IDs, so watching many jobs costs one request instead of one per job.
+func bulkJobStatus(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		badRequest(w, "INVALID_JSON", "body must be a JSON array of job IDs: "+err.Error())
+		return
+	}
+	if len(ids) > maxBulkJobIDs {
+		badRequest(w, "TOO_MANY_JOB_IDS", "at most "+strconv.Itoa(maxBulkJobIDs)+" job IDs per request")
+		return
+	}
+	res := BulkJobStatus{Jobs: []*JobStatus{}, NotFound: []string{}}
+	seen := make(map[string]bool, len(ids))
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if j, ok := scopedJob(r, id); ok {
+			res.Jobs = append(res.Jobs, j)
+		} else {
+			res.NotFound = append(res.NotFound, id)
+		}
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func getJob(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	jobsMu.RLock()
 	defer jobsMu.RUnlock()
-	if j, ok := jobs[id]; ok {
+	if j, ok := scopedJob(r, id); ok {
 		writeJSON(w, http.StatusOK, j)
 	} else {
 		notFound(w, "JOB_NOT_FOUND", "job not found")
@@ -421,14 +2009,83 @@ func cancelJob(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 	jobsMu.Lock()
 	defer jobsMu.Unlock()
-	if j, ok := jobs[id]; ok {
-		j.State = StateCancelled
-		j.Cancelled = true
-		j.UpdatedAt = time.Now()
-		writeJSON(w, http.StatusAccepted, j)
-	} else {
+	j, ok := scopedJob(r, id)
+	if !ok {
 		notFound(w, "JOB_NOT_FOUND", "job not found")
+		return
+	}
+	if j.State.Terminal() {
+		conflict(w, "JOB_FINISHED", "job already finished as "+string(j.State))
+		return
+	}
+	markCancelled(j)
+	audit(r, "job.cancel", "job", id)
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// markCancelled records j as CANCELLED and stops its processing. A job
+// that already finished keeps its state. The caller holds jobsMu.
+func markCancelled(j *JobStatus) {
+	if j.State.Terminal() {
+		return
+	}
+	jobsFinished.WithLabelValues(string(StateCancelled)).Inc()
+	j.State = StateCancelled
+	j.Cancelled = true
+	j.UpdatedAt = time.Now()
+	if j.cancel != nil {
+		j.cancel()
+	}
+}
+
+// CancelledJobs lists the jobs a bulk cancel stopped.
+type CancelledJobs struct {
+	ModelID   string   `json:"model_id"`
+	Cancelled []string `json:"cancelled"`
+}
+
+// cancelModelJobs cancels every PENDING, RUNNING or PAUSED job of a model. Jobs of
+// a model that was force-deleted can still be cancelled this way.
+func cancelModelJobs(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ns := namespace(r)
+	modelsMu.RLock()
+	_, known := models[modelKey(ns, id)]
+	modelsMu.RUnlock()
+
+	res := CancelledJobs{ModelID: id, Cancelled: []string{}}
+	jobsMu.Lock()
+	for jobID, j := range jobs {
+		if j.Namespace != ns || j.ModelID != id {
+			continue
+		}
+		known = true
+		if !j.State.Terminal() {
+			markCancelled(j)
+			res.Cancelled = append(res.Cancelled, jobID)
+		}
+	}
+	jobsMu.Unlock()
+	if !known {
+		notFound(w, "MODEL_NOT_FOUND", "model not found")
+		return
 	}
+	sort.Strings(res.Cancelled)
+	requestLogger(r).Info("cancelled model jobs", "model_id", id, "count", len(res.Cancelled))
+	audit(r, "model.cancel_jobs", "model", id)
+	for _, jobID := range res.Cancelled {
+		audit(r, "job.cancel", "job", jobID)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// RejectedPage is one page of a job's rejected rows.
+type RejectedPage struct {
+	Rows      []RejectedRow `json:"rows"`
+	Total     int           `json:"total"`
+	Offset    int           `json:"offset"`
+	Limit     int           `json:"limit,omitempty"`
+	Truncated bool          `json:"truncated"` // the in-memory copy overflowed; rows came from the DLQ
 }
 
 func rejectedRows(w http.ResponseWriter, r *http.Request) {
@@ -436,64 +2093,401 @@ func rejectedRows(w http.ResponseWriter, r *http.Request) {
 
 	// Check if job exists
 	jobsMu.RLock()
-	if _, ok := jobs[jobId]; !ok {
+	if _, ok := scopedJob(r, jobId); !ok {
 		jobsMu.RUnlock()
 		notFound(w, "JOB_NOT_FOUND", "job not found")
 		return
 	}
 	jobsMu.RUnlock()
 
-	brokers := strings.Split(getenv("KAFKA_BROKERS", "localhost:19092"), ",")
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		streamRejectedCSV(w, r, jobId)
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		streamRejectedNDJSON(w, r, jobId)
+		return
+	}
+
+	offset, limit := 0, 0
+	q := r.URL.Query()
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			badRequest(w, "INVALID_PAGINATION", "offset must be a non-negative integer")
+			return
+		}
+		offset = n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			badRequest(w, "INVALID_PAGINATION", "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	rows, truncated := jobRejected(r.Context(), jobId, requestLogger(r))
+	page := RejectedPage{Total: len(rows), Offset: offset, Limit: limit, Truncated: truncated}
+	if offset < len(rows) {
+		rows = rows[offset:]
+		if limit > 0 && limit < len(rows) {
+			rows = rows[:limit]
+		}
+		page.Rows = rows
+	} else {
+		page.Rows = []RejectedRow{}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// jobRejected returns every row a job rejected. It serves the in-memory
+// copy when that is complete and reads the DLQ otherwise; if Kafka cannot
+// be read the partial copy is returned. truncated reports whether the
+// in-memory copy overflowed.
+func jobRejected(ctx context.Context, jobID string, logger *slog.Logger) (rows []RejectedRow, truncated bool) {
+	ns := defaultNamespace
+	jobsMu.RLock()
+	if j, ok := jobs[jobID]; ok {
+		rows = append([]RejectedRow{}, j.rejected...)
+		truncated = j.truncated
+		ns = j.Namespace
+	}
+	jobsMu.RUnlock()
+	if !truncated {
+		return rows, false
+	}
+
+	all, err := readRejected(ctx, ns, jobID, logger)
+	if err != nil {
+		logger.Warn("DLQ unreadable, serving truncated rejected rows", "job_id", jobID, "error", err)
+		return rows, true
+	}
+	return all, true
+}
+
+func rejectedCSV(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+	jobsMu.RLock()
+	_, ok := scopedJob(r, jobID)
+	jobsMu.RUnlock()
+	if !ok {
+		notFound(w, "JOB_NOT_FOUND", "job not found")
+		return
+	}
+	streamRejectedCSV(w, r, jobID)
+}
+
+func rejectedNDJSON(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+	jobsMu.RLock()
+	_, ok := scopedJob(r, jobID)
+	jobsMu.RUnlock()
+	if !ok {
+		notFound(w, "JOB_NOT_FOUND", "job not found")
+		return
+	}
+	streamRejectedNDJSON(w, r, jobID)
+}
+
+// streamRejectedCSV writes the job's DLQ as a CSV attachment. The columns
+// after timestamp carry the structured detail of each rejection.
+func streamRejectedCSV(w http.ResponseWriter, r *http.Request, jobID string) {
+	cw := csv.NewWriter(w)
+	begin := func() {
+		_ = cw.Write([]string{"row_number", "raw_data", "error", "timestamp",
+			"column", "error_type", "observed_value", "expected_type", "source_file"})
+		cw.Flush()
+	}
+	streamRejected(w, r, jobID, "text/csv", "csv", begin, func(row RejectedRow) error {
+		err := cw.Write([]string{
+			strconv.Itoa(row.RowNumber),
+			row.RawData,
+			row.Error,
+			row.Timestamp.UTC().Format(time.RFC3339),
+			row.Column,
+			string(row.ErrorType),
+			row.ObservedValue,
+			row.ExpectedType,
+			row.SourceFile,
+		})
+		cw.Flush()
+		if err == nil {
+			err = cw.Error()
+		}
+		return err
+	})
+}
+
+// streamRejectedNDJSON writes the job's DLQ as an NDJSON attachment, one
+// RejectedRow per line.
+func streamRejectedNDJSON(w http.ResponseWriter, r *http.Request, jobID string) {
+	enc := json.NewEncoder(w)
+	streamRejected(w, r, jobID, "application/x-ndjson", "ndjson", nil, func(row RejectedRow) error {
+		return enc.Encode(row)
+	})
+}
+
+// streamRejected writes the job's DLQ as an attachment, encoding each row
+// with write and flushing it as it is read from Kafka. begin, if set,
+// writes anything that precedes the rows.
+func streamRejected(w http.ResponseWriter, r *http.Request, jobID, contentType, ext string, begin func(), write func(RejectedRow) error) {
+	flusher, _ := w.(http.Flusher)
+	// Headers are sent with the first row so that a Kafka failure before
+	// anything was read can still be reported as an error response.
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="rejected_`+jobID+`.`+ext+`"`)
+		w.WriteHeader(http.StatusOK)
+		if begin != nil {
+			begin()
+		}
+	}
+	err := eachRejected(r.Context(), namespace(r), jobID, requestLogger(r), func(row RejectedRow) error {
+		start()
+		err := write(row)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return err
+	})
+	if err != nil && !started {
+		kafkaError(w, r, err)
+		return
+	}
+	if err != nil {
+		requestLogger(r).Error("rejected rows export interrupted", "job_id", jobID, "error", err)
+	}
+	start()
+}
+
+// rejectedCount reports how many messages the job's DLQ holds without
+// consuming them, from the partition watermarks.
+func rejectedCount(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+	jobsMu.RLock()
+	_, ok := scopedJob(r, jobID)
+	jobsMu.RUnlock()
+	if !ok {
+		notFound(w, "JOB_NOT_FOUND", "job not found")
+		return
+	}
+
+	count, err := dlqCount(r.Context(), namespace(r), jobID)
+	if err != nil {
+		kafkaError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int64{"count": count})
+}
+
+// dlqCount sums high minus low watermark over every partition of the job's
+// DLQ topic. A topic that does not exist yet counts as empty.
+func dlqCount(ctx context.Context, ns, jobID string) (int64, error) {
+	offsets, err := dlqOffsets(ctx, ns, jobID)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	for _, o := range offsets {
+		count += o.last - o.first
+	}
+	return count, nil
+}
+
+// partitionOffsets holds a partition's low and high watermarks.
+type partitionOffsets struct{ first, last int64 }
+
+// dlqOffsets returns the watermarks of every partition of the job's DLQ
+// topic, keyed by partition ID. A topic that does not exist yet has none.
+func dlqOffsets(ctx context.Context, ns, jobID string) (map[int]partitionOffsets, error) {
+	_, dlqTopic := jobTopics(ns, jobID)
+	conn, err := kafkaDialer.DialContext(ctx, "tcp", kafkaBrokers()[0])
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions(dlqTopic)
+	if errors.Is(err, kafka.UnknownTopicOrPartition) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[int]partitionOffsets, len(partitions))
+	for _, p := range partitions {
+		pc, err := kafkaDialer.DialLeader(ctx, "tcp", kafkaBrokers()[0], dlqTopic, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		first, err := pc.ReadFirstOffset()
+		if err == nil {
+			var last int64
+			last, err = pc.ReadLastOffset()
+			offsets[p.ID] = partitionOffsets{first, last}
+		}
+		pc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return offsets, nil
+}
+
+// readRejected returns the rows in a job's DLQ topic.
+func readRejected(ctx context.Context, ns, jobID string, logger *slog.Logger) ([]RejectedRow, error) {
+	rows := []RejectedRow{}
+	err := eachRejected(ctx, ns, jobID, logger, func(row RejectedRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+// eachRejected calls fn for every row in a job's DLQ topic, partition by
+// partition. It snapshots each partition's high watermark first and stops
+// once everything below it has been read, so an empty topic returns at
+// once and a large one is never cut short. It stops early when fn returns
+// an error.
+func eachRejected(ctx context.Context, ns, jobID string, logger *slog.Logger, fn func(RejectedRow) error) error {
+	offsets, err := dlqOffsets(ctx, ns, jobID)
+	if err != nil {
+		return err
+	}
+	var partitions []int
+	for p, o := range offsets {
+		if o.last > o.first {
+			partitions = append(partitions, p)
+		}
+	}
+	sort.Ints(partitions)
+
+	_, dlqTopic := jobTopics(ns, jobID)
+	for _, p := range partitions {
+		if err := eachRejectedIn(ctx, dlqTopic, p, offsets[p].last, logger.With("job_id", jobID), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// Create reader for DLQ topic with unique group ID
-	dlqTopic := "batch_" + jobId + "_dlq"
-	groupID := "rejected-rows-reader-" + jobId + "-" + randomID()
+// eachRejectedIn calls fn for the rows of one DLQ partition from its first
+// offset up to end, its high watermark. The reader is bound to the
+// partition rather than a consumer group, so reading commits no offsets
+// and leaves no group metadata behind in the cluster.
+func eachRejectedIn(ctx context.Context, topic string, partition int, end int64, logger *slog.Logger, fn func(RejectedRow) error) error {
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:     brokers,
-		Topic:       dlqTopic,
-		GroupID:     groupID,
-		StartOffset: kafka.FirstOffset,
+		Brokers:   kafkaBrokers(),
+		Topic:     topic,
+		Partition: partition,
+		Dialer:    kafkaDialer,
 	})
 	defer reader.Close()
+	if err := reader.SetOffset(kafka.FirstOffset); err != nil {
+		return err
+	}
 
-	var rejectedRows []RejectedRow
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	// Read all available messages from DLQ
 	for {
-		select {
-		case <-ctx.Done():
-			// Timeout reached, return what we have
-			writeJSON(w, http.StatusOK, rejectedRows)
-			return
-		default:
-			msg, err := reader.FetchMessage(ctx)
-			if err != nil {
-				// No more messages or error - return what we have
-				if len(rejectedRows) == 0 {
-					writeJSON(w, http.StatusOK, []RejectedRow{})
-				} else {
-					writeJSON(w, http.StatusOK, rejectedRows)
-				}
-				return
-			}
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return err
+		}
+		var row RejectedRow
+		if err := json.Unmarshal(msg.Value, &row); err != nil {
+			logger.Warn("failed to unmarshal rejected row", "partition", partition, "offset", msg.Offset, "error", err)
+		} else if err := fn(row); err != nil {
+			return err
+		}
+		if msg.Offset+1 >= end {
+			return nil
+		}
+	}
+}
 
-			var rejectedRow RejectedRow
-			if err := json.Unmarshal(msg.Value, &rejectedRow); err != nil {
-				log.Printf("Failed to unmarshal rejected row: %v", err)
-				reader.CommitMessages(ctx, msg)
-				continue
-			}
+// deleteJob drops a finished job from the store, and with ?topics=true
+// deletes its Kafka topics first. Jobs that are still PENDING, RUNNING or
+// PAUSED must be cancelled before they can be deleted.
+func deleteJob(w http.ResponseWriter, r *http.Request) {
+	removeJob(w, r, r.URL.Query().Get("topics") == "true", "job.delete")
+}
+
+// deleteJobTopics removes a job's Kafka topics and drops the job from the
+// store. Jobs that are still producing are refused.
+func deleteJobTopics(w http.ResponseWriter, r *http.Request) {
+	removeJob(w, r, true, "job.purge")
+}
+
+// removeJob drops a terminal job from the store, deleting its topics
+// first when topics is set, and audits it as action.
+func removeJob(w http.ResponseWriter, r *http.Request, topics bool, action string) {
+	id := mux.Vars(r)["id"]
+	jobsMu.RLock()
+	j, ok := scopedJob(r, id)
+	if !ok {
+		jobsMu.RUnlock()
+		notFound(w, "JOB_NOT_FOUND", "job not found")
+		return
+	}
+	state := j.State
+	jobsMu.RUnlock()
+	if !state.Terminal() {
+		conflict(w, "JOB_RUNNING", "job is still "+string(state)+"; cancel it first")
+		return
+	}
 
-			rejectedRows = append(rejectedRows, rejectedRow)
-			reader.CommitMessages(ctx, msg)
+	if topics {
+		if _, err := deleteTopics(namespace(r), id); err != nil {
+			kafkaError(w, r, err)
+			return
 		}
 	}
+
+	jobsMu.Lock()
+	delete(jobs, id)
+	jobsMu.Unlock()
+	audit(r, action, "job", id)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // ------------------ helpers ------------------
 
+// deleteTopics removes a job's main and DLQ topics, tolerating topics that
+// were never created, and reports how many it actually deleted.
+func deleteTopics(ns, jobID string) (int, error) {
+	conn, err := kafkaDialer.Dial("tcp", kafkaBrokers()[0])
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	deleted := 0
+	mainTopic, dlqTopic := jobTopics(ns, jobID)
+	for _, topic := range []string{mainTopic, dlqTopic} {
+		err := conn.DeleteTopics(topic)
+		switch {
+		case err == nil:
+			deleted++
+		case !errors.Is(err, kafka.UnknownTopicOrPartition):
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// ErrorResponse is the {error,message} envelope of every failed request.
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -501,25 +2495,49 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 }
 
 func badRequest(w http.ResponseWriter, code, msg string) {
-	writeJSON(w, http.StatusBadRequest, map[string]string{
-		"error":   code,
-		"message": msg,
-	})
+	writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: code, Message: msg})
+}
+
+// fileTooLarge reports a job whose files exceed maxUploadBytes.
+func fileTooLarge(w http.ResponseWriter) {
+	writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "FILE_TOO_LARGE", Message: uploadLimitMessage("file")})
+}
+
+// uploadLimitMessage says that what is over maxUploadBytes.
+func uploadLimitMessage(what string) string {
+	return fmt.Sprintf("%s exceeds the %d-byte upload limit (MAX_UPLOAD_BYTES)", what, maxUploadBytes)
 }
 
 func notFound(w http.ResponseWriter, code, msg string) {
-	writeJSON(w, http.StatusNotFound, map[string]string{
-		"error":   code,
-		"message": msg,
-	})
+	writeJSON(w, http.StatusNotFound, ErrorResponse{Error: code, Message: msg})
 }
 
-func internalError(w http.ResponseWriter, err error) {
-	log.Println("internal error:", err)
-	writeJSON(w, http.StatusInternalServerError, map[string]string{
-		"error":   "INTERNAL_ERROR",
-		"message": err.Error(),
-	})
+func unauthorized(w http.ResponseWriter, code, msg string) {
+	writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: code, Message: msg})
+}
+
+func conflict(w http.ResponseWriter, code, msg string) {
+	writeJSON(w, http.StatusConflict, ErrorResponse{Error: code, Message: msg})
+}
+
+func serviceUnavailable(w http.ResponseWriter, code, msg string) {
+	writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: code, Message: msg})
+}
+
+// kafkaError reports a failed Kafka call: 503 when the brokers could not
+// be reached, 500 otherwise.
+func kafkaError(w http.ResponseWriter, r *http.Request, err error) {
+	var unreachable *net.OpError
+	if errors.As(err, &unreachable) {
+		serviceUnavailable(w, "KAFKA_UNAVAILABLE", err.Error())
+		return
+	}
+	internalError(w, r, err)
+}
+
+func internalError(w http.ResponseWriter, r *http.Request, err error) {
+	requestLogger(r).Error("internal error", "error", err)
+	writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "INTERNAL_ERROR", Message: err.Error()})
 }
 
 func randomID() string {
@@ -537,3 +2555,48 @@ func healthCheck(w http.ResponseWriter, r *http.Request) {
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 	})
 }
+
+// readiness caches the last Kafka reachability probe so frequent readiness
+// checks don't hammer the brokers.
+var readiness struct {
+	sync.Mutex
+	checked time.Time
+	err     error
+}
+
+const readinessTTL = 2 * time.Second
+
+// kafkaReachable reports whether any configured broker accepts a connection.
+func kafkaReachable() error {
+	readiness.Lock()
+	defer readiness.Unlock()
+	if time.Since(readiness.checked) < readinessTTL {
+		return readiness.err
+	}
+
+	dialer := *kafkaDialer
+	dialer.Timeout = 2 * time.Second
+	readiness.err = nil
+	for _, broker := range kafkaBrokers() {
+		conn, err := dialer.Dial("tcp", broker)
+		if err == nil {
+			conn.Close()
+			readiness.err = nil
+			break
+		}
+		readiness.err = err
+	}
+	readiness.checked = time.Now()
+	return readiness.err
+}
+
+func readyCheck(w http.ResponseWriter, r *http.Request) {
+	if err := kafkaReachable(); err != nil {
+		serviceUnavailable(w, "NOT_READY", "kafka unreachable: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":    "ready",
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
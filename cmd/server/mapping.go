@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/keithchambers/batch-ingestion/internal/rowschema"
+)
+
+// ColumnMapping renames and drops source columns on the way to the
+// produced payload. Columns keep their source names everywhere else: in
+// the schema, key_column, dedupe_column and the DLQ.
+type ColumnMapping struct {
+	Rename       map[string]string `json:"rename,omitempty"`        // source column -> output field
+	Exclude      []string          `json:"exclude,omitempty"`       // source columns never produced
+	DropUnmapped bool              `json:"drop_unmapped,omitempty"` // produce only the renamed columns
+}
+
+// outputField is one field of a produced row: the cell at Index, written
+// under Name.
+type outputField struct {
+	Index int
+	Name  string
+}
+
+// outputName returns the field name cols' column col is produced under,
+// and false if it is not produced.
+func (m *ColumnMapping) outputName(col string) (string, bool) {
+	if m == nil {
+		return col, true
+	}
+	if indexOf(m.Exclude, col) >= 0 {
+		return "", false
+	}
+	if name, ok := m.Rename[col]; ok {
+		return name, true
+	}
+	return col, !m.DropUnmapped
+}
+
+// outputFields plans the produced fields for rows with the given columns.
+// A nil mapping produces every column as-is and returns nil.
+func outputFields(m *ColumnMapping, cols []string) ([]outputField, error) {
+	if m == nil {
+		return nil, nil
+	}
+	if len(cols) == 0 {
+		return nil, invalid("CANNOT_DERIVE_COLUMNS", "the model's column mapping requires a header row or schema properties")
+	}
+	fields := make([]outputField, 0, len(cols))
+	seen := map[string]string{}
+	for i, col := range cols {
+		name, ok := m.outputName(col)
+		if !ok {
+			continue
+		}
+		if other, dup := seen[name]; dup {
+			return nil, invalid("DUPLICATE_OUTPUT_FIELD", "columns '"+other+"' and '"+col+"' would both be produced as '"+name+"'")
+		}
+		seen[name] = col
+		fields = append(fields, outputField{Index: i, Name: name})
+	}
+	return fields, nil
+}
+
+// validateMapping checks m's mapping against its schema properties.
+func validateMapping(m Model) error {
+	cm := m.Mapping
+	if cm == nil {
+		return nil
+	}
+	sources := make([]string, 0, len(cm.Rename))
+	for src := range cm.Rename {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+	for _, src := range sources {
+		if cm.Rename[src] == "" {
+			return invalid("INVALID_MAPPING", "mapping: column '"+src+"' is renamed to an empty name")
+		}
+		if indexOf(cm.Exclude, src) >= 0 {
+			return invalid("INVALID_MAPPING", "mapping: column '"+src+"' is both renamed and excluded")
+		}
+	}
+	// Renamed columns missing from the schema can still come from a
+	// header, so they take part in the duplicate check too.
+	cols := rowschema.Columns(m.Schema)
+	for _, src := range sources {
+		if indexOf(cols, src) < 0 {
+			cols = append(cols, src)
+		}
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+	if _, err := outputFields(cm, cols); err != nil {
+		var rerr *requestError
+		if errors.As(err, &rerr) {
+			return invalid(rerr.Code, "mapping: "+rerr.Message)
+		}
+		return err
+	}
+	return nil
+}
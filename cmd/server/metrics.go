@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	jobsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "batch_jobs_created_total",
+		Help: "Jobs accepted by POST /jobs.",
+	})
+	jobsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "batch_jobs_finished_total",
+		Help: "Jobs that reached a terminal state, by state.",
+	}, []string{"state"})
+	rowsProduced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "batch_rows_produced_total",
+		Help: "Rows written to a job's main topic, by model.",
+	}, []string{"model_id"})
+	rowsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "batch_rows_rejected_total",
+		Help: "Rows routed to a job's DLQ, by model.",
+	}, []string{"model_id"})
+	jobDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "batch_job_processing_seconds",
+		Help:    "Time from job start to its terminal state.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 14),
+	})
+	kafkaWriteLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "batch_kafka_write_seconds",
+		Help:    "Latency of individual produce calls to the main topic.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	})
+)
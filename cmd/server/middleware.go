@@ -0,0 +1,291 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	principalKey        // API key fingerprint, set by authMiddleware
+	namespaceKey        // tenant, set by namespaceMiddleware
+)
+
+// requestID returns the correlation ID assigned to r, if any.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+// requestLogger returns the default logger tagged with r's request ID.
+func requestLogger(r *http.Request) *slog.Logger {
+	return slog.With("request_id", requestID(r))
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// requestIDMiddleware propagates X-Request-ID (generating one when absent)
+// through the request context and response, and logs each request with it.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			id = randomID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		requestLogger(r).Info("request",
+			"method", r.Method, "path", r.URL.Path, "status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds())
+	})
+}
+
+// gzipMinBytes is the smallest response body worth compressing
+// (GZIP_MIN_BYTES, default 1024).
+var gzipMinBytes = func() int {
+	n, err := strconv.Atoi(getenv("GZIP_MIN_BYTES", "1024"))
+	if err != nil || n < 0 {
+		return 1024
+	}
+	return n
+}()
+
+// gzipMiddleware compresses responses for clients that accept gzip once
+// the body reaches gzipMinBytes. Smaller bodies, and responses the handler
+// flushes before reaching that size (event streams, CSV exports), are
+// sent as they are.
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether Accept-Encoding lists gzip without q=0.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			v, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			q, err := strconv.ParseFloat(v, 64)
+			return !ok || err != nil || q > 0
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter holds back the status and the start of the body until
+// it knows whether the response is large enough to compress.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.status == 0 {
+		g.status = code
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if !g.decided {
+		g.buf = append(g.buf, p...)
+		if len(g.buf) >= gzipMinBytes {
+			if err := g.start(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	return g.ResponseWriter.Write(p)
+}
+
+// start sends the status, compressed if compress is set and the response
+// is eligible, followed by whatever was buffered.
+func (g *gzipResponseWriter) start(compress bool) error {
+	g.decided = true
+	h := g.Header()
+	if compress && h.Get("Content-Encoding") == "" && !strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+	buf := g.buf
+	g.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if g.gz != nil {
+		_, err = g.gz.Write(buf)
+	} else {
+		_, err = g.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush commits a response that is still undecided to being uncompressed,
+// so streams reach the client as soon as the handler asks.
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		if g.status == 0 {
+			g.status = http.StatusOK
+		}
+		_ = g.start(false)
+	} else if g.gz != nil {
+		_ = g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close sends a response that stayed below gzipMinBytes and terminates the
+// gzip stream.
+func (g *gzipResponseWriter) Close() {
+	if !g.decided && g.status != 0 {
+		_ = g.start(false)
+	}
+	if g.gz != nil {
+		_ = g.gz.Close()
+	}
+}
+
+// publicPaths are served without authentication so probes keep working
+// and integrators can fetch the API description.
+var publicPaths = map[string]bool{
+	"/healthz":      true,
+	"/readyz":       true,
+	"/openapi.json": true,
+}
+
+// authMiddleware requires "Authorization: Bearer <key>" matching one of
+// API_KEYS. When API_KEYS is unset every request is let through.
+func authMiddleware() mux.MiddlewareFunc {
+	var keys []string
+	for _, k := range strings.Split(getenv("API_KEYS", ""), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(keys) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if publicPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || !validKey(keys, token) {
+				unauthorized(w, "UNAUTHORIZED", "missing or invalid API key")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey, keyFingerprint(token))))
+		})
+	}
+}
+
+func validKey(keys []string, token string) bool {
+	match := 0
+	for _, k := range keys {
+		match |= subtle.ConstantTimeCompare([]byte(k), []byte(token))
+	}
+	return match == 1
+}
+
+// withMiddleware applies mw to h in the order mux.Router.Use would. mux
+// only runs its middleware for matched routes, so the fallback handlers are
+// wrapped explicitly.
+func withMiddleware(h http.Handler, mw ...mux.MiddlewareFunc) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i].Middleware(h)
+	}
+	return h
+}
+
+// routeNotFound answers a path no route serves.
+func routeNotFound(w http.ResponseWriter, r *http.Request) {
+	notFound(w, "NOT_FOUND", "no route for "+r.Method+" "+r.URL.Path)
+}
+
+// methodNotAllowed answers a request whose path is served, but not for its
+// method, listing the methods that are in Allow.
+func methodNotAllowed(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allow := allowedMethods(router, r)
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{
+			Error:   "METHOD_NOT_ALLOWED",
+			Message: r.Method + " is not supported on " + r.URL.Path + "; use " + strings.Join(allow, ", "),
+		})
+	}
+}
+
+// allowedMethods returns the methods of every route matching r's path, in
+// registration order.
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	var allow []string
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			req := r.WithContext(r.Context())
+			req.Method = method
+			if indexOf(allow, method) < 0 && route.Match(req, &mux.RouteMatch{}) {
+				allow = append(allow, method)
+			}
+		}
+		return nil
+	})
+	return allow
+}
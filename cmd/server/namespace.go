@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+)
+
+// Every model and job belongs to the namespace of the request that created
+// it, taken from the X-Namespace header. Requests only see and act on their
+// own namespace; one without the header uses defaultNamespace, whose
+// topics keep their un-prefixed names.
+const defaultNamespace = "default"
+
+// namespaceName keeps namespaces usable inside topic names. "_" is left out
+// because it separates the namespace from the job ID.
+var namespaceName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// namespaceMiddleware resolves the request's namespace, rejecting invalid
+// names with 400 INVALID_NAMESPACE.
+func namespaceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ns := r.Header.Get("X-Namespace")
+		if ns == "" {
+			ns = defaultNamespace
+		}
+		if !namespaceName.MatchString(ns) {
+			badRequest(w, "INVALID_NAMESPACE", "X-Namespace must be 1-32 lowercase letters, digits or '-', starting with a letter or digit")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), namespaceKey, ns)))
+	})
+}
+
+// namespace returns the namespace r acts in.
+func namespace(r *http.Request) string {
+	if ns, ok := r.Context().Value(namespaceKey).(string); ok {
+		return ns
+	}
+	return defaultNamespace
+}
+
+// modelKey is the key of a model in models and versions. Model IDs can be
+// chosen by the caller, so they are only unique within a namespace.
+func modelKey(ns, id string) string {
+	return ns + "/" + id
+}
+
+// scopedJob returns job id if it belongs to r's namespace. Job IDs are
+// generated, so jobs stay keyed by ID alone. The caller holds jobsMu.
+func scopedJob(r *http.Request, id string) (*JobStatus, bool) {
+	j, ok := jobs[id]
+	if !ok || j.Namespace != namespace(r) {
+		return nil, false
+	}
+	return j, true
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/keithchambers/batch-ingestion/internal/rowschema"
+)
+
+// validateNulls checks m's null values against its schema properties. A
+// schema without properties takes its columns from the file header, so
+// any per-column override is allowed then.
+func validateNulls(m Model) error {
+	if m.Nulls == nil {
+		return nil
+	}
+	cols := rowschema.Columns(m.Schema)
+	names := make([]string, 0, len(m.Nulls.Columns))
+	for col := range m.Nulls.Columns {
+		names = append(names, col)
+	}
+	sort.Strings(names)
+	for _, col := range names {
+		if col == "" {
+			return invalid("INVALID_NULLS", "nulls: column names must not be empty")
+		}
+		if len(cols) > 0 && indexOf(cols, col) < 0 {
+			return invalid("UNKNOWN_NULL_COLUMN", "nulls: column '"+col+"' is not a property of the schema")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+
+	"github.com/keithchambers/batch-ingestion/internal/rowschema"
+)
+
+// apiOperation documents one route for the OpenAPI document. Request and
+// response shapes are Go values whose types are reflected into schemas, so
+// the document follows the structs the handlers actually encode.
+type apiOperation struct {
+	Summary  string
+	Query    map[string]string // query parameter name to description
+	Body     interface{}       // JSON request body
+	Form     interface{}       // multipart/form-data request body
+	Status   int               // success status
+	Response interface{}       // JSON success body; nil for none
+	Produces string            // success content type when it is not JSON
+	Errors   []int             // statuses answered with ErrorResponse
+	Cached   bool              // sends an ETag and honours If-None-Match
+	Sync     bool              // may wait and answer 200, 207 or 422 with the final JobStatus
+}
+
+// binaryFile is a file part of a multipart form.
+type binaryFile []byte
+
+// jobUploadForm is the multipart form of POST /jobs.
+type jobUploadForm struct {
+	ModelID string       `json:"model_id"`
+	File    []binaryFile `json:"file"` // repeat the part to upload several files
+	Dedupe  bool         `json:"dedupe,omitempty"`
+	jobFields
+	Tags string `json:"tags,omitempty"` // key=value pairs or a JSON object; shadows jobFields.Tags
+}
+
+// modelRequest is the body of POST and PUT /models; the server sets the
+// version and creation time.
+type modelRequest struct {
+	ID     string          `json:"id,omitempty"` // generated when empty; ignored by PUT
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	// Defaults are kept from the current version when PUT omits them
+	Defaults *ModelDefaults `json:"defaults,omitempty"`
+	// Mapping is kept from the current version when PUT omits it
+	Mapping *ColumnMapping `json:"mapping,omitempty"`
+	// Computed is kept from the current version when PUT omits it
+	Computed []ComputedField `json:"computed,omitempty"`
+	// Nulls is kept from the current version when PUT omits it
+	Nulls *rowschema.Nulls `json:"nulls,omitempty"`
+}
+
+// jobAccepted is the body of a 202 reply to a job request.
+type jobAccepted struct {
+	JobID        string `json:"job_id"`
+	ParentJobID  string `json:"parent_job_id,omitempty"`
+	Deduplicated bool   `json:"deduplicated,omitempty"`
+}
+
+type statusResponse struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type countResponse struct {
+	Count int64 `json:"count"`
+}
+
+// apiOperations is keyed by "METHOD /path/template" as registered on the
+// router.
+var apiOperations = map[string]apiOperation{
+	"GET /models":  {Summary: "List models", Status: http.StatusOK, Response: []Model{}, Cached: true},
+	"POST /models": {Summary: "Create a model", Body: modelRequest{}, Status: http.StatusCreated, Response: Model{}, Errors: []int{400, 409}},
+	"GET /models/{id}": {Summary: "Get a model", Status: http.StatusOK, Response: Model{},
+		Query: map[string]string{"version": "Return this revision instead of the latest"}, Errors: []int{404}, Cached: true},
+	"PUT /models/{id}": {Summary: "Add a new version of a model", Body: modelRequest{}, Status: http.StatusOK, Response: Model{},
+		Query: map[string]string{
+			"force":         "Update even while jobs are using the model",
+			"compatibility": "BACKWARD, FORWARD, FULL (default) or NONE",
+		}, Errors: []int{400, 404, 409}},
+	"DELETE /models/{id}": {Summary: "Delete a model", Status: http.StatusNoContent,
+		Query: map[string]string{"force": "Delete even while jobs are using the model"}, Errors: []int{404, 409}},
+	"GET /models/{id}/versions": {Summary: "List every version of a model, oldest first", Status: http.StatusOK, Response: []Model{}, Errors: []int{404}},
+	"POST /models/{id}/clone": {Summary: "Create a model from the latest version of another", Body: modelCloneRequest{},
+		Status: http.StatusCreated, Response: Model{}, Errors: []int{400, 404, 409}},
+	"POST /models/{id}/jobs/cancel": {Summary: "Cancel every pending, running or paused job of a model", Status: http.StatusOK,
+		Response: CancelledJobs{}, Errors: []int{404}},
+	"POST /jobs": {Summary: "Create a job from uploaded files or a source URL", Form: jobUploadForm{}, Body: jobSourceRequest{},
+		Status: http.StatusAccepted, Response: jobAccepted{}, Errors: []int{400, 429, 503}, Sync: true},
+	"GET /jobs": {Summary: "List jobs", Status: http.StatusOK, Response: []JobStatus{},
+		Query: map[string]string{"tag": "Only jobs tagged key:value (or just key); repeat to require several tags"}},
+	"POST /jobs/status": {Summary: "Get the status of several jobs at once", Body: []string{}, Status: http.StatusOK,
+		Response: BulkJobStatus{}, Errors: []int{400}},
+	"GET /jobs/{id}": {Summary: "Get a job's status", Status: http.StatusOK, Response: JobStatus{}, Errors: []int{404}},
+	"DELETE /jobs/{id}": {Summary: "Delete a finished job's record", Status: http.StatusNoContent,
+		Query: map[string]string{"topics": "Also delete the job's main and DLQ topics"}, Errors: []int{404, 409, 503}},
+	"POST /jobs/{id}/cancel": {Summary: "Cancel a job", Status: http.StatusAccepted, Response: JobStatus{}, Errors: []int{404, 409}},
+	"GET /jobs/{id}/events": {Summary: "Stream status changes as server-sent events", Status: http.StatusOK,
+		Produces: "text/event-stream", Errors: []int{404}},
+	"GET /jobs/{id}/metrics": {Summary: "Get a job's throughput, Kafka write latency and time breakdown", Status: http.StatusOK,
+		Response: JobMetrics{}, Errors: []int{404}},
+	"POST /jobs/{id}/pause": {Summary: "Pause a running job after its current row", Status: http.StatusAccepted,
+		Response: JobStatus{}, Errors: []int{404, 409}},
+	"POST /jobs/{id}/resume": {Summary: "Resume a paused job where it stopped", Status: http.StatusAccepted,
+		Response: JobStatus{}, Errors: []int{404, 409}},
+	"GET /jobs/{id}/rejected": {Summary: "Page through a job's rejected rows", Status: http.StatusOK, Response: RejectedPage{},
+		Query: map[string]string{"offset": "Rows to skip", "limit": "Maximum rows to return"}, Errors: []int{400, 404, 503}},
+	"GET /jobs/{id}/rejected/count": {Summary: "Count a job's rejected rows from DLQ offsets", Status: http.StatusOK,
+		Response: countResponse{}, Errors: []int{404, 503}},
+	"GET /jobs/{id}/rejected.csv": {Summary: "Download a job's rejected rows as CSV", Status: http.StatusOK,
+		Produces: "text/csv", Errors: []int{404, 503}},
+	"GET /jobs/{id}/rejected.ndjson": {Summary: "Download a job's rejected rows as NDJSON", Status: http.StatusOK,
+		Produces: "application/x-ndjson", Errors: []int{404, 503}},
+	"POST /jobs/{id}/retry": {Summary: "Reprocess a finished job's rejected rows as a new job", Status: http.StatusAccepted,
+		Response: jobAccepted{}, Errors: []int{400, 404, 409, 429, 503}},
+	"DELETE /jobs/{id}/topics": {Summary: "Delete a finished job's topics and record", Status: http.StatusNoContent, Errors: []int{404, 409, 503}},
+	"GET /audit": {Summary: "List recent model and job changes, oldest first", Status: http.StatusOK, Response: []AuditEntry{},
+		Query: map[string]string{
+			"resource": "Only model or job entries", "resource_id": "Only entries for this model or job", "action": "Only this action, e.g. job.cancel",
+			"since": "Only entries at or after this RFC 3339 time", "until": "Only entries before this RFC 3339 time",
+		}, Errors: []int{400}},
+	"GET /quota":        {Summary: "Show the namespace's quotas and usage", Status: http.StatusOK, Response: QuotaStatus{}},
+	"GET /healthz":      {Summary: "Liveness probe", Status: http.StatusOK, Response: statusResponse{}},
+	"GET /readyz":       {Summary: "Readiness probe; checks Kafka", Status: http.StatusOK, Response: statusResponse{}, Errors: []int{503}},
+	"GET /metrics":      {Summary: "Prometheus metrics", Status: http.StatusOK, Produces: "text/plain"},
+	"GET /version":      {Summary: "Build information of the server", Status: http.StatusOK, Response: BuildInfo{}},
+	"GET /openapi.json": {Summary: "This document", Status: http.StatusOK, Produces: "application/json"},
+}
+
+// openAPIHandler serves an OpenAPI 3 document built from the routes
+// registered on r.
+func openAPIHandler(r *mux.Router) http.HandlerFunc {
+	var (
+		once sync.Once
+		doc  map[string]interface{}
+	)
+	return func(w http.ResponseWriter, req *http.Request) {
+		once.Do(func() { doc = openAPIDocument(r) })
+		writeJSON(w, http.StatusOK, doc)
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+func openAPIDocument(r *mux.Router) map[string]interface{} {
+	b := &schemaBuilder{components: map[string]interface{}{}}
+	paths := map[string]map[string]interface{}{}
+	_ = r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if paths[path] == nil {
+				paths[path] = map[string]interface{}{}
+			}
+			op, ok := apiOperations[method+" "+path]
+			if !ok {
+				slog.Warn("route is missing from the OpenAPI document", "method", method, "path", path)
+			}
+			paths[path][strings.ToLower(method)] = b.operation(op, path)
+		}
+		return nil
+	})
+	b.schema(reflect.TypeOf(ErrorResponse{}))
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Batch Ingestion API",
+			"version": buildInfo().Version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": b.components,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]string{"type": "http", "scheme": "bearer"},
+			},
+		},
+		// Only enforced when the server has API_KEYS set
+		"security": []map[string][]string{{"apiKey": {}}, {}},
+	}
+}
+
+func (b *schemaBuilder) operation(op apiOperation, path string) map[string]interface{} {
+	var params []map[string]interface{}
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]interface{}{
+			"name": m[1], "in": "path", "required": true,
+			"schema": map[string]string{"type": "string"},
+		})
+	}
+	names := make([]string, 0, len(op.Query))
+	for name := range op.Query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		params = append(params, map[string]interface{}{
+			"name": name, "in": "query", "description": op.Query[name],
+			"schema": map[string]string{"type": "string"},
+		})
+	}
+
+	if op.Cached {
+		params = append(params, map[string]interface{}{
+			"name": "If-None-Match", "in": "header", "description": "ETag of a copy already held; answered with 304 while it is current",
+			"schema": map[string]string{"type": "string"},
+		})
+	}
+	if op.Sync {
+		params = append(params, map[string]interface{}{
+			"name": "Prefer", "in": "header", "description": "wait=<seconds> waits for the job to finish, as sync=true does, for at most that long",
+			"schema": map[string]string{"type": "string"},
+		})
+	}
+
+	out := map[string]interface{}{"summary": op.Summary}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+	content := map[string]interface{}{}
+	if op.Body != nil {
+		content["application/json"] = map[string]interface{}{"schema": b.schema(reflect.TypeOf(op.Body))}
+	}
+	if op.Form != nil {
+		content["multipart/form-data"] = map[string]interface{}{"schema": b.schema(reflect.TypeOf(op.Form))}
+	}
+	if len(content) > 0 {
+		out["requestBody"] = map[string]interface{}{"required": true, "content": content}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	ok := map[string]interface{}{"description": http.StatusText(status)}
+	switch {
+	case op.Response != nil:
+		ok["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": b.schema(reflect.TypeOf(op.Response))},
+		}
+	case op.Produces != "":
+		ok["content"] = map[string]interface{}{op.Produces: map[string]interface{}{}}
+	}
+	responses := map[string]interface{}{strconv.Itoa(status): ok}
+	if op.Cached {
+		etag := map[string]interface{}{"ETag": map[string]interface{}{"schema": map[string]string{"type": "string"}}}
+		ok["headers"] = etag
+		responses[strconv.Itoa(http.StatusNotModified)] = map[string]interface{}{
+			"description": http.StatusText(http.StatusNotModified), "headers": etag,
+		}
+	}
+	if op.Sync {
+		final := map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": b.schema(reflect.TypeOf(JobStatus{}))},
+		}
+		// 200 also answers a dedupe request that matched an earlier job
+		responses[strconv.Itoa(http.StatusOK)] = map[string]interface{}{
+			"description": "The job finished while the request waited, or a deduplicated upload's existing job",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": map[string]interface{}{
+					"oneOf": []interface{}{b.schema(reflect.TypeOf(JobStatus{})), b.schema(reflect.TypeOf(op.Response))},
+				}},
+			},
+		}
+		responses[strconv.Itoa(http.StatusMultiStatus)] = map[string]interface{}{
+			"description": "The job finished PARTIAL_SUCCESS while the request waited", "content": final,
+		}
+		responses[strconv.Itoa(http.StatusUnprocessableEntity)] = map[string]interface{}{
+			"description": "The job finished FAILED or CANCELLED while the request waited", "content": final,
+		}
+	}
+	errRef := map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": map[string]string{"$ref": "#/components/schemas/ErrorResponse"}},
+	}
+	codes := append(append([]int{}, op.Errors...), http.StatusInternalServerError)
+	if !publicPaths[path] {
+		codes = append(codes, http.StatusUnauthorized)
+	}
+	for _, code := range codes {
+		responses[strconv.Itoa(code)] = map[string]interface{}{"description": http.StatusText(code), "content": errRef}
+	}
+	out["responses"] = responses
+	return out
+}
+
+// schemaBuilder reflects Go types into OpenAPI schemas. Named structs are
+// emitted once under components and referenced by name.
+type schemaBuilder struct {
+	components map[string]interface{}
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	rawType    = reflect.TypeOf(json.RawMessage{})
+	binaryType = reflect.TypeOf(binaryFile{})
+)
+
+// schemaEnums lists the values of string types with a fixed set.
+var schemaEnums = map[reflect.Type][]string{
+	reflect.TypeOf(JobState("")): {
+		string(StatePending), string(StateRunning), string(StatePaused), string(StateSuccess),
+		string(StatePartialSuccess), string(StateFailed), string(StateCancelled),
+	},
+	reflect.TypeOf(ErrorType("")): {
+		string(ErrorTypeParse), string(ErrorTypeSchemaViolation), string(ErrorTypeKafka),
+	},
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawType:
+		return map[string]interface{}{"type": "object", "description": "JSON Schema document"}
+	case binaryType:
+		return map[string]interface{}{"type": "string", "format": "binary"}
+	}
+	if values, ok := schemaEnums[t]; ok {
+		return map[string]interface{}{"type": "string", "enum": values}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.schema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		name := componentName(t)
+		if _, ok := b.components[name]; !ok {
+			b.components[name] = nil // guards against recursive types
+			b.components[name] = b.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// object builds an object schema from t's JSON-encoded fields. Fields
+// without omitempty are listed as required.
+func (b *schemaBuilder) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	var required []string
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+				collect(f.Type)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = b.schema(f.Type)
+			if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+				required = append(required, name)
+			}
+		}
+	}
+	collect(t)
+	out := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		out["required"] = required
+	}
+	return out
+}
+
+func componentName(t reflect.Type) string {
+	r := []rune(t.Name())
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
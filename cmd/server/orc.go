@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/scritchley/orc"
+)
+
+// orcMagic starts every ORC file; the postscript at its end repeats it.
+const orcMagic = "ORC"
+
+// hasORCPostscript reports whether f ends the way an ORC file does: a
+// postscript, ending with orcMagic, whose length is the file's last byte
+// and fits in the file after the leading magic. f is rewound.
+func hasORCPostscript(f io.ReadSeeker) (bool, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+	ok := false
+	if size > int64(len(orcMagic))+1 {
+		tail := make([]byte, len(orcMagic)+1)
+		if _, err := f.Seek(-int64(len(tail)), io.SeekEnd); err != nil {
+			return false, err
+		}
+		if _, err := io.ReadFull(f, tail); err != nil {
+			return false, invalid("READ_ERROR", err.Error())
+		}
+		psLen := int64(tail[len(orcMagic)])
+		ok = string(tail[:len(orcMagic)]) == orcMagic &&
+			psLen >= int64(len(orcMagic)) && psLen < size-int64(len(orcMagic))
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// sizedReaderAt adapts a seekable input to orc.SizedReaderAt.
+type sizedReaderAt struct {
+	io.ReaderAt
+	size int64
+}
+
+func (s sizedReaderAt) Size() int64 { return s.size }
+
+// openORC reads the footer of an ORC input. ORC is read from the end, so
+// the input must support ReadAt and Seek, as uploads and fetched sources
+// do.
+func openORC(r io.Reader) (or *orc.Reader, err error) {
+	// The reader trusts the postscript length and panics on some corrupt
+	// footers
+	defer func() {
+		if p := recover(); p != nil {
+			or, err = nil, fmt.Errorf("corrupt footer: %v", p)
+		}
+	}()
+	f, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	if !ok {
+		return nil, fmt.Errorf("ORC input is not seekable")
+	}
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(pos, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return orc.NewReader(sizedReaderAt{f, size})
+}
+
+// orcColumns returns the top-level column names of an ORC file, checking
+// its footer on the way.
+func orcColumns(r io.Reader) ([]string, error) {
+	or, err := openORC(r)
+	if err != nil {
+		return nil, invalid("UNSUPPORTED_FILE_TYPE", "invalid ORC file: "+err.Error())
+	}
+	cols := or.Schema().Columns()
+	if len(cols) == 0 {
+		return nil, invalid("UNSUPPORTED_FILE_TYPE", "ORC file has no top-level struct columns")
+	}
+	return cols, nil
+}
+
+// orcRows turns the rows of an ORC file, stripe by stripe, into records in
+// column order. Columns the file does not have become empty cells, as do
+// nulls, and values are written as text so rows are typed by the same
+// rules as CSV cells. Only the columns the job uses are decoded.
+type orcRows struct {
+	cursor  *orc.Cursor
+	columns []string
+	index   []int // position of each column in the cursor's rows, or -1
+	started bool
+	raw     string
+}
+
+func newORCRows(r io.Reader, columns []string) (*orcRows, error) {
+	or, err := openORC(r)
+	if err != nil {
+		return nil, err
+	}
+	o := &orcRows{columns: columns, index: make([]int, len(columns))}
+	have := or.Schema().Columns()
+	var selected []string
+	for i, col := range columns {
+		o.index[i] = -1
+		if indexOf(have, col) >= 0 {
+			o.index[i] = len(selected)
+			selected = append(selected, col)
+		}
+	}
+	o.cursor = or.Select(selected...)
+	return o, nil
+}
+
+func (o *orcRows) Read() ([]string, error) {
+	if !o.started {
+		o.started = true
+		if !o.cursor.Stripes() {
+			return nil, o.end()
+		}
+	}
+	for !o.cursor.Next() {
+		if err := o.cursor.Err(); err != nil {
+			return nil, err
+		}
+		if !o.cursor.Stripes() {
+			return nil, o.end()
+		}
+	}
+
+	values := o.cursor.Row()
+	rec := make([]string, len(o.columns))
+	obj := make(map[string]interface{}, len(o.columns))
+	var unsupported *RowError
+	for i, col := range o.columns {
+		if o.index[i] < 0 || o.index[i] >= len(values) {
+			continue
+		}
+		cell, ok := orcCell(values[o.index[i]])
+		if !ok && unsupported == nil {
+			unsupported = &RowError{
+				Type:     ErrorTypeParse,
+				Column:   col,
+				Observed: fmt.Sprintf("%T", values[o.index[i]]),
+				Message:  fmt.Sprintf("UNSUPPORTED_ORC_TYPE: column '%s' holds a %T, which cannot be read as a cell", col, values[o.index[i]]),
+			}
+		}
+		rec[i] = cell
+		if values[o.index[i]] != nil {
+			obj[col] = cell
+		}
+	}
+	raw, _ := json.Marshal(obj)
+	o.raw = string(raw)
+	if unsupported != nil {
+		return rec, unsupported
+	}
+	return rec, nil
+}
+
+// end returns io.EOF once every stripe is read, or the error that stopped
+// the cursor.
+func (o *orcRows) end() error {
+	if err := o.cursor.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// Raw returns the row as a JSON object of its non-null cells, so retries
+// can read rejected ORC rows as NDJSON.
+func (o *orcRows) Raw([]string) string { return o.raw }
+
+// orcCell formats an ORC value as cell text. Scalars are supported;
+// binary, list, map, struct and union values are not.
+func orcCell(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int8:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case orc.Float:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), true
+	case orc.Double:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64), true
+	case orc.Decimal:
+		return v.String(), true
+	case orc.Date:
+		return v.UTC().Format("2006-01-02"), true
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
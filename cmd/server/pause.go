@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// pauseJob holds a RUNNING job after the row it is on. Its open Kafka
+// transaction, if any, is committed first. The job keeps its place in its
+// inputs, its concurrency quota slot and its JOB_TIMEOUT deadline.
+func pauseJob(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	j, ok := scopedJob(r, id)
+	if !ok {
+		notFound(w, "JOB_NOT_FOUND", "job not found")
+		return
+	}
+	if j.State != StateRunning {
+		conflict(w, "JOB_NOT_RUNNING", "only a RUNNING job can be paused; job is "+string(j.State))
+		return
+	}
+	j.State = StatePaused
+	j.resume = make(chan struct{})
+	j.UpdatedAt = time.Now()
+	audit(r, "job.pause", "job", id)
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// resumeJob lets a PAUSED job carry on from where it stopped.
+func resumeJob(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	j, ok := scopedJob(r, id)
+	if !ok {
+		notFound(w, "JOB_NOT_FOUND", "job not found")
+		return
+	}
+	if j.State != StatePaused {
+		conflict(w, "JOB_NOT_PAUSED", "only a PAUSED job can be resumed; job is "+string(j.State))
+		return
+	}
+	j.State = StateRunning
+	close(j.resume)
+	j.resume = nil
+	j.UpdatedAt = time.Now()
+	audit(r, "job.resume", "job", id)
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// resumeSignal returns the channel closed when js is resumed, or nil when
+// it is not paused.
+func resumeSignal(js *JobStatus) <-chan struct{} {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	return js.resume
+}
+
+// awaitResume blocks until resume is closed or ctx ends, adding the time
+// spent to js.paused. It reports whether the job was resumed.
+func awaitResume(ctx context.Context, js *JobStatus, resume <-chan struct{}) bool {
+	start := time.Now()
+	select {
+	case <-resume:
+	case <-ctx.Done():
+	}
+	jobsMu.Lock()
+	js.paused += time.Since(start)
+	jobsMu.Unlock()
+	return ctx.Err() == nil
+}
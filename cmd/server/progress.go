@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"time"
+)
+
+// estimateRows sets js.Totals.Expected to the number of records in inputs,
+// counted as line breaks before processing starts. It is left 0 (unknown)
+// when any input cannot be counted.
+func estimateRows(ctx context.Context, js *JobStatus, inputs []jobInput) {
+	expected := 0
+	for _, in := range inputs {
+		n, ok := countRows(ctx, in)
+		if !ok {
+			return
+		}
+		expected += n
+	}
+	jobsMu.Lock()
+	js.Totals.Expected = expected
+	js.setProgress(time.Now())
+	jobsMu.Unlock()
+}
+
+// countRows counts the lines of in as its row reader will see them, after
+// decompression and decoding, less any header, and rewinds it. Quoted CSV
+// cells spanning lines and blank lines make it an overestimate. ORC files
+// record their exact row count in the footer. It fails for inputs that
+// cannot be rewound and for Parquet, which is not decoded yet.
+func countRows(ctx context.Context, in jobInput) (int, bool) {
+	f, ok := in.R.(io.ReadSeeker)
+	if !ok || in.Opts.FileType == FileParquet {
+		return 0, false
+	}
+	if in.Opts.FileType == FileORC {
+		or, err := openORC(in.R)
+		if err != nil {
+			return 0, false
+		}
+		return or.NumRows(), true
+	}
+	start, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Seek(start, io.SeekStart)
+
+	r, err := openInput(f, in.Opts)
+	if err != nil {
+		return 0, false
+	}
+	buf := make([]byte, 64<<10)
+	lines, last := 0, byte('\n')
+	for {
+		if ctx.Err() != nil {
+			return 0, false
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			lines += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, false
+		}
+	}
+	if last != '\n' {
+		lines++ // final line without a line break
+	}
+	if in.Opts.HasHeader && lines > 0 {
+		lines--
+	}
+	return lines, true
+}
+
+// setProgress fills in ProgressPercent and ETASeconds of a running job
+// from the records read so far and the time spent unpaused. It stops short of 100% because
+// Totals.Expected may be an overestimate; finishProgress completes it. The
+// caller holds jobsMu.
+func (js *JobStatus) setProgress(now time.Time) {
+	if js.Totals.Expected <= 0 {
+		return
+	}
+	p := math.Round(min(float64(js.read)/float64(js.Totals.Expected)*100, 99.9)*10) / 10
+	js.ProgressPercent = &p
+	elapsed := (now.Sub(js.StartedAt) - js.paused).Seconds()
+	if js.read > 0 && elapsed > 0 {
+		remaining := float64(max(js.Totals.Expected-js.read, 0))
+		eta := int64(math.Ceil(remaining / (float64(js.read) / elapsed)))
+		js.ETASeconds = &eta
+	}
+}
+
+// finishProgress settles a job's progress once it ends: 100% when every
+// input was read, otherwise where it stopped. The caller holds jobsMu.
+func (js *JobStatus) finishProgress(complete bool) {
+	js.ETASeconds = nil
+	if complete && js.Totals.Expected > 0 {
+		p := 100.0
+		js.ProgressPercent = &p
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/keithchambers/batch-ingestion/internal/rowschema"
+)
+
+// protoField is one field of the message derived from a model.
+type protoField struct {
+	Name     string // sanitised Protobuf field name
+	Column   string // model property it is filled from
+	Type     string // int64, double, bool or string
+	Optional bool   // proto3 optional, so an empty cell is told apart from a zero value
+	desc     protoreflect.FieldDescriptor
+}
+
+// protoCodec encodes typed rows as Protobuf messages built at runtime with
+// dynamicpb. schemaID is filled in once the schema has been registered for
+// the job's topic; while it is 0 rows are produced as bare messages.
+type protoCodec struct {
+	name     string
+	fields   []protoField
+	desc     protoreflect.MessageDescriptor
+	schemaID uint32
+}
+
+var protoTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"double": descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"bool":   descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+}
+
+// newProtoCodec derives a proto3 message from the model's properties in
+// declaration order, named and filtered by its column mapping, with field
+// numbers counting up from 1. Fields not listed in required are optional;
+// object and array properties are carried as JSON strings. Protobuf
+// identifiers follow the same rules as Avro names.
+func newProtoCodec(model Model, rs *rowschema.Schema) (*protoCodec, error) {
+	cols := rowschema.Columns(model.Schema)
+	if rs == nil || len(cols) == 0 {
+		return nil, fmt.Errorf("model schema declares no properties")
+	}
+	c := &protoCodec{name: avroName(model.Name)}
+	if model.Name == "" {
+		c.name = avroName("model_" + model.ID)
+	}
+	msg := &descriptorpb.DescriptorProto{Name: proto.String(c.name)}
+	seen := map[string]bool{}
+	for _, col := range cols {
+		name, ok := model.Mapping.outputName(col)
+		if !ok {
+			continue
+		}
+		f := protoField{Name: avroName(name), Column: col, Optional: !rs.Required(col)}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("properties collide as Protobuf field %q", f.Name)
+		}
+		seen[f.Name] = true
+		switch rs.Field(col).Type {
+		case "integer":
+			f.Type = "int64"
+		case "number":
+			f.Type = "double"
+		case "boolean":
+			f.Type = "bool"
+		default:
+			f.Type = "string"
+		}
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(f.Name),
+			Number: proto.Int32(int32(len(c.fields) + 1)),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   protoTypes[f.Type].Enum(),
+		}
+		if f.Optional {
+			// proto3 optional is a synthetic oneof holding just the field
+			fd.Proto3Optional = proto.Bool(true)
+			fd.OneofIndex = proto.Int32(int32(len(msg.OneofDecl)))
+			msg.OneofDecl = append(msg.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + f.Name)})
+		}
+		msg.Field = append(msg.Field, fd)
+		c.fields = append(c.fields, f)
+	}
+
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String(c.name + ".proto"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{msg},
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.desc = file.Messages().Get(0)
+	for i := range c.fields {
+		c.fields[i].desc = c.desc.Fields().Get(i)
+	}
+	return c, nil
+}
+
+// schema returns the message as .proto source, the form the Schema
+// Registry expects for PROTOBUF schemas.
+func (c *protoCodec) schema() string {
+	var b strings.Builder
+	b.WriteString("syntax = \"proto3\";\n\nmessage " + c.name + " {\n")
+	for i, f := range c.fields {
+		b.WriteString("  ")
+		if f.Optional {
+			b.WriteString("optional ")
+		}
+		fmt.Fprintf(&b, "%s %s = %d;\n", f.Type, f.Name, i+1)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// encode writes values, whose cells are named by cols, as a serialized
+// message. Once the schema is registered it is framed in Confluent wire
+// format: magic byte 0, the big-endian schema ID, the message index 0 (the
+// first message of the schema), then the Protobuf bytes.
+func (c *protoCodec) encode(values []interface{}, cols []string) ([]byte, error) {
+	msg := dynamicpb.NewMessage(c.desc)
+	for _, f := range c.fields {
+		var v interface{}
+		if i := indexOf(cols, f.Column); i >= 0 && i < len(values) {
+			v = values[i]
+		}
+		if v == nil {
+			if !f.Optional {
+				return nil, &RowError{
+					Type:     ErrorTypeSchemaViolation,
+					Column:   f.Column,
+					Expected: f.Type,
+					Message:  fmt.Sprintf("REQUIRED_FIELD_EMPTY: required column '%s' is missing or empty", f.Column),
+				}
+			}
+			continue
+		}
+		pv, err := protoValue(f.Type, v)
+		if err != nil {
+			return nil, &RowError{
+				Type:     ErrorTypeSchemaViolation,
+				Column:   f.Column,
+				Expected: f.Type,
+				Message:  fmt.Sprintf("TYPE_MISMATCH: column '%s' cannot be encoded as Protobuf %s: %v", f.Column, f.Type, err),
+			}
+		}
+		msg.Set(f.desc, pv)
+	}
+
+	var buf bytes.Buffer
+	if c.schemaID != 0 {
+		buf.WriteByte(0)
+		_ = binary.Write(&buf, binary.BigEndian, c.schemaID)
+		buf.WriteByte(0) // message indexes [0], shortened to a single 0
+	}
+	return proto.MarshalOptions{Deterministic: true}.MarshalAppend(buf.Bytes(), msg)
+}
+
+func protoValue(typ string, v interface{}) (protoreflect.Value, error) {
+	switch typ {
+	case "int64":
+		n, ok := v.(int64)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("unexpected %T", v)
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case "double":
+		n, ok := v.(float64)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("unexpected %T", v)
+		}
+		return protoreflect.ValueOfFloat64(n), nil
+	case "bool":
+		b, ok := v.(bool)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("unexpected %T", v)
+		}
+		return protoreflect.ValueOfBool(b), nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		s = string(raw)
+	}
+	return protoreflect.ValueOfString(s), nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// quotaWindow is the rolling window the daily quotas are counted over.
+const quotaWindow = 24 * time.Hour
+
+// Quota limits one namespace. A zero field is unlimited.
+type Quota struct {
+	MaxConcurrentJobs    int   `json:"max_concurrent_jobs,omitempty"`
+	MaxRowsPerDay        int64 `json:"max_rows_per_day,omitempty"`
+	MaxUploadBytesPerDay int64 `json:"max_upload_bytes_per_day,omitempty"`
+}
+
+// QuotaUsage is what a namespace has used against its Quota.
+type QuotaUsage struct {
+	ConcurrentJobs int   `json:"concurrent_jobs"`
+	Rows           int64 `json:"rows"`         // in the last 24 hours
+	UploadBytes    int64 `json:"upload_bytes"` // in the last 24 hours
+}
+
+// QuotaStatus is the reply to GET /quota.
+type QuotaStatus struct {
+	Namespace string     `json:"namespace"`
+	Window    string     `json:"window"`
+	Limits    Quota      `json:"limits"`
+	Usage     QuotaUsage `json:"usage"`
+}
+
+// usageEvent is rows or upload bytes counted at a point in time.
+type usageEvent struct {
+	at          time.Time
+	rows, bytes int64
+}
+
+type namespaceUsage struct {
+	running int
+	events  []usageEvent // oldest first
+}
+
+// quotaTracker enforces the quotas read from QUOTA_FILE, a JSON object of
+// namespace to Quota; the "*" entry applies to namespaces not listed.
+// Without the file every namespace is unlimited.
+type quotaTracker struct {
+	mu     sync.Mutex
+	limits map[string]Quota
+	usage  map[string]*namespaceUsage
+}
+
+var quotas = &quotaTracker{usage: map[string]*namespaceUsage{}}
+
+// loadQuotas reads QUOTA_FILE, if set.
+func loadQuotas() error {
+	path := getenv("QUOTA_FILE", "")
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var limits map[string]Quota
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for ns, q := range limits {
+		if q.MaxConcurrentJobs < 0 || q.MaxRowsPerDay < 0 || q.MaxUploadBytesPerDay < 0 {
+			return fmt.Errorf("%s: quota for %q must not be negative", path, ns)
+		}
+	}
+	quotas.mu.Lock()
+	quotas.limits = limits
+	quotas.mu.Unlock()
+	return nil
+}
+
+// limit returns the quota for ns. The caller holds q.mu.
+func (q *quotaTracker) limit(ns string) Quota {
+	if l, ok := q.limits[ns]; ok {
+		return l
+	}
+	return q.limits["*"]
+}
+
+// current returns ns's usage with events older than the window dropped.
+// The caller holds q.mu.
+func (q *quotaTracker) current(ns string, now time.Time) (*namespaceUsage, QuotaUsage) {
+	u, ok := q.usage[ns]
+	if !ok {
+		u = &namespaceUsage{}
+		q.usage[ns] = u
+	}
+	cutoff := now.Add(-quotaWindow)
+	i := 0
+	for i < len(u.events) && !u.events[i].at.After(cutoff) {
+		i++
+	}
+	u.events = u.events[i:]
+	sum := QuotaUsage{ConcurrentJobs: u.running}
+	for _, e := range u.events {
+		sum.Rows += e.rows
+		sum.UploadBytes += e.bytes
+	}
+	return u, sum
+}
+
+// reserve admits a new job of ns uploading bytes, or returns a 429
+// QUOTA_EXCEEDED error naming the exhausted quota. An admitted job counts
+// as running until release.
+func (q *quotaTracker) reserve(ns string, bytes int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	l := q.limit(ns)
+	u, sum := q.current(ns, now)
+	switch {
+	case l.MaxConcurrentJobs > 0 && sum.ConcurrentJobs >= l.MaxConcurrentJobs:
+		return quotaExceeded(fmt.Sprintf("namespace %s already has %d of %d concurrent jobs (max_concurrent_jobs)", ns, sum.ConcurrentJobs, l.MaxConcurrentJobs))
+	case l.MaxRowsPerDay > 0 && sum.Rows >= l.MaxRowsPerDay:
+		return quotaExceeded(fmt.Sprintf("namespace %s ingested %d rows in the last 24h, limit is %d (max_rows_per_day)", ns, sum.Rows, l.MaxRowsPerDay))
+	case l.MaxUploadBytesPerDay > 0 && sum.UploadBytes+bytes > l.MaxUploadBytesPerDay:
+		return quotaExceeded(fmt.Sprintf("namespace %s uploaded %d bytes in the last 24h; %d more would exceed the limit of %d (max_upload_bytes_per_day)", ns, sum.UploadBytes, bytes, l.MaxUploadBytesPerDay))
+	}
+	u.running++
+	if bytes > 0 {
+		u.events = append(u.events, usageEvent{at: now, bytes: bytes})
+	}
+	return nil
+}
+
+// chargeBytes counts bytes fetched for an admitted job of ns whose size
+// was not known when reserve ran. It returns a QUOTA_EXCEEDED error when
+// they take the namespace past max_upload_bytes_per_day.
+func (q *quotaTracker) chargeBytes(ns string, bytes int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	l := q.limit(ns)
+	u, sum := q.current(ns, now)
+	u.events = append(u.events, usageEvent{at: now, bytes: bytes})
+	if l.MaxUploadBytesPerDay > 0 && sum.UploadBytes+bytes > l.MaxUploadBytesPerDay {
+		return quotaExceeded(fmt.Sprintf("namespace %s uploaded %d bytes in the last 24h; the %d fetched exceed the limit of %d (max_upload_bytes_per_day)", ns, sum.UploadBytes, bytes, l.MaxUploadBytesPerDay))
+	}
+	return nil
+}
+
+// chargeRows counts rows read by a running job of ns and returns how many
+// more the namespace may read, or a QUOTA_EXCEEDED error once these take
+// it past max_rows_per_day.
+func (q *quotaTracker) chargeRows(ns string, rows int64) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	l := q.limit(ns)
+	u, sum := q.current(ns, now)
+	u.events = append(u.events, usageEvent{at: now, rows: rows})
+	if l.MaxRowsPerDay <= 0 {
+		return math.MaxInt64, nil
+	}
+	if sum.Rows+rows > l.MaxRowsPerDay {
+		return 0, quotaExceeded(fmt.Sprintf("namespace %s reached its limit of %d rows in the last 24h (max_rows_per_day)", ns, l.MaxRowsPerDay))
+	}
+	return l.MaxRowsPerDay - sum.Rows - rows, nil
+}
+
+// release ends a job admitted by reserve. uncharged corrects the rows
+// charged while it ran to the rows it processed, and is negative when
+// rows were charged and then taken back.
+func (q *quotaTracker) release(ns string, uncharged int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u, _ := q.current(ns, time.Now())
+	u.running--
+	if uncharged != 0 {
+		u.events = append(u.events, usageEvent{at: time.Now(), rows: uncharged})
+	}
+}
+
+// quotaChargeRows is the most rows a running job reads between charging
+// them to its namespace.
+const quotaChargeRows = 1000
+
+// rowQuota charges the rows one job reads to its namespace as it runs, in
+// batches no larger than the namespace has left, so the job stops at
+// max_rows_per_day instead of only the next job being refused. It is used
+// by the job's goroutine alone.
+type rowQuota struct {
+	ns       string
+	pending  int64 // rows read since the last charge
+	batch    int64 // rows that may be read before the next charge
+	charged  int64 // rows charged so far
+	exceeded bool  // the namespace passed its limit while the job ran
+}
+
+// take counts one row read, returning a QUOTA_EXCEEDED error when it is
+// past the namespace's limit.
+func (rq *rowQuota) take() error {
+	rq.pending++
+	if rq.pending <= rq.batch {
+		return nil
+	}
+	left, err := quotas.chargeRows(rq.ns, rq.pending)
+	rq.charged += rq.pending
+	rq.pending = 0
+	rq.batch = min(left, quotaChargeRows)
+	if err != nil {
+		rq.exceeded = true
+	}
+	return err
+}
+
+func quotaExceeded(msg string) *requestError {
+	return &requestError{Status: http.StatusTooManyRequests, Code: "QUOTA_EXCEEDED", Message: msg}
+}
+
+// getQuota reports the caller's namespace limits and current usage.
+func getQuota(w http.ResponseWriter, r *http.Request) {
+	ns := namespace(r)
+	quotas.mu.Lock()
+	_, usage := quotas.current(ns, time.Now())
+	status := QuotaStatus{Namespace: ns, Window: fmt.Sprintf("%dh", int(quotaWindow.Hours())), Limits: quotas.limit(ns), Usage: usage}
+	quotas.mu.Unlock()
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// reapJobs periodically removes terminal jobs whose last update is older
+// than JOB_TTL (default 72h) and deletes their Kafka topics. The sweep runs
+// every JOB_SWEEP_INTERVAL (default 10m) until ctx is cancelled.
+func reapJobs(ctx context.Context) {
+	ttl := envDuration("JOB_TTL", 72*time.Hour)
+	interval := envDuration("JOB_SWEEP_INTERVAL", 10*time.Minute)
+	slog.Info("job reaper started", "ttl", ttl.String(), "interval", interval.String())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweepJobs(time.Now().Add(-ttl))
+		}
+	}
+}
+
+// sweepJobs removes every terminal job last updated before cutoff. A job
+// whose topics cannot be deleted is kept so the next pass retries it.
+func sweepJobs(cutoff time.Time) {
+	var expired []*JobStatus
+	jobsMu.RLock()
+	for _, j := range jobs {
+		if j.State.Terminal() && j.UpdatedAt.Before(cutoff) {
+			expired = append(expired, j)
+		}
+	}
+	jobsMu.RUnlock()
+	if len(expired) == 0 {
+		return
+	}
+
+	reaped, topics := 0, 0
+	for _, j := range expired {
+		id := j.JobID
+		n, err := deleteTopics(j.Namespace, id)
+		topics += n
+		if err != nil {
+			slog.Warn("reaper could not delete job topics", "job_id", id, "error", err)
+			continue
+		}
+		jobsMu.Lock()
+		delete(jobs, id)
+		jobsMu.Unlock()
+		reaped++
+	}
+	slog.Info("reaped expired jobs", "jobs", reaped, "topics", topics)
+}
+
+// reapStaleJobs fails RUNNING jobs whose UpdatedAt has not advanced for
+// STALE_JOB_THRESHOLD (default 5m). Running jobs heartbeat far more often
+// than that, so such a job is wedged. STALE_JOB_THRESHOLD=0 disables it.
+func reapStaleJobs(ctx context.Context) {
+	if getenv("STALE_JOB_THRESHOLD", "") == "0" {
+		slog.Info("stale job detection disabled")
+		return
+	}
+	threshold := envDuration("STALE_JOB_THRESHOLD", 5*time.Minute)
+	slog.Info("stale job detector started", "threshold", threshold.String())
+
+	ticker := time.NewTicker(threshold / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			failStaleJobs(time.Now().Add(-threshold))
+		}
+	}
+}
+
+// failStaleJobs marks every RUNNING job last updated before cutoff as
+// FAILED with reason STALE and stops its processing.
+func failStaleJobs(cutoff time.Time) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	for id, j := range jobs {
+		if j.State != StateRunning || !j.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		slog.Warn("failing stale job", "job_id", id, "model_id", j.ModelID,
+			"last_update", j.UpdatedAt)
+		j.State = StateFailed
+		j.Reason = "STALE"
+		j.UpdatedAt = time.Now()
+		if j.cancel != nil {
+			j.cancel()
+		}
+		jobsFinished.WithLabelValues(string(j.State)).Inc()
+	}
+}
+
+// envDuration parses a duration from the environment, falling back to def
+// when the variable is unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := getenv(key, "")
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		slog.Warn("invalid duration, using default", "var", key, "value", v, "default", def.String())
+		return def
+	}
+	return d
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// compileSchema checks a model schema against the JSON Schema meta-schema
+// by compiling it. Rows are typed by rowschema, not by the compiled schema.
+func compileSchema(raw json.RawMessage) (*jsonschema.Schema, error) {
+	if len(bytes.TrimSpace(raw)) == 0 || string(raw) == "null" {
+		return nil, errors.New("schema is required")
+	}
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	if err := c.AddResource("mem://model.json", bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	return c.Compile("mem://model.json")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+var (
+	// jobsCtx is the parent of every processJob context; cancelling it
+	// interrupts jobs that outlive the shutdown grace period.
+	jobsCtx, stopJobs = context.WithCancel(context.Background())
+	jobsWG            sync.WaitGroup
+	draining          atomic.Bool
+
+	// bgCtx scopes housekeeping goroutines such as the job reaper and the
+	// audit log writer; it is cancelled once requests and jobs have drained,
+	// so their last entries are still written.
+	bgCtx, stopBackground = context.WithCancel(context.Background())
+	bgWG                  sync.WaitGroup
+)
+
+// startBackground runs fn in a goroutine that serve waits for on shutdown.
+func startBackground(fn func(context.Context)) {
+	bgWG.Add(1)
+	go func() {
+		defer bgWG.Done()
+		fn(bgCtx)
+	}()
+}
+
+// serve runs srv, and grpcSrv on grpcLis when it is set, until
+// SIGINT/SIGTERM, then stops accepting requests and gives running jobs
+// SHUTDOWN_GRACE (default 30s) to finish.
+func serve(srv *http.Server, grpcSrv *grpc.Server, grpcLis net.Listener) {
+	errCh := make(chan error, 2)
+	go func() { errCh <- srv.ListenAndServe() }()
+	if grpcSrv != nil {
+		slog.Info("gRPC listening", "addr", grpcLis.Addr().String())
+		go func() { errCh <- grpcSrv.Serve(grpcLis) }()
+	}
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-errCh:
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
+	case <-sigCtx.Done():
+	}
+
+	grace, err := time.ParseDuration(getenv("SHUTDOWN_GRACE", "30s"))
+	if err != nil {
+		slog.Warn("invalid SHUTDOWN_GRACE, using 30s", "error", err)
+		grace = 30 * time.Second
+	}
+	slog.Info("shutting down, waiting for running jobs", "grace", grace.String())
+	draining.Store(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("HTTP shutdown", "error", err)
+	}
+	if grpcSrv != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			grpcSrv.Stop()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		jobsWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("grace period expired, interrupting running jobs")
+		stopJobs()
+		<-done
+	}
+	stopBackground()
+	bgWG.Wait()
+	if kafkaWriter != nil {
+		if err := kafkaWriter.Close(); err != nil {
+			slog.Error("closing Kafka writer", "error", err)
+		}
+	}
+	slog.Info("shutdown complete")
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// jobSourceRequest is the JSON form of POST /jobs, used when the server
+// fetches the data itself instead of receiving an upload.
+type jobSourceRequest struct {
+	ModelID   string `json:"model_id"`
+	SourceURL string `json:"source_url"`
+	jobFields
+}
+
+// createJobFromURL validates the source up front, then downloads and
+// processes it in the background. Download failures end the job FAILED
+// with reason SOURCE_FETCH_ERROR.
+func createJobFromURL(w http.ResponseWriter, r *http.Request) {
+	var req jobSourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "INVALID_JSON", err.Error())
+		return
+	}
+	model, err := lookupModel(namespace(r), req.ModelID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	wait := syncWait(r, req.Sync)
+	opts, err := parseJobOptions(applyModelDefaults(req.jobFields, model), requestID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if req.SourceURL == "" {
+		badRequest(w, "MISSING_SOURCE_URL", "source_url is required")
+		return
+	}
+	src, err := url.Parse(req.SourceURL)
+	if err != nil || src.Host == "" || (src.Scheme != "http" && src.Scheme != "https" && src.Scheme != "s3") {
+		badRequest(w, "UNSUPPORTED_SOURCE", "source_url must be an http://, https:// or s3:// URL")
+		return
+	}
+	if err := checkSourceHost(src); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	size, err := sourceSize(r.Context(), src)
+	if err != nil {
+		badRequest(w, "SOURCE_FETCH_ERROR", err.Error())
+		return
+	}
+	if size > maxUploadBytes {
+		fileTooLarge(w)
+		return
+	}
+
+	js := &JobStatus{
+		JobID:        randomID(),
+		Namespace:    model.Namespace,
+		ModelID:      model.ID,
+		ModelVersion: model.Version,
+		State:        StatePending,
+		OutputFormat: opts.OutputFormat,
+		SourceURL:    req.SourceURL,
+		UpdatedAt:    time.Now(),
+	}
+	if err := quotas.reserve(js.Namespace, size); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	runJob(js, opts, func(ctx context.Context) {
+		f, err := fetchSource(ctx, src)
+		if err != nil {
+			reason := "SOURCE_FETCH_ERROR"
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				reason = "TIMEOUT"
+			}
+			failJob(js, reason, err)
+			return
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		if size < 0 {
+			// HEAD did not give the size, so the download is charged now
+			fi, err := f.Stat()
+			if err != nil {
+				failJob(js, "SOURCE_FETCH_ERROR", err)
+				return
+			}
+			if err := quotas.chargeBytes(js.Namespace, fi.Size()); err != nil {
+				failJob(js, "QUOTA_EXCEEDED", err)
+				return
+			}
+		}
+
+		name := path.Base(src.Path)
+		jf, err := inspectFile(f, name, model, &opts)
+		if err != nil {
+			reason := "SOURCE_FETCH_ERROR"
+			var rerr *requestError
+			if errors.As(err, &rerr) {
+				reason = rerr.Code
+			}
+			failJob(js, reason, err)
+			return
+		}
+		jobsMu.Lock()
+		js.Checksum, js.Size = jf.Checksum, jf.Size
+		js.opts = opts
+		jobsMu.Unlock()
+		processJob(ctx, js, []jobInput{{Name: name, R: f, Opts: opts}}, opts)
+	})
+	audit(r, "job.create", "job", js.JobID)
+
+	respondJobCreated(w, r, js, wait)
+}
+
+// sourceSize returns the size of the object at src, or -1 when the server
+// does not say.
+func sourceSize(ctx context.Context, src *url.URL) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if src.Scheme == "s3" {
+		client, err := s3Client()
+		if err != nil {
+			return 0, err
+		}
+		out, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(src.Host),
+			Key:    aws.String(strings.TrimPrefix(src.Path, "/")),
+		})
+		if err != nil {
+			return 0, err
+		}
+		return aws.ToInt64(out.ContentLength), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", src.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := sourceClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed:
+		return -1, nil // size is enforced while downloading instead
+	case resp.StatusCode >= 300:
+		return 0, fmt.Errorf("HEAD %s returned HTTP %d", src.Redacted(), resp.StatusCode)
+	}
+	return resp.ContentLength, nil
+}
+
+// fetchSource downloads src into a temporary file, enforcing the upload
+// size limit, and returns it rewound. The caller removes the file.
+func fetchSource(ctx context.Context, src *url.URL) (*os.File, error) {
+	var body io.ReadCloser
+	if src.Scheme == "s3" {
+		client, err := s3Client()
+		if err != nil {
+			return nil, err
+		}
+		out, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(src.Host),
+			Key:    aws.String(strings.TrimPrefix(src.Path, "/")),
+		})
+		if err != nil {
+			return nil, err
+		}
+		body = out.Body
+	} else {
+		req, err := http.NewRequestWithContext(ctx, "GET", src.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := sourceClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s returned HTTP %d", src.Redacted(), resp.StatusCode)
+		}
+		body = resp.Body
+	}
+	defer body.Close()
+
+	f, err := os.CreateTemp("", "batch-source-*")
+	if err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(f, io.LimitReader(body, maxUploadBytes+1))
+	if err == nil && n > maxUploadBytes {
+		err = errors.New(uploadLimitMessage("source"))
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// sourceAllow lists the only hosts an http(s) source_url, or a redirect
+// it follows, may name (SOURCE_URL_ALLOW, comma-separated). Listed hosts
+// may resolve to private addresses; when the list is empty any host is
+// allowed, but only at a public address.
+var sourceAllow = envList("SOURCE_URL_ALLOW", "")
+
+// maxSourceRedirects is how many redirects fetching a source follows.
+const maxSourceRedirects = 5
+
+// sourceClient fetches http(s) sources. It connects directly rather than
+// through any HTTP_PROXY, so the address it dials is the one checked.
+var sourceClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext:           dialSource,
+		ForceAttemptHTTP2:     true,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxSourceRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxSourceRedirects)
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+		}
+		return checkSourceHost(req.URL)
+	},
+}
+
+// checkSourceHost refuses an http(s) src whose host SOURCE_URL_ALLOW does
+// not list, with 400 SOURCE_NOT_ALLOWED.
+func checkSourceHost(src *url.URL) error {
+	if src.Scheme == "s3" || len(sourceAllow) == 0 || sourceHostListed(src.Hostname()) {
+		return nil
+	}
+	return invalid("SOURCE_NOT_ALLOWED", "source_url host "+src.Hostname()+" is not in SOURCE_URL_ALLOW")
+}
+
+func sourceHostListed(host string) bool {
+	for _, h := range sourceAllow {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialSource connects to a source host. Unless the host is listed in
+// SOURCE_URL_ALLOW, the resolved address must be public, so neither the
+// URL nor its DNS can point the server at loopback, private or link-local
+// services such as cloud metadata endpoints.
+func dialSource(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if host, _, err := net.SplitHostPort(addr); err != nil || !sourceHostListed(host) {
+		d.Control = refuseNonPublic
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+// refuseNonPublic is a net.Dialer Control that fails connections to
+// addresses that are not public unicast.
+func refuseNonPublic(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	ip := ap.Addr().Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || sharedAddrSpace.Contains(ip) {
+		return fmt.Errorf("source address %s is not public", ip)
+	}
+	return nil
+}
+
+// sharedAddrSpace is the carrier-grade NAT range, which IsPrivate leaves
+// out.
+var sharedAddrSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+var (
+	s3Once   sync.Once
+	s3Cached *s3.Client
+	s3Err    error
+)
+
+// s3Client returns a client configured by the standard AWS credential and
+// region resolution (environment, shared config, instance role).
+func s3Client() (*s3.Client, error) {
+	s3Once.Do(func() {
+		cfg, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			s3Err = err
+			return
+		}
+		s3Cached = s3.NewFromConfig(cfg)
+	})
+	return s3Cached, s3Err
+}
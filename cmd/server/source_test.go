@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestRefuseNonPublic(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:4700::1111]:443", true},
+		{"127.0.0.1:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"[::1]:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+	}
+	for _, tt := range tests {
+		err := refuseNonPublic("tcp", tt.addr, nil)
+		if (err == nil) != tt.public {
+			t.Errorf("refuseNonPublic(%s) = %v, want public %v", tt.addr, err, tt.public)
+		}
+	}
+}
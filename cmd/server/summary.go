@@ -0,0 +1,22 @@
+package main
+
+import "regexp"
+
+// errorCode matches the stable code that starts most rejection messages,
+// such as TYPE_MISMATCH or COLUMN_COUNT_MISMATCH.
+var errorCode = regexp.MustCompile(`^([A-Z][A-Z0-9_]+):`)
+
+// summaryKey is the rejection_summary entry a rejected row counts toward:
+// the message's code, or the error type when it has none, followed by
+// ":" and the column when the rejection names one. Codes never contain
+// ":", so the key splits unambiguously at the first one.
+func (e *RowError) summaryKey() string {
+	reason := string(e.Type)
+	if m := errorCode.FindStringSubmatch(e.Message); m != nil {
+		reason = m[1]
+	}
+	if e.Column == "" {
+		return reason
+	}
+	return reason + ":" + e.Column
+}
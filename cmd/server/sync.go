@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// syncWait returns how long POST /jobs should wait for the job it creates
+// to finish before answering, or 0 to answer 202 straight away. A request
+// asks to wait with sync=true, or with an RFC 7240 "Prefer: wait=<seconds>"
+// header, which may also shorten the wait. SYNC_JOB_WAIT caps every wait;
+// setting it to 0 turns synchronous jobs off.
+func syncWait(r *http.Request, sync bool) time.Duration {
+	limit := envDuration("SYNC_JOB_WAIT", 30*time.Second)
+	wait := time.Duration(0)
+	if sync {
+		wait = limit
+	}
+	// Preferences are hints, so ones that do not parse are ignored
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(pref), "=")
+			if !strings.EqualFold(strings.TrimSpace(name), "wait") {
+				continue
+			}
+			secs, err := strconv.Atoi(strings.Trim(strings.TrimSpace(value), `"`))
+			if err != nil || secs <= 0 {
+				continue
+			}
+			wait = time.Duration(secs) * time.Second
+		}
+	}
+	return min(wait, limit)
+}
+
+// respondJobCreated answers a request that created js. When wait is set
+// and the job finishes within it, the reply is the final JobStatus: 200
+// for SUCCESS, 207 Multi-Status for PARTIAL_SUCCESS, where only some rows
+// were produced, and 422 Unprocessable Entity for FAILED and CANCELLED,
+// so a client checking only for 2xx does not take them as done. Otherwise, and always without wait, it
+// is the usual 202 with the job ID for the client to poll. A client that
+// disconnects while waiting leaves the job running.
+func respondJobCreated(w http.ResponseWriter, r *http.Request, js *JobStatus, wait time.Duration) {
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-js.done:
+			jobsMu.RLock()
+			defer jobsMu.RUnlock()
+			status := http.StatusOK
+			switch js.State {
+			case StatePartialSuccess:
+				status = http.StatusMultiStatus
+			case StateFailed, StateCancelled:
+				status = http.StatusUnprocessableEntity
+			}
+			writeJSON(w, status, js)
+			return
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"job_id": js.JobID})
+}
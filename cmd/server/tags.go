@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// maxTags bounds how many tags one job may carry.
+const maxTags = 20
+
+// parseTags reads the tags form field: either a JSON object of strings or
+// comma-separated key=value pairs.
+func parseTags(v string) (map[string]string, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	tags := map[string]string{}
+	if strings.HasPrefix(v, "{") {
+		if err := json.Unmarshal([]byte(v), &tags); err != nil {
+			return nil, invalid("INVALID_TAGS", "tags must be a JSON object of strings or key=value pairs: "+err.Error())
+		}
+		return tags, nil
+	}
+	for _, pair := range strings.Split(v, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, invalid("INVALID_TAGS", "tag "+strings.TrimSpace(pair)+" is not key=value")
+		}
+		tags[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return tags, nil
+}
+
+// validateTags rejects tags that could not be matched by a ?tag= filter.
+func validateTags(tags map[string]string) error {
+	if len(tags) > maxTags {
+		return invalid("INVALID_TAGS", "at most 20 tags are allowed")
+	}
+	for key := range tags {
+		if key == "" || strings.ContainsAny(key, ":=,") {
+			return invalid("INVALID_TAGS", "tag keys must be non-empty and must not contain ':', '=' or ','")
+		}
+	}
+	return nil
+}
+
+// matchTags reports whether tags satisfy every filter. A filter is
+// "key:value", or just "key" to match any value.
+func matchTags(tags map[string]string, filters []string) bool {
+	for _, f := range filters {
+		key, value, hasValue := strings.Cut(f, ":")
+		got, ok := tags[key]
+		if !ok || (hasValue && got != value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package main
+
+import "math"
+
+// errorRateMinRows is how many records a job reads before max_error_rate
+// is checked while it runs, so a bad first row does not end it at 100%.
+// The rate over every record is checked again when the job finishes.
+const errorRateMinRows = 100
+
+// parseErrorThresholds checks the max_errors and max_error_rate job
+// settings; nil leaves either unlimited.
+func parseErrorThresholds(maxErrors *int, maxErrorRate *float64) error {
+	if maxErrors != nil && *maxErrors < 0 {
+		return invalid("INVALID_MAX_ERRORS", "max_errors must not be negative")
+	}
+	if r := maxErrorRate; r != nil && (math.IsNaN(*r) || *r < 0 || *r > 1) {
+		return invalid("INVALID_MAX_ERROR_RATE", "max_error_rate must be a fraction between 0 and 1")
+	}
+	return nil
+}
+
+// errorsExceeded reports whether a job with errors rejected rows out of
+// read records is past its max_errors or max_error_rate. final marks the
+// check made once every record is read, when the rate applies however
+// few records there were.
+func (o JobOptions) errorsExceeded(errors, read int, final bool) bool {
+	if o.MaxErrors != nil && errors > *o.MaxErrors {
+		return true
+	}
+	if o.MaxErrorRate == nil || read == 0 || (!final && read < errorRateMinRows) {
+		return false
+	}
+	return float64(errors)/float64(read) > *o.MaxErrorRate
+}
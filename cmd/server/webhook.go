@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	callbackAttempts = 4
+	callbackTimeout  = 5 * time.Second
+	callbackBackoff  = time.Second
+)
+
+// validCallbackURL reports whether raw is an absolute http(s) URL.
+func validCallbackURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// signPayload returns the X-Batch-Signature value for body, or "" when
+// CALLBACK_SECRET is not configured.
+func signPayload(body []byte) string {
+	secret := getenv("CALLBACK_SECRET", "")
+	if secret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyCallback POSTs the job's final status to its callback URL,
+// retrying network errors and 5xx responses with exponential backoff.
+// Failures are only logged; they never change the job's state.
+func notifyCallback(js *JobStatus, callbackURL string, logger *slog.Logger) {
+	jobsMu.RLock()
+	body, err := json.Marshal(js)
+	jobsMu.RUnlock()
+	if err != nil {
+		logger.Error("failed to marshal job status for callback", "error", err)
+		return
+	}
+	signature := signPayload(body)
+
+	delay := callbackBackoff
+	for attempt := 1; ; attempt++ {
+		err := postCallback(callbackURL, body, signature)
+		if err == nil {
+			logger.Info("job callback delivered", "attempt", attempt)
+			return
+		}
+		if attempt == callbackAttempts {
+			logger.Warn("job callback failed, giving up", "attempts", attempt, "error", err)
+			return
+		}
+		logger.Debug("job callback failed, retrying", "attempt", attempt, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func postCallback(callbackURL string, body []byte, signature string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		req.Header.Set("X-Batch-Signature", signature)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
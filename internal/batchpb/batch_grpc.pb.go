@@ -0,0 +1,554 @@
+// gRPC interface of the batch ingestion server, served on GRPC_PORT. Each
+// call runs the same operation as the REST endpoint named in its comment,
+// against the same models and jobs, so the two APIs can be mixed freely.
+//
+// Metadata carries what REST carries in headers: "authorization"
+// ("Bearer <key>"), "x-namespace" and "x-request-id". Errors use the REST
+// error code as the start of the status message, e.g.
+// "MODEL_NOT_FOUND: model not found".
+//
+// Message fields have the names of the REST JSON fields, so the JSON
+// documented in DESIGN.md describes them too.
+//
+// Regenerate the Go code in internal/batchpb with
+//   protoc --go_out=. --go_opt=module=github.com/keithchambers/batch-ingestion \
+//     --go-grpc_out=. --go-grpc_opt=module=github.com/keithchambers/batch-ingestion \
+//     proto/batch/v1/batch.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v5.27.2
+// source: proto/batch/v1/batch.proto
+
+package batchpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	BatchIngestion_ListModels_FullMethodName         = "/batch.v1.BatchIngestion/ListModels"
+	BatchIngestion_GetModel_FullMethodName           = "/batch.v1.BatchIngestion/GetModel"
+	BatchIngestion_CreateModel_FullMethodName        = "/batch.v1.BatchIngestion/CreateModel"
+	BatchIngestion_UpdateModel_FullMethodName        = "/batch.v1.BatchIngestion/UpdateModel"
+	BatchIngestion_DeleteModel_FullMethodName        = "/batch.v1.BatchIngestion/DeleteModel"
+	BatchIngestion_CreateJob_FullMethodName          = "/batch.v1.BatchIngestion/CreateJob"
+	BatchIngestion_GetJob_FullMethodName             = "/batch.v1.BatchIngestion/GetJob"
+	BatchIngestion_CancelJob_FullMethodName          = "/batch.v1.BatchIngestion/CancelJob"
+	BatchIngestion_DeleteJob_FullMethodName          = "/batch.v1.BatchIngestion/DeleteJob"
+	BatchIngestion_StreamRejectedRows_FullMethodName = "/batch.v1.BatchIngestion/StreamRejectedRows"
+)
+
+// BatchIngestionClient is the client API for BatchIngestion service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BatchIngestionClient interface {
+	// GET /models
+	ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error)
+	// GET /models/{id}
+	GetModel(ctx context.Context, in *GetModelRequest, opts ...grpc.CallOption) (*Model, error)
+	// POST /models
+	CreateModel(ctx context.Context, in *CreateModelRequest, opts ...grpc.CallOption) (*Model, error)
+	// PUT /models/{id}
+	UpdateModel(ctx context.Context, in *UpdateModelRequest, opts ...grpc.CallOption) (*Model, error)
+	// DELETE /models/{id}
+	DeleteModel(ctx context.Context, in *DeleteModelRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// POST /jobs. The first message holds the settings; the rest carry the
+	// files in chunks, each file starting with a chunk that names it.
+	CreateJob(ctx context.Context, opts ...grpc.CallOption) (BatchIngestion_CreateJobClient, error)
+	// GET /jobs/{id}
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// POST /jobs/{id}/cancel
+	CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// DELETE /jobs/{id}
+	DeleteJob(ctx context.Context, in *DeleteJobRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// GET /jobs/{id}/rejected, one row per message
+	StreamRejectedRows(ctx context.Context, in *StreamRejectedRowsRequest, opts ...grpc.CallOption) (BatchIngestion_StreamRejectedRowsClient, error)
+}
+
+type batchIngestionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBatchIngestionClient(cc grpc.ClientConnInterface) BatchIngestionClient {
+	return &batchIngestionClient{cc}
+}
+
+func (c *batchIngestionClient) ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListModelsResponse)
+	err := c.cc.Invoke(ctx, BatchIngestion_ListModels_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *batchIngestionClient) GetModel(ctx context.Context, in *GetModelRequest, opts ...grpc.CallOption) (*Model, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Model)
+	err := c.cc.Invoke(ctx, BatchIngestion_GetModel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *batchIngestionClient) CreateModel(ctx context.Context, in *CreateModelRequest, opts ...grpc.CallOption) (*Model, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Model)
+	err := c.cc.Invoke(ctx, BatchIngestion_CreateModel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *batchIngestionClient) UpdateModel(ctx context.Context, in *UpdateModelRequest, opts ...grpc.CallOption) (*Model, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Model)
+	err := c.cc.Invoke(ctx, BatchIngestion_UpdateModel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *batchIngestionClient) DeleteModel(ctx context.Context, in *DeleteModelRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, BatchIngestion_DeleteModel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *batchIngestionClient) CreateJob(ctx context.Context, opts ...grpc.CallOption) (BatchIngestion_CreateJobClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &BatchIngestion_ServiceDesc.Streams[0], BatchIngestion_CreateJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &batchIngestionCreateJobClient{ClientStream: stream}
+	return x, nil
+}
+
+type BatchIngestion_CreateJobClient interface {
+	Send(*CreateJobRequest) error
+	CloseAndRecv() (*CreateJobResponse, error)
+	grpc.ClientStream
+}
+
+type batchIngestionCreateJobClient struct {
+	grpc.ClientStream
+}
+
+func (x *batchIngestionCreateJobClient) Send(m *CreateJobRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *batchIngestionCreateJobClient) CloseAndRecv() (*CreateJobResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(CreateJobResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *batchIngestionClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, BatchIngestion_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *batchIngestionClient) CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, BatchIngestion_CancelJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *batchIngestionClient) DeleteJob(ctx context.Context, in *DeleteJobRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, BatchIngestion_DeleteJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *batchIngestionClient) StreamRejectedRows(ctx context.Context, in *StreamRejectedRowsRequest, opts ...grpc.CallOption) (BatchIngestion_StreamRejectedRowsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &BatchIngestion_ServiceDesc.Streams[1], BatchIngestion_StreamRejectedRows_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &batchIngestionStreamRejectedRowsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type BatchIngestion_StreamRejectedRowsClient interface {
+	Recv() (*RejectedRow, error)
+	grpc.ClientStream
+}
+
+type batchIngestionStreamRejectedRowsClient struct {
+	grpc.ClientStream
+}
+
+func (x *batchIngestionStreamRejectedRowsClient) Recv() (*RejectedRow, error) {
+	m := new(RejectedRow)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// BatchIngestionServer is the server API for BatchIngestion service.
+// All implementations must embed UnimplementedBatchIngestionServer
+// for forward compatibility
+type BatchIngestionServer interface {
+	// GET /models
+	ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error)
+	// GET /models/{id}
+	GetModel(context.Context, *GetModelRequest) (*Model, error)
+	// POST /models
+	CreateModel(context.Context, *CreateModelRequest) (*Model, error)
+	// PUT /models/{id}
+	UpdateModel(context.Context, *UpdateModelRequest) (*Model, error)
+	// DELETE /models/{id}
+	DeleteModel(context.Context, *DeleteModelRequest) (*emptypb.Empty, error)
+	// POST /jobs. The first message holds the settings; the rest carry the
+	// files in chunks, each file starting with a chunk that names it.
+	CreateJob(BatchIngestion_CreateJobServer) error
+	// GET /jobs/{id}
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	// POST /jobs/{id}/cancel
+	CancelJob(context.Context, *CancelJobRequest) (*Job, error)
+	// DELETE /jobs/{id}
+	DeleteJob(context.Context, *DeleteJobRequest) (*emptypb.Empty, error)
+	// GET /jobs/{id}/rejected, one row per message
+	StreamRejectedRows(*StreamRejectedRowsRequest, BatchIngestion_StreamRejectedRowsServer) error
+	mustEmbedUnimplementedBatchIngestionServer()
+}
+
+// UnimplementedBatchIngestionServer must be embedded to have forward compatible implementations.
+type UnimplementedBatchIngestionServer struct {
+}
+
+func (UnimplementedBatchIngestionServer) ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListModels not implemented")
+}
+func (UnimplementedBatchIngestionServer) GetModel(context.Context, *GetModelRequest) (*Model, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetModel not implemented")
+}
+func (UnimplementedBatchIngestionServer) CreateModel(context.Context, *CreateModelRequest) (*Model, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateModel not implemented")
+}
+func (UnimplementedBatchIngestionServer) UpdateModel(context.Context, *UpdateModelRequest) (*Model, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateModel not implemented")
+}
+func (UnimplementedBatchIngestionServer) DeleteModel(context.Context, *DeleteModelRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteModel not implemented")
+}
+func (UnimplementedBatchIngestionServer) CreateJob(BatchIngestion_CreateJobServer) error {
+	return status.Errorf(codes.Unimplemented, "method CreateJob not implemented")
+}
+func (UnimplementedBatchIngestionServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedBatchIngestionServer) CancelJob(context.Context, *CancelJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelJob not implemented")
+}
+func (UnimplementedBatchIngestionServer) DeleteJob(context.Context, *DeleteJobRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteJob not implemented")
+}
+func (UnimplementedBatchIngestionServer) StreamRejectedRows(*StreamRejectedRowsRequest, BatchIngestion_StreamRejectedRowsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamRejectedRows not implemented")
+}
+func (UnimplementedBatchIngestionServer) mustEmbedUnimplementedBatchIngestionServer() {}
+
+// UnsafeBatchIngestionServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BatchIngestionServer will
+// result in compilation errors.
+type UnsafeBatchIngestionServer interface {
+	mustEmbedUnimplementedBatchIngestionServer()
+}
+
+func RegisterBatchIngestionServer(s grpc.ServiceRegistrar, srv BatchIngestionServer) {
+	s.RegisterService(&BatchIngestion_ServiceDesc, srv)
+}
+
+func _BatchIngestion_ListModels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListModelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BatchIngestionServer).ListModels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BatchIngestion_ListModels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BatchIngestionServer).ListModels(ctx, req.(*ListModelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BatchIngestion_GetModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BatchIngestionServer).GetModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BatchIngestion_GetModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BatchIngestionServer).GetModel(ctx, req.(*GetModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BatchIngestion_CreateModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BatchIngestionServer).CreateModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BatchIngestion_CreateModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BatchIngestionServer).CreateModel(ctx, req.(*CreateModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BatchIngestion_UpdateModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BatchIngestionServer).UpdateModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BatchIngestion_UpdateModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BatchIngestionServer).UpdateModel(ctx, req.(*UpdateModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BatchIngestion_DeleteModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BatchIngestionServer).DeleteModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BatchIngestion_DeleteModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BatchIngestionServer).DeleteModel(ctx, req.(*DeleteModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BatchIngestion_CreateJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BatchIngestionServer).CreateJob(&batchIngestionCreateJobServer{ServerStream: stream})
+}
+
+type BatchIngestion_CreateJobServer interface {
+	SendAndClose(*CreateJobResponse) error
+	Recv() (*CreateJobRequest, error)
+	grpc.ServerStream
+}
+
+type batchIngestionCreateJobServer struct {
+	grpc.ServerStream
+}
+
+func (x *batchIngestionCreateJobServer) SendAndClose(m *CreateJobResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *batchIngestionCreateJobServer) Recv() (*CreateJobRequest, error) {
+	m := new(CreateJobRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _BatchIngestion_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BatchIngestionServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BatchIngestion_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BatchIngestionServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BatchIngestion_CancelJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BatchIngestionServer).CancelJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BatchIngestion_CancelJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BatchIngestionServer).CancelJob(ctx, req.(*CancelJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BatchIngestion_DeleteJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BatchIngestionServer).DeleteJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BatchIngestion_DeleteJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BatchIngestionServer).DeleteJob(ctx, req.(*DeleteJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BatchIngestion_StreamRejectedRows_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRejectedRowsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BatchIngestionServer).StreamRejectedRows(m, &batchIngestionStreamRejectedRowsServer{ServerStream: stream})
+}
+
+type BatchIngestion_StreamRejectedRowsServer interface {
+	Send(*RejectedRow) error
+	grpc.ServerStream
+}
+
+type batchIngestionStreamRejectedRowsServer struct {
+	grpc.ServerStream
+}
+
+func (x *batchIngestionStreamRejectedRowsServer) Send(m *RejectedRow) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// BatchIngestion_ServiceDesc is the grpc.ServiceDesc for BatchIngestion service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BatchIngestion_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "batch.v1.BatchIngestion",
+	HandlerType: (*BatchIngestionServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListModels",
+			Handler:    _BatchIngestion_ListModels_Handler,
+		},
+		{
+			MethodName: "GetModel",
+			Handler:    _BatchIngestion_GetModel_Handler,
+		},
+		{
+			MethodName: "CreateModel",
+			Handler:    _BatchIngestion_CreateModel_Handler,
+		},
+		{
+			MethodName: "UpdateModel",
+			Handler:    _BatchIngestion_UpdateModel_Handler,
+		},
+		{
+			MethodName: "DeleteModel",
+			Handler:    _BatchIngestion_DeleteModel_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _BatchIngestion_GetJob_Handler,
+		},
+		{
+			MethodName: "CancelJob",
+			Handler:    _BatchIngestion_CancelJob_Handler,
+		},
+		{
+			MethodName: "DeleteJob",
+			Handler:    _BatchIngestion_DeleteJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CreateJob",
+			Handler:       _BatchIngestion_CreateJob_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "StreamRejectedRows",
+			Handler:       _BatchIngestion_StreamRejectedRows_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/batch/v1/batch.proto",
+}
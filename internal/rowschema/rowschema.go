@@ -0,0 +1,445 @@
+// Package rowschema types CSV cells according to a model's JSON schema.
+// The server applies it to every ingested row and the CLI applies it when
+// validating a file locally, so both report the same errors.
+package rowschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Error describes why a row does not satisfy the schema. Message starts
+// with a stable code such as TYPE_MISMATCH or REQUIRED_FIELD_EMPTY.
+type Error struct {
+	Column   string
+	Observed string
+	Expected string
+	Message  string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Field is how the model schema types one column.
+type Field struct {
+	Type     string // JSON Schema type; "" leaves the cell a string
+	Format   string // "date-time" or "date" for string columns
+	Layout   string // x-date-format: Go time layout the cell is written in
+	Nullable bool   // type list includes "null"
+
+	Pattern *regexp.Regexp // JSON Schema pattern for string values
+	Enum    []interface{}  // allowed values, decoded as encoding/json does
+
+	Minimum   *float64 // inclusive bounds for numeric values
+	Maximum   *float64
+	MinLength *int // bounds in characters for string values
+	MaxLength *int
+}
+
+// Schema holds the per-column rules derived from a model schema.
+type Schema struct {
+	fields   map[string]Field
+	required []string
+}
+
+// Nulls lists the cell values a source writes for a missing value, such
+// as NULL, \N or NA. Matching cells become JSON null before any other rule
+// applies. A column listed in Columns uses its own values instead of
+// Values; an empty list there turns null values off for that column.
+type Nulls struct {
+	Values  []string            `json:"values,omitempty"`
+	Columns map[string][]string `json:"columns,omitempty"`
+}
+
+// IsNull reports whether v, a cell of column col, is a null value. A nil
+// Nulls has none.
+func (n *Nulls) IsNull(col, v string) bool {
+	if n == nil {
+		return false
+	}
+	values, ok := n.Columns[col]
+	if !ok {
+		values = n.Values
+	}
+	return indexOf(values, v) >= 0
+}
+
+// dateTimeLayouts are tried in order for date-time columns without an
+// explicit x-date-format.
+var dateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+}
+
+// New extracts column rules from a JSON schema. It returns nil when the
+// schema declares no properties, in which case rows are produced as plain
+// strings.
+func New(schema json.RawMessage) *Schema {
+	var doc struct {
+		Properties map[string]struct {
+			Type       json.RawMessage   `json:"type"`
+			Format     string            `json:"format"`
+			DateFormat string            `json:"x-date-format"`
+			Pattern    string            `json:"pattern"`
+			Enum       []json.RawMessage `json:"enum"`
+			Minimum    *float64          `json:"minimum"`
+			Maximum    *float64          `json:"maximum"`
+			MinLength  *int              `json:"minLength"`
+			MaxLength  *int              `json:"maxLength"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(schema, &doc); err != nil || len(doc.Properties) == 0 {
+		return nil
+	}
+	rs := &Schema{fields: make(map[string]Field, len(doc.Properties)), required: doc.Required}
+	for name, p := range doc.Properties {
+		rule := Field{
+			Format: p.Format, Layout: p.DateFormat,
+			Minimum: p.Minimum, Maximum: p.Maximum, MinLength: p.MinLength, MaxLength: p.MaxLength,
+		}
+		var types []string
+		var single string
+		if err := json.Unmarshal(p.Type, &single); err == nil {
+			types = []string{single}
+		} else {
+			_ = json.Unmarshal(p.Type, &types)
+		}
+		for _, t := range types {
+			if t == "null" {
+				rule.Nullable = true
+			} else if rule.Type == "" {
+				rule.Type = t
+			}
+		}
+		// Patterns were already checked when the model was saved
+		if p.Pattern != "" {
+			rule.Pattern, _ = regexp.Compile(p.Pattern)
+		}
+		for _, raw := range p.Enum {
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err == nil {
+				rule.Enum = append(rule.Enum, v)
+			}
+		}
+		rs.fields[name] = rule
+	}
+	return rs
+}
+
+// Field returns the rule for a column; undeclared columns get the zero
+// Field, which leaves cells as strings.
+func (rs *Schema) Field(name string) Field {
+	return rs.fields[name]
+}
+
+// Has reports whether the schema declares a property called name.
+func (rs *Schema) Has(name string) bool {
+	_, ok := rs.fields[name]
+	return ok
+}
+
+// Required reports whether the schema lists name as required.
+func (rs *Schema) Required(name string) bool {
+	return indexOf(rs.required, name) >= 0
+}
+
+// IsHeader reports whether rec looks like a header row: every cell names a
+// declared property.
+func (rs *Schema) IsHeader(rec []string) bool {
+	if len(rec) == 0 {
+		return false
+	}
+	for _, name := range rec {
+		if !rs.Has(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Coerce converts rec, whose cells are named by cols, into typed values.
+// Cells that nulls names become nil first. Required fields must be
+// present, non-empty and not null. A nil Schema returns the other cells
+// unchanged. Failures are reported as *Error.
+func (rs *Schema) Coerce(rec, cols []string, nulls *Nulls) ([]interface{}, error) {
+	values := make([]interface{}, len(rec))
+	null := make([]bool, len(rec))
+	for i, v := range rec {
+		values[i] = v
+		col := ""
+		if i < len(cols) {
+			col = cols[i]
+		}
+		if nulls.IsNull(col, v) {
+			values[i], null[i] = nil, true
+		}
+	}
+	if rs == nil {
+		return values, nil
+	}
+
+	for _, name := range rs.required {
+		i := indexOf(cols, name)
+		if i < 0 || i >= len(rec) || rec[i] == "" {
+			return nil, &Error{
+				Column:   name,
+				Expected: rs.fields[name].Type,
+				Message:  fmt.Sprintf("REQUIRED_FIELD_EMPTY: required column '%s' is missing or empty", name),
+			}
+		}
+		if null[i] {
+			return nil, &Error{
+				Column:   name,
+				Observed: rec[i],
+				Expected: rs.fields[name].Type,
+				Message:  fmt.Sprintf("REQUIRED_FIELD_EMPTY: required column '%s' holds the null value %q", name, rec[i]),
+			}
+		}
+	}
+
+	for i, v := range rec {
+		if i >= len(cols) {
+			break
+		}
+		rule, ok := rs.fields[cols[i]]
+		if !ok || null[i] {
+			continue
+		}
+		val, err := rule.coerce(v)
+		// Blank optional cells are absent values, not constraint violations
+		if err == nil && v != "" {
+			err = rule.check(cols[i], val)
+		}
+		var rerr *Error
+		if errors.As(err, &rerr) {
+			rerr.Observed = v
+			return nil, rerr
+		}
+		if err != nil {
+			expected := rule.Type
+			if rule.Format != "" {
+				expected += " (" + rule.Format + ")"
+			}
+			return nil, &Error{
+				Column:   cols[i],
+				Observed: v,
+				Expected: expected,
+				Message:  fmt.Sprintf("TYPE_MISMATCH: column '%s' expected %s: %v", cols[i], expected, err),
+			}
+		}
+		values[i] = val
+	}
+	return values, nil
+}
+
+// coerce converts a single cell. Empty cells of non-string columns become
+// null.
+func (f Field) coerce(v string) (interface{}, error) {
+	if v == "" && (f.Type != "string" || f.Nullable) {
+		return nil, nil
+	}
+	switch f.Type {
+	case "integer":
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", v)
+		}
+		return n, nil
+	case "number":
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", v)
+		}
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("%q is not a finite number", v)
+		}
+		return n, nil
+	case "boolean":
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not true or false", v)
+	case "object", "array":
+		var doc interface{}
+		if err := json.Unmarshal([]byte(v), &doc); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	case "string":
+		return f.coerceDate(v)
+	}
+	return v, nil
+}
+
+// check applies the pattern, range, length and enum constraints to a
+// coerced value.
+func (f Field) check(col string, val interface{}) error {
+	if s, ok := val.(string); ok && f.Pattern != nil && !f.Pattern.MatchString(s) {
+		return &Error{
+			Column:   col,
+			Expected: f.Type,
+			Message:  fmt.Sprintf("PATTERN_MISMATCH: column '%s' does not match pattern %s", col, f.Pattern),
+		}
+	}
+	if err := f.checkBounds(col, val); err != nil {
+		return err
+	}
+	if len(f.Enum) == 0 {
+		return nil
+	}
+	// Round-trip so numbers compare as the float64s the enum decoded to
+	var norm interface{}
+	if b, err := json.Marshal(val); err == nil {
+		_ = json.Unmarshal(b, &norm)
+	}
+	allowed := make([]string, len(f.Enum))
+	for i, e := range f.Enum {
+		if reflect.DeepEqual(e, norm) {
+			return nil
+		}
+		b, _ := json.Marshal(e)
+		allowed[i] = string(b)
+	}
+	return &Error{
+		Column:   col,
+		Expected: f.Type,
+		Message:  fmt.Sprintf("ENUM_MISMATCH: column '%s' must be one of %s", col, strings.Join(allowed, ", ")),
+	}
+}
+
+// checkBounds applies minimum and maximum to numbers and minLength and
+// maxLength to strings, whose length is counted in characters as JSON
+// Schema counts it.
+func (f Field) checkBounds(col string, val interface{}) error {
+	bounds := func(code, format string, args ...interface{}) error {
+		return &Error{Column: col, Expected: f.Type, Message: code + ": " + fmt.Sprintf(format, args...)}
+	}
+	var n float64
+	switch v := val.(type) {
+	case int64:
+		n = float64(v)
+	case float64:
+		n = v
+	case string:
+		length := utf8.RuneCountInString(v)
+		if f.MinLength != nil && length < *f.MinLength {
+			return bounds("LENGTH_MISMATCH", "column '%s' value %q is %d characters, shorter than minLength %d", col, v, length, *f.MinLength)
+		}
+		if f.MaxLength != nil && length > *f.MaxLength {
+			return bounds("LENGTH_MISMATCH", "column '%s' value %q is %d characters, longer than maxLength %d", col, v, length, *f.MaxLength)
+		}
+		return nil
+	default:
+		return nil
+	}
+	if f.Minimum != nil && n < *f.Minimum {
+		return bounds("OUT_OF_RANGE", "column '%s' value %s is below minimum %s", col, formatNumber(n), formatNumber(*f.Minimum))
+	}
+	if f.Maximum != nil && n > *f.Maximum {
+		return bounds("OUT_OF_RANGE", "column '%s' value %s is above maximum %s", col, formatNumber(n), formatNumber(*f.Maximum))
+	}
+	return nil
+}
+
+// formatNumber writes n without an exponent.
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// coerceDate normalises date and date-time columns; other strings pass
+// through unchanged.
+func (f Field) coerceDate(v string) (interface{}, error) {
+	layouts := dateTimeLayouts
+	out := time.RFC3339
+	switch f.Format {
+	case "date-time":
+	case "date":
+		layouts, out = []string{"2006-01-02"}, "2006-01-02"
+	default:
+		return v, nil
+	}
+	if f.Layout != "" {
+		layouts = []string{f.Layout}
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+			return t.Format(out), nil
+		}
+	}
+	return nil, fmt.Errorf("%q does not match the declared format", v)
+}
+
+// CheckWidth reports a COLUMN_COUNT_MISMATCH when rec does not have
+// exactly n cells.
+func CheckWidth(rec []string, n int) error {
+	if len(rec) == n {
+		return nil
+	}
+	return &Error{
+		Observed: strconv.Itoa(len(rec)),
+		Message:  fmt.Sprintf("COLUMN_COUNT_MISMATCH: expected %d got %d", n, len(rec)),
+	}
+}
+
+// Fit pads rec with empty cells or drops trailing cells so it has exactly
+// n.
+func Fit(rec []string, n int) []string {
+	if len(rec) > n {
+		return rec[:n]
+	}
+	return append(rec, make([]string, n-len(rec))...)
+}
+
+// Columns returns the top-level property names of a JSON schema in
+// declaration order.
+func Columns(schema json.RawMessage) []string {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(schema, &doc); err != nil {
+		return nil
+	}
+	props, ok := doc["properties"]
+	if !ok {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(props))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+	var cols []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		key, _ := tok.(string)
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil
+		}
+		cols = append(cols, key)
+	}
+	return cols
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
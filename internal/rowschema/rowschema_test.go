@@ -0,0 +1,100 @@
+package rowschema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const nullsSchema = `{
+	"type": "object",
+	"properties": {
+		"id":    {"type": "integer"},
+		"age":   {"type": "integer"},
+		"color": {"type": "string", "enum": ["red", "blue"]}
+	},
+	"required": ["id"]
+}`
+
+func TestCoerceNullSentinels(t *testing.T) {
+	rs := New(json.RawMessage(nullsSchema))
+	cols := []string{"id", "age", "color"}
+	nulls := &Nulls{Values: []string{"NULL", `\N`, "NA"}}
+
+	for _, sentinel := range nulls.Values {
+		t.Run(sentinel, func(t *testing.T) {
+			// Optional columns: null skips the type and enum rules
+			got, err := rs.Coerce([]string{"1", sentinel, sentinel}, cols, nulls)
+			if err != nil {
+				t.Fatalf("optional columns: unexpected error %v", err)
+			}
+			if got[0] != int64(1) || got[1] != nil || got[2] != nil {
+				t.Errorf("optional columns: got %#v, want [1 <nil> <nil>]", got)
+			}
+
+			// Required column: null counts as missing
+			_, err = rs.Coerce([]string{sentinel, "30", "red"}, cols, nulls)
+			var rerr *Error
+			if !errors.As(err, &rerr) {
+				t.Fatalf("required column: got %v, want *Error", err)
+			}
+			if rerr.Column != "id" || rerr.Observed != sentinel ||
+				!strings.HasPrefix(rerr.Message, "REQUIRED_FIELD_EMPTY: required column 'id' holds the null value") {
+				t.Errorf("required column: got %+v", rerr)
+			}
+		})
+	}
+}
+
+func TestCoerceRequiredEmptyIsNotNull(t *testing.T) {
+	rs := New(json.RawMessage(nullsSchema))
+	nulls := &Nulls{Values: []string{""}}
+	_, err := rs.Coerce([]string{"", "30", "red"}, []string{"id", "age", "color"}, nulls)
+	var rerr *Error
+	if !errors.As(err, &rerr) || rerr.Message != "REQUIRED_FIELD_EMPTY: required column 'id' is missing or empty" {
+		t.Errorf("got %v, want the missing or empty message", err)
+	}
+}
+
+func TestCoerceColumnNulls(t *testing.T) {
+	rs := New(json.RawMessage(nullsSchema))
+	cols := []string{"id", "age", "color"}
+	nulls := &Nulls{
+		Values:  []string{"NULL"},
+		Columns: map[string][]string{"age": {"-"}, "color": {}},
+	}
+
+	got, err := rs.Coerce([]string{"1", "-", "red"}, cols, nulls)
+	if err != nil || got[1] != nil {
+		t.Errorf("column override: got %#v, %v; want age null", got, err)
+	}
+
+	// The override replaces the shared values for that column
+	_, err = rs.Coerce([]string{"1", "NULL", "red"}, cols, nulls)
+	if err == nil || !strings.HasPrefix(err.Error(), "TYPE_MISMATCH") {
+		t.Errorf("shared value on an overridden column: got %v, want TYPE_MISMATCH", err)
+	}
+
+	// An empty override turns null values off for the column
+	_, err = rs.Coerce([]string{"1", "30", "NULL"}, cols, nulls)
+	if err == nil || !strings.HasPrefix(err.Error(), "ENUM_MISMATCH") {
+		t.Errorf("column with nulls off: got %v, want ENUM_MISMATCH", err)
+	}
+}
+
+func TestCoerceWithoutNulls(t *testing.T) {
+	rs := New(json.RawMessage(nullsSchema))
+	_, err := rs.Coerce([]string{"1", "NULL", "red"}, []string{"id", "age", "color"}, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "TYPE_MISMATCH") {
+		t.Errorf("got %v, want TYPE_MISMATCH", err)
+	}
+}
+
+func TestCoerceNullsWithoutSchema(t *testing.T) {
+	var rs *Schema
+	got, err := rs.Coerce([]string{"1", "NA"}, []string{"a", "b"}, &Nulls{Values: []string{"NA"}})
+	if err != nil || got[0] != "1" || got[1] != nil {
+		t.Errorf("got %#v, %v; want [\"1\" <nil>]", got, err)
+	}
+}
@@ -3,9 +3,14 @@ set -euo pipefail
 ROOT=$(dirname "${BASH_SOURCE[0]}")/..
 pushd "${ROOT}" > /dev/null
 
-echo "Building server and CLI binaries..."
-docker build -f Dockerfile.server -t batch-ingest-api:latest .
-docker build -f Dockerfile.cli -t batch-cli:latest .
+VERSION=${VERSION:-$(git describe --tags --always --dirty 2>/dev/null || echo dev)}
+COMMIT=$(git rev-parse HEAD 2>/dev/null || echo unknown)
+BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+BUILD_ARGS=(--build-arg "VERSION=${VERSION}" --build-arg "COMMIT=${COMMIT}" --build-arg "BUILD_DATE=${BUILD_DATE}")
+
+echo "Building server and CLI binaries (${VERSION})..."
+docker build "${BUILD_ARGS[@]}" -f Dockerfile.server -t batch-ingest-api:latest .
+docker build "${BUILD_ARGS[@]}" -f Dockerfile.cli -t batch-cli:latest .
 
 echo "Copying CLI binary to ./bin for local use..."
 mkdir -p bin
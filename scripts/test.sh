@@ -169,7 +169,7 @@ test_api_job_processing() {
     
     # Test DLQ is empty for successful job
     local api_dlq=$(curl -s "$API/jobs/$api_job_id/rejected")
-    local api_dlq_count=$(echo "$api_dlq" | jq 'length')
+    local api_dlq_count=$(echo "$api_dlq" | jq '.rows | length')
     test_assert "API job has no rejected rows" '[ "$api_dlq_count" -eq 0 ]'
     
     # Test Parquet file detection
@@ -213,7 +213,7 @@ test_cli_job_processing() {
         wait_for_job "$cli_job_id"
         
         # Test CLI job status retrieval
-        local cli_status_output=$($CLI job status "$cli_job_id" 2>/dev/null || echo '{"error": "Status failed"}')
+        local cli_status_output=$($CLI job status "$cli_job_id" --output json 2>/dev/null || echo '{"error": "Status failed"}')
         local cli_state=$(echo "$cli_status_output" | jq -r '.state // ""')
         local cli_rows=$(echo "$cli_status_output" | jq -r '.totals.rows // 0')
         
@@ -221,8 +221,8 @@ test_cli_job_processing() {
         test_assert "CLI job processed 7 rows" '[ "$cli_rows" -eq 7 ]'
         
         # Test CLI rejected rows retrieval
-        local cli_dlq_output=$($CLI job rejected "$cli_job_id" 2>/dev/null || echo '[]')
-        local cli_dlq_count=$(echo "$cli_dlq_output" | jq 'length // 0')
+        local cli_dlq_output=$($CLI job rejected "$cli_job_id" --output json 2>/dev/null || echo '{"rows":[]}')
+        local cli_dlq_count=$(echo "$cli_dlq_output" | jq '.rows | length // 0')
         test_assert "CLI rejected rows retrieval successful" '[ "$cli_dlq_count" -eq 0 ]'
     fi
     
@@ -261,12 +261,12 @@ test_dlq_functionality() {
     # Test DLQ entries
     sleep 2  # Give DLQ time to process
     local dlq_entries=$(curl -s "$API/jobs/$dlq_job_id/rejected")
-    local dlq_count=$(echo "$dlq_entries" | jq 'length // 0')
+    local dlq_count=$(echo "$dlq_entries" | jq '.rows | length // 0')
     test_assert "DLQ contains rejected rows" '[ "$dlq_count" -gt 0 ]'
     
     # Validate DLQ entry structure
     if [ "$dlq_count" -gt 0 ]; then
-        local first_entry=$(echo "$dlq_entries" | jq '.[0]')
+        local first_entry=$(echo "$dlq_entries" | jq '.rows[0]')
         local has_job_id=$(echo "$first_entry" | jq -e '.job_id' >/dev/null 2>&1 && echo "true" || echo "false")
         local has_row_number=$(echo "$first_entry" | jq -e '.row_number' >/dev/null 2>&1 && echo "true" || echo "false")
         local has_error=$(echo "$first_entry" | jq -e '.error' >/dev/null 2>&1 && echo "true" || echo "false")